@@ -0,0 +1,228 @@
+// Command geocode fills in missing coordinates for directory records using the
+// configured geocoder (GEOCODER, or -provider to override).
+//
+//	go run ./cmd/geocode -table providers -dry-run
+//	go run ./cmd/geocode -table all -provider census -min-confidence 0.8
+//	go run ./cmd/geocode -table aba_centers -provider csv -csv address_points.csv
+package main
+
+import (
+	"bac/internal/config"
+	"bac/internal/database"
+	"bac/internal/geocode"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// target describes how to find and update records in one table
+type target struct {
+	// pending selects id and address for rows without coordinates
+	pending string
+	// all selects id and address for every row, used with -force
+	all    string
+	update func(tx *gorm.DB, id string, result *geocode.Result) error
+}
+
+var targets = map[string]target{
+	"providers": {
+		pending: `SELECT id::text AS id, COALESCE(NULLIF(address, ''), name || ', Los Angeles, CA') AS address
+			FROM providers
+			WHERE deleted_at IS NULL AND (latitude IS NULL OR longitude IS NULL OR latitude = 0 OR longitude = 0)
+			ORDER BY id`,
+		all: `SELECT id::text AS id, COALESCE(NULLIF(address, ''), name || ', Los Angeles, CA') AS address
+			FROM providers WHERE deleted_at IS NULL ORDER BY id`,
+		update: func(tx *gorm.DB, id string, result *geocode.Result) error {
+			return tx.Exec("UPDATE providers SET latitude = ?, longitude = ? WHERE id::text = ?",
+				result.Latitude, result.Longitude, id).Error
+		},
+	},
+	"resources": {
+		pending: `SELECT id::text AS id, address FROM resources
+			WHERE address <> '' AND deleted_at IS NULL
+				AND (latitude IS NULL OR longitude IS NULL OR (latitude = 0 AND longitude = 0))
+			ORDER BY id`,
+		all: `SELECT id::text AS id, address FROM resources WHERE address <> '' AND deleted_at IS NULL ORDER BY id`,
+		update: func(tx *gorm.DB, id string, result *geocode.Result) error {
+			return tx.Exec("UPDATE resources SET latitude = ?, longitude = ?, updated_at = NOW() WHERE id::text = ?",
+				result.Latitude, result.Longitude, id).Error
+		},
+	},
+	"aba_centers": {
+		pending: `SELECT id::text AS id, concat_ws(', ', street, city, 'CA ' || zip) AS address
+			FROM aba_centers
+			WHERE street <> '' AND deleted_at IS NULL AND (latitude IS NULL OR longitude IS NULL)
+			ORDER BY id`,
+		all: `SELECT id::text AS id, concat_ws(', ', street, city, 'CA ' || zip) AS address
+			FROM aba_centers WHERE street <> '' AND deleted_at IS NULL ORDER BY id`,
+		update: func(tx *gorm.DB, id string, result *geocode.Result) error {
+			return tx.Exec("UPDATE aba_centers SET latitude = ?, longitude = ?, geocoded_at = NOW() WHERE id::text = ?",
+				result.Latitude, result.Longitude, id).Error
+		},
+	},
+	"regional_centers": {
+		pending: `SELECT id::text AS id,
+				concat_ws(', ', address, city, concat_ws(' ', COALESCE(NULLIF(state, ''), 'CA'), zip_code)) AS address
+			FROM regional_centers
+			WHERE address <> '' AND deleted_at IS NULL AND location IS NULL
+			ORDER BY id`,
+		all: `SELECT id::text AS id,
+				concat_ws(', ', address, city, concat_ws(' ', COALESCE(NULLIF(state, ''), 'CA'), zip_code)) AS address
+			FROM regional_centers WHERE address <> '' AND deleted_at IS NULL ORDER BY id`,
+		update: func(tx *gorm.DB, id string, result *geocode.Result) error {
+			// The sync trigger derives geog from location, and a located center
+			// drops out of the regional_center_coordinate_errors view
+			return tx.Exec(`UPDATE regional_centers
+				SET location = ST_SetSRID(ST_MakePoint(?, ?), 4326),
+				    location_coordinates = ?
+				WHERE id::text = ?`,
+				result.Longitude, result.Latitude,
+				fmt.Sprintf("(%f, %f)", result.Latitude, result.Longitude), id).Error
+		},
+	},
+}
+
+var tableOrder = []string{"providers", "resources", "aba_centers", "regional_centers"}
+
+type summary struct {
+	Checked       int
+	Updated       int
+	LowConfidence int
+	NotFound      int
+	Failed        int
+}
+
+func main() {
+	tables := flag.String("table", "all", "comma separated tables to geocode: "+strings.Join(tableOrder, ", ")+", or all")
+	provider := flag.String("provider", "", "geocoder to use: google, nominatim, census or csv (defaults to GEOCODER)")
+	csvPath := flag.String("csv", "", "address point CSV for the csv provider (defaults to GEOCODER_CSV_PATH)")
+	minConfidence := flag.Float64("min-confidence", 0.8, "skip results below this confidence (0-1)")
+	limit := flag.Int("limit", 0, "maximum records per table, 0 for no limit")
+	force := flag.Bool("force", false, "re-geocode records that already have coordinates")
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+	db, err := database.Initialize(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+
+	geocoderConfig := cfg.GeocoderConfig()
+	geocoderConfig.CacheDB = db
+	if *provider != "" {
+		geocoderConfig.Provider = *provider
+	}
+	if *csvPath != "" {
+		geocoderConfig.CSVPath = *csvPath
+	}
+	if geocoderConfig.Provider == "" || geocoderConfig.Provider == "none" {
+		log.Fatal("No geocoder configured, set GEOCODER or pass -provider")
+	}
+	geocoder, err := geocode.New(geocoderConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize geocoder:", err)
+	}
+
+	selected := tableOrder
+	if *tables != "all" {
+		selected = strings.Split(*tables, ",")
+	}
+
+	failed := false
+	for _, table := range selected {
+		table = strings.TrimSpace(table)
+		t, ok := targets[table]
+		if !ok {
+			log.Fatalf("Unknown table %q, expected one of %s", table, strings.Join(tableOrder, ", "))
+		}
+
+		s, err := run(db, geocoder, table, t, *force, *limit, *minConfidence, *dryRun)
+		if err != nil {
+			log.Printf("%s: %v", table, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: %d checked, %d updated, %d below confidence, %d not found, %d failed (dry run %t)\n",
+			table, s.Checked, s.Updated, s.LowConfidence, s.NotFound, s.Failed, *dryRun)
+		if s.Failed > 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// run geocodes every pending record of one table
+func run(db *gorm.DB, geocoder geocode.Geocoder, table string, t target, force bool, limit int, minConfidence float64, dryRun bool) (summary, error) {
+	var s summary
+
+	query := t.pending
+	if force {
+		query = t.all
+	}
+	if limit > 0 {
+		query = fmt.Sprintf("%s LIMIT %d", query, limit)
+	}
+
+	var rows []struct {
+		ID      string
+		Address string
+	}
+	if err := db.Raw(query).Scan(&rows).Error; err != nil {
+		return s, err
+	}
+
+	ctx := context.Background()
+	for _, row := range rows {
+		s.Checked++
+
+		result, err := geocoder.Geocode(ctx, row.Address)
+		switch {
+		case errors.Is(err, geocode.ErrNotFound):
+			log.Printf("%s %s: no match for %q", table, row.ID, row.Address)
+			s.NotFound++
+			continue
+		case err != nil:
+			log.Printf("%s %s: geocoding %q failed: %v", table, row.ID, row.Address, err)
+			s.Failed++
+			continue
+		}
+
+		if result.Confidence < minConfidence {
+			log.Printf("%s %s: skipping %q, confidence %.2f below %.2f (%s)",
+				table, row.ID, row.Address, result.Confidence, minConfidence, result.FormattedAddress)
+			s.LowConfidence++
+			continue
+		}
+
+		if dryRun {
+			log.Printf("%s %s: would set %f, %f for %q (confidence %.2f)",
+				table, row.ID, result.Latitude, result.Longitude, row.Address, result.Confidence)
+			s.Updated++
+			continue
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return t.update(tx, row.ID, result)
+		}); err != nil {
+			log.Printf("%s %s: update failed: %v", table, row.ID, err)
+			s.Failed++
+			continue
+		}
+		s.Updated++
+	}
+
+	return s, nil
+}
@@ -0,0 +1,92 @@
+// Command import-aba-centers loads ABA centers from the outreach team's CSV or
+// XLSX spreadsheet. Run with -dry-run first to review the per-row report.
+//
+//	go run ./cmd/import-aba-centers -file centers.xlsx -dry-run
+//	go run ./cmd/import-aba-centers -file centers.csv -mode insert -map "name=Agency,street=Address 1"
+package main
+
+import (
+	"bac/internal/abaimport"
+	"bac/internal/config"
+	"bac/internal/database"
+	"bac/internal/geocode"
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	file := flag.String("file", "", "CSV or XLSX file to import")
+	mode := flag.String("mode", abaimport.ModeUpsert, "upsert updates matching centers, insert skips them")
+	mapping := flag.String("map", "", "comma separated field=Column Header pairs, e.g. name=Agency,zip=Postal Code")
+	dryRun := flag.Bool("dry-run", false, "validate and report without writing")
+	skipInvalid := flag.Bool("skip-invalid", false, "import the valid rows even when others have errors")
+	geocodeRows := flag.Bool("geocode", true, "geocode rows without coordinates using GEOCODER")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	columns := map[string]string{}
+	for _, pair := range strings.Split(*mapping, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, header, ok := strings.Cut(pair, "=")
+		if !ok {
+			log.Fatalf("Invalid -map entry %q, expected field=Column Header", pair)
+		}
+		columns[strings.TrimSpace(field)] = strings.TrimSpace(header)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+	db, err := database.Initialize(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+
+	var geocoder geocode.Geocoder
+	if *geocodeRows {
+		geocoderConfig := cfg.GeocoderConfig()
+		geocoderConfig.CacheDB = db
+		if geocoder, err = geocode.New(geocoderConfig); err != nil {
+			log.Fatal("Failed to initialize geocoder:", err)
+		}
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal("Failed to open file:", err)
+	}
+	defer f.Close()
+
+	table, err := abaimport.ReadTable(f, *file)
+	if err != nil {
+		log.Fatal("Failed to read file:", err)
+	}
+
+	report, err := abaimport.NewService(db, geocoder).Import(context.Background(), table, abaimport.Options{
+		Mapping:     columns,
+		Mode:        *mode,
+		DryRun:      *dryRun,
+		SkipInvalid: *skipInvalid,
+	})
+	if err != nil {
+		log.Fatal("Import failed:", err)
+	}
+
+	out, _ := json.MarshalIndent(report, "", "  ")
+	os.Stdout.Write(append(out, '\n'))
+	if !report.DryRun && !report.Committed && report.Errors > 0 {
+		log.Printf("Nothing was imported: fix the %d rows with errors or pass -skip-invalid", report.Errors)
+		os.Exit(1)
+	}
+}
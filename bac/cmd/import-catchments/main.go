@@ -0,0 +1,75 @@
+// Command import-catchments loads regional center service areas, or ZIP code
+// areas, from a GeoJSON file.
+//
+//	go run ./cmd/import-catchments -file catchments.geojson -replace
+//	go run ./cmd/import-catchments -type zip -file tl_2020_us_zcta520_ca.geojson
+package main
+
+import (
+	"bac/internal/catchment"
+	"bac/internal/config"
+	"bac/internal/database"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	file := flag.String("file", "", "GeoJSON FeatureCollection to import")
+	kind := flag.String("type", "catchments", "what the file contains: catchments or zip")
+	centerProperty := flag.String("center-property", "regional_center", "feature property holding the regional center id or name")
+	nameProperty := flag.String("name-property", "name", "feature property holding the area name")
+	zipProperty := flag.String("zip-property", "", "feature property holding ZIP codes (defaults: zip_codes for catchments, ZCTA fields for zip)")
+	replace := flag.Bool("replace", false, "replace existing areas of every center in the file")
+	dryRun := flag.Bool("dry-run", false, "validate the file and roll back")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+	db, err := database.Initialize(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal("Failed to open file:", err)
+	}
+	defer f.Close()
+
+	service := catchment.NewService(db)
+	var report *catchment.ImportReport
+	switch *kind {
+	case "catchments":
+		report, err = service.ImportCatchments(f, catchment.ImportOptions{
+			CenterProperty: *centerProperty,
+			NameProperty:   *nameProperty,
+			ZipProperty:    *zipProperty,
+			Source:         filepath.Base(*file),
+			Replace:        *replace,
+			DryRun:         *dryRun,
+		})
+	case "zip":
+		report, err = service.ImportZipAreas(f, catchment.ZipImportOptions{
+			ZipProperty: *zipProperty,
+			DryRun:      *dryRun,
+		})
+	default:
+		log.Fatalf("Unknown -type %q, expected catchments or zip", *kind)
+	}
+	if err != nil {
+		log.Fatal("Import failed:", err)
+	}
+
+	out, _ := json.MarshalIndent(report, "", "  ")
+	os.Stdout.Write(append(out, '\n'))
+}
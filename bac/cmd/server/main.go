@@ -2,9 +2,14 @@ package main
 
 import (
 	"bac/internal/api"
+	"bac/internal/auth"
 	"bac/internal/config"
 	"bac/internal/database"
+	"bac/internal/geocode"
+	"bac/internal/mail"
 	"bac/internal/utils"
+	"bac/internal/trash"
+	"bac/internal/verification"
 	"bac/internal/models"
 	"context"
 	"os"
@@ -46,9 +51,59 @@ func main() {
     logger.Fatal("Failed to run auto-migrations:", err)
 	}
 
+	// Regional centers whose text coordinates could not be converted are left off the map
+	var unparsedCenters int64
+	if err := db.Table("regional_center_coordinate_errors").Count(&unparsedCenters).Error; err != nil {
+		logger.Error("Failed to check regional center coordinates:", err)
+	} else if unparsedCenters > 0 {
+		logger.Error("Regional centers with unparsable coordinates (see regional_center_coordinate_errors):", unparsedCenters)
+	}
+
+	// Seed the default roles and permissions
+	roleService := auth.NewRoleService(db)
+	if err := roleService.SeedDefaults(); err != nil {
+		logger.Fatal("Failed to seed roles and permissions:", err)
+	}
+	if cfg.AdminEmail != "" {
+		if err := roleService.BootstrapAdmin(cfg.AdminEmail); err != nil {
+			logger.Fatal("Failed to grant admin role:", err)
+		}
+	}
+
+
+	// Initialize mailer
+	mailer, err := mail.New(mail.Config{
+		Driver:   cfg.MailDriver,
+		From:     cfg.MailFrom,
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		LogDir:   cfg.MailLogDir,
+	})
+	if err != nil {
+		logger.Fatal("Failed to initialize mailer:", err)
+	}
+
+	// Initialize geocoder; it is optional and nil when GEOCODER=none
+	geocoderConfig := cfg.GeocoderConfig()
+	geocoderConfig.CacheDB = db
+	geocoder, err := geocode.New(geocoderConfig)
+	if err != nil {
+		logger.Fatal("Failed to initialize geocoder:", err)
+	}
+
+	// Flag stale directory records in the background until shutdown
+	verifier := verification.New(db, cfg.VerificationConfig())
+	scanCtx, stopScans := context.WithCancel(context.Background())
+	defer stopScans()
+	verifier.Start(scanCtx, cfg.VerificationInterval)
+
+	// Purge records that have been in the trash past the retention period
+	trash.NewService(db, cfg.TrashRetention).Start(scanCtx, cfg.TrashPurgeInterval)
 
 	// Initialize server
-	server := api.NewServer(db, cfg)
+	server := api.NewServer(db, cfg, mailer, geocoder, verifier)
 
 	// Setup graceful shutdown
 	stop := make(chan os.Signal, 1)
@@ -62,6 +117,7 @@ func main() {
 
 	<-stop
 	logger.Info("Shutting down server...")
+	stopScans()
 
 	// Gracefully shut down the server
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
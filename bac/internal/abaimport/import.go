@@ -0,0 +1,496 @@
+// Package abaimport loads ABA centers in bulk from the outreach team's spreadsheets
+package abaimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"bac/internal/audit"
+	"bac/internal/geocode"
+	"bac/internal/insurance"
+	"bac/internal/models"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidImport wraps problems with the file or options, as opposed to database failures
+var ErrInvalidImport = errors.New("invalid import")
+
+// Import modes
+const (
+	ModeUpsert = "upsert"
+	ModeInsert = "insert"
+)
+
+// Row statuses in the report
+const (
+	StatusCreated = "created"
+	StatusUpdated = "updated"
+	StatusSkipped = "skipped"
+	StatusError   = "error"
+)
+
+// Options controls an import
+type Options struct {
+	// Mapping maps ABACenterRequest JSON field names to column headers in the
+	// file. Fields left out are matched to headers by name.
+	Mapping map[string]string
+	// Mode is ModeUpsert (update matching centers) or ModeInsert (skip them)
+	Mode   string
+	DryRun bool
+	// SkipInvalid commits the valid rows even when others have errors;
+	// otherwise any row error leaves the database untouched
+	SkipInvalid bool
+	ActorID     *int
+}
+
+// RowResult is what happened to one spreadsheet row
+type RowResult struct {
+	// Row is the 1-based row number in the file, counting the header
+	Row     int      `json:"row"`
+	Status  string   `json:"status"`
+	ID      string   `json:"id,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Report summarises an import
+type Report struct {
+	Mode      string `json:"mode"`
+	DryRun    bool   `json:"dryRun"`
+	Committed bool   `json:"committed"`
+	Rows      int    `json:"rows"`
+	Created   int    `json:"created"`
+	Updated   int    `json:"updated"`
+	Skipped   int    `json:"skipped"`
+	Errors    int    `json:"errors"`
+	// Columns shows which header each field was read from
+	Columns map[string]string `json:"columns"`
+	Results []RowResult       `json:"results"`
+}
+
+// Service imports ABA centers
+type Service struct {
+	db *gorm.DB
+	// geocoder is optional; rows without coordinates are geocoded when set
+	geocoder geocode.Geocoder
+}
+
+// NewService creates a new Service instance
+func NewService(db *gorm.DB, geocoder geocode.Geocoder) *Service {
+	return &Service{db: db, geocoder: geocoder}
+}
+
+// field is an ABACenterRequest field that can be read from a column
+type field struct {
+	name    string
+	aliases []string
+	set     func(req *models.ABACenterRequest, value string) error
+}
+
+func text(set func(req *models.ABACenterRequest, value string)) func(*models.ABACenterRequest, string) error {
+	return func(req *models.ABACenterRequest, value string) error {
+		set(req, value)
+		return nil
+	}
+}
+
+func coordinate(set func(req *models.ABACenterRequest, value *float64)) func(*models.ABACenterRequest, string) error {
+	return func(req *models.ABACenterRequest, value string) error {
+		if value == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		set(req, &f)
+		return nil
+	}
+}
+
+var fields = []field{
+	{"name", []string{"centername", "providername", "agency"}, text(func(r *models.ABACenterRequest, v string) { r.Name = v })},
+	{"street", []string{"address", "streetaddress", "address1"}, text(func(r *models.ABACenterRequest, v string) { r.Street = v })},
+	{"city", nil, text(func(r *models.ABACenterRequest, v string) { r.City = v })},
+	{"zip", []string{"zipcode", "postalcode"}, text(func(r *models.ABACenterRequest, v string) { r.Zip = v })},
+	{"phone", []string{"phonenumber", "telephone"}, text(func(r *models.ABACenterRequest, v string) { r.Phone = v })},
+	{"serviceType", []string{"services", "type"}, text(func(r *models.ABACenterRequest, v string) { r.ServiceType = v })},
+	{"waitlistAvailability", []string{"waitlist"}, text(func(r *models.ABACenterRequest, v string) { r.WaitlistAvailability = v })},
+	{"waitlistNotes", nil, text(func(r *models.ABACenterRequest, v string) { r.WaitlistNotes = v })},
+	{"dxVerification", []string{"diagnosisverification"}, text(func(r *models.ABACenterRequest, v string) { r.DxVerification = v })},
+	{"insuranceAccepted", []string{"insurance"}, text(func(r *models.ABACenterRequest, v string) { r.InsuranceAccepted = v })},
+	{"mediCalPlans", []string{"medical", "medicalplan"}, text(func(r *models.ABACenterRequest, v string) { r.MediCalPlans = v })},
+	{"notes", []string{"comments"}, text(func(r *models.ABACenterRequest, v string) { r.Notes = v })},
+	{"latitude", []string{"lat"}, coordinate(func(r *models.ABACenterRequest, v *float64) { r.Latitude = v })},
+	{"longitude", []string{"lng", "lon", "long"}, coordinate(func(r *models.ABACenterRequest, v *float64) { r.Longitude = v })},
+}
+
+var (
+	nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+	zipPattern      = regexp.MustCompile(`^\d{5}(-\d{4})?$`)
+)
+
+// headerKey makes "Service Type", "service_type" and "serviceType" compare equal
+func headerKey(header string) string {
+	return nonAlphanumeric.ReplaceAllString(strings.ToLower(header), "")
+}
+
+// resolveColumns maps each field to a column index using the explicit mapping
+// first and header names second
+func resolveColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	byKey := map[string]int{}
+	for i, h := range header {
+		if key := headerKey(h); key != "" {
+			if _, seen := byKey[key]; !seen {
+				byKey[key] = i
+			}
+		}
+	}
+
+	known := map[string]bool{}
+	for _, f := range fields {
+		known[f.name] = true
+	}
+	for name := range mapping {
+		if !known[name] {
+			return nil, fmt.Errorf("%w: mapping names unknown field %q", ErrInvalidImport, name)
+		}
+	}
+
+	columns := map[string]int{}
+	for _, f := range fields {
+		if header, ok := mapping[f.name]; ok {
+			i, found := byKey[headerKey(header)]
+			if !found {
+				return nil, fmt.Errorf("%w: mapped column %q for %s is not in the file", ErrInvalidImport, header, f.name)
+			}
+			columns[f.name] = i
+			continue
+		}
+		for _, key := range append([]string{headerKey(f.name)}, f.aliases...) {
+			if i, found := byKey[key]; found {
+				columns[f.name] = i
+				break
+			}
+		}
+	}
+	return columns, nil
+}
+
+// DedupKey identifies a center by normalized name, address and phone digits
+func DedupKey(name, street, city, zip, phone string) string {
+	digits := nonDigits.ReplaceAllString(phone, "")
+	if len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	if len(zip) > 5 {
+		zip = zip[:5]
+	}
+	return strings.Join([]string{
+		headerKey(name),
+		geocode.NormalizeAddress(street + " " + city + " " + zip),
+		digits,
+	}, "|")
+}
+
+var nonDigits = regexp.MustCompile(`\D`)
+
+// validate applies the request's binding rules plus checks the form can't express
+func validate(req *models.ABACenterRequest) []string {
+	problems := []string{}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		var verrs validator.ValidationErrors
+		if errors.As(err, &verrs) {
+			for _, fe := range verrs {
+				if fe.Tag() == "required" {
+					problems = append(problems, fe.Field()+" is required")
+				} else {
+					problems = append(problems, fmt.Sprintf("%s must satisfy %s=%s", fe.Field(), fe.Tag(), fe.Param()))
+				}
+			}
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+	if req.Zip != "" && !zipPattern.MatchString(req.Zip) {
+		problems = append(problems, fmt.Sprintf("Zip %q is not a 5 digit ZIP code", req.Zip))
+	}
+	if req.Phone != "" && len(nonDigits.ReplaceAllString(req.Phone, "")) < 10 {
+		problems = append(problems, fmt.Sprintf("Phone %q needs at least 10 digits", req.Phone))
+	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		problems = append(problems, "Latitude and Longitude must be given together")
+	}
+	return problems
+}
+
+// parseRow reads a row into a request and lists what is wrong with it
+func parseRow(values []string, columns map[string]int) (models.ABACenterRequest, []string) {
+	var req models.ABACenterRequest
+	problems := []string{}
+	for _, f := range fields {
+		col, ok := columns[f.name]
+		if !ok || col >= len(values) {
+			continue
+		}
+		if err := f.set(&req, strings.TrimSpace(values[col])); err != nil {
+			problems = append(problems, f.name+": "+err.Error())
+		}
+	}
+	return req, append(problems, validate(&req)...)
+}
+
+// pendingRow is a valid row waiting to be written
+type pendingRow struct {
+	result *RowResult
+	req    models.ABACenterRequest
+	// existing is the matching center in upsert mode
+	existing *models.ABACenter
+}
+
+// Import validates every row, then writes the valid ones in a single
+// transaction. A dry run reports the same outcome and rolls back.
+func (s *Service) Import(ctx context.Context, table [][]string, opts Options) (*Report, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeUpsert
+	}
+	if opts.Mode != ModeUpsert && opts.Mode != ModeInsert {
+		return nil, fmt.Errorf("%w: mode must be %s or %s", ErrInvalidImport, ModeUpsert, ModeInsert)
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+	}
+
+	columns, err := resolveColumns(table[0], opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	for _, required := range []string{"name", "street", "city", "zip", "phone", "serviceType"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: no column found for %s; add it to the mapping", ErrInvalidImport, required)
+		}
+	}
+
+	// Results never grows past the row count, so pointers into it stay valid
+	report := &Report{Mode: opts.Mode, DryRun: opts.DryRun, Columns: map[string]string{},
+		Results: make([]RowResult, 0, len(table)-1)}
+	for name, i := range columns {
+		report.Columns[name] = table[0][i]
+	}
+
+	// Existing centers by dedup key
+	var existing []models.ABACenter
+	if err := s.db.Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	existingByKey := map[string]*models.ABACenter{}
+	for i := range existing {
+		c := &existing[i]
+		existingByKey[DedupKey(c.Name, c.Street, c.City, c.Zip, c.Phone)] = c
+	}
+
+	seen := map[string]int{}
+	pending := []pendingRow{}
+	for i, values := range table[1:] {
+		rowNumber := i + 2
+		if blank(values) {
+			continue
+		}
+		report.Rows++
+		report.Results = append(report.Results, RowResult{Row: rowNumber})
+		result := &report.Results[len(report.Results)-1]
+
+		req, problems := parseRow(values, columns)
+		result.Name = req.Name
+		if len(problems) > 0 {
+			result.Status, result.Errors = StatusError, problems
+			continue
+		}
+
+		key := DedupKey(req.Name, req.Street, req.City, req.Zip, req.Phone)
+		if first, dup := seen[key]; dup {
+			result.Status, result.Message = StatusSkipped, fmt.Sprintf("duplicate of row %d", first)
+			continue
+		}
+		seen[key] = rowNumber
+
+		match := existingByKey[key]
+		if match != nil {
+			result.ID = match.ID.String()
+			if opts.Mode == ModeInsert {
+				result.Status, result.Message = StatusSkipped, "already exists"
+				continue
+			}
+			if unchanged(match, req) {
+				result.Status, result.Message = StatusSkipped, "no changes"
+				continue
+			}
+			result.Status = StatusUpdated
+		} else {
+			result.Status = StatusCreated
+		}
+		pending = append(pending, pendingRow{result: result, req: req, existing: match})
+	}
+
+	for _, r := range report.Results {
+		switch r.Status {
+		case StatusCreated:
+			report.Created++
+		case StatusUpdated:
+			report.Updated++
+		case StatusSkipped:
+			report.Skipped++
+		case StatusError:
+			report.Errors++
+		}
+	}
+
+	if opts.DryRun || (report.Errors > 0 && !opts.SkipInvalid) || len(pending) == 0 {
+		return report, nil
+	}
+
+	// Geocode before the transaction so slow lookups don't hold it open
+	centers := make([]models.ABACenter, len(pending))
+	for i, p := range pending {
+		centers[i] = s.toCenter(ctx, p, opts.ActorID)
+	}
+
+	// Carrier and plan links follow the imported text, queueing unknown names for review
+	links := insurance.NewService(s.db)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i, p := range pending {
+			center := &centers[i]
+			if p.existing == nil {
+				if err := tx.Create(center).Error; err != nil {
+					return fmt.Errorf("row %d: %w", p.result.Row, err)
+				}
+				p.result.ID = center.ID.String()
+				if err := links.Sync(tx, center.ID, p.req, true); err != nil {
+					return fmt.Errorf("row %d: %w", p.result.Row, err)
+				}
+				if err := recordChange(tx, audit.OpCreate, opts.ActorID, nil, center.ID); err != nil {
+					return fmt.Errorf("row %d: %w", p.result.Row, err)
+				}
+				continue
+			}
+			before := *p.existing
+			previous, err := audit.Load(tx, audit.EntityABACenter, p.existing.ID.String())
+			if err != nil {
+				return fmt.Errorf("row %d: %w", p.result.Row, err)
+			}
+			// Name the columns so values cleared in the file are written too
+			err = tx.Model(p.existing).
+				Select("name", "street", "city", "zip", "phone", "service_type", "waitlist_availability",
+					"waitlist_notes", "dx_verification", "insurance_accepted", "medi_cal_plans", "notes",
+					"latitude", "longitude", "geocoded_at", "updated_by").
+				Updates(center).Error
+			if err != nil {
+				return fmt.Errorf("row %d: %w", p.result.Row, err)
+			}
+			textChanged := before.InsuranceAccepted != p.req.InsuranceAccepted || before.MediCalPlans != p.req.MediCalPlans
+			if err := links.Sync(tx, p.existing.ID, p.req, textChanged); err != nil {
+				return fmt.Errorf("row %d: %w", p.result.Row, err)
+			}
+			if err := recordChange(tx, audit.OpUpdate, opts.ActorID, previous, p.existing.ID); err != nil {
+				return fmt.Errorf("row %d: %w", p.result.Row, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.Committed = true
+	log.Printf("ABA center import: %d rows, %d created, %d updated, %d skipped, %d errors",
+		report.Rows, report.Created, report.Updated, report.Skipped, report.Errors)
+	return report, nil
+}
+
+// recordChange adds an imported create or update to the audit log
+func recordChange(tx *gorm.DB, op string, actorID *int, before interface{}, id uuid.UUID) error {
+	after, err := audit.Load(tx, audit.EntityABACenter, id.String())
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, audit.Change{EntityType: audit.EntityABACenter, EntityID: id.String(), Operation: op, ActorID: actorID, Before: before, After: after})
+}
+
+// toCenter builds the row to write, keeping or looking up coordinates
+func (s *Service) toCenter(ctx context.Context, p pendingRow, actorID *int) models.ABACenter {
+	req := p.req
+	center := models.ABACenter{
+		Name:                 req.Name,
+		Street:               req.Street,
+		City:                 req.City,
+		Zip:                  req.Zip,
+		Phone:                req.Phone,
+		ServiceType:          req.ServiceType,
+		WaitlistAvailability: req.WaitlistAvailability,
+		WaitlistNotes:        req.WaitlistNotes,
+		DxVerification:       req.DxVerification,
+		InsuranceAccepted:    req.InsuranceAccepted,
+		MediCalPlans:         req.MediCalPlans,
+		Notes:                req.Notes,
+		UpdatedBy:            actorID,
+	}
+	if p.existing == nil {
+		center.CreatedBy = actorID
+	}
+
+	now := time.Now()
+	switch {
+	case req.Latitude != nil && req.Longitude != nil:
+		center.Latitude, center.Longitude, center.GeocodedAt = req.Latitude, req.Longitude, &now
+	case p.existing != nil && p.existing.Latitude != nil &&
+		p.existing.Street == req.Street && p.existing.City == req.City && p.existing.Zip == req.Zip:
+		// Same address, so the existing pin is still right
+		center.Latitude, center.Longitude, center.GeocodedAt = p.existing.Latitude, p.existing.Longitude, p.existing.GeocodedAt
+	case s.geocoder != nil:
+		lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		result, err := s.geocoder.Geocode(lookupCtx, center.Address())
+		if err != nil {
+			log.Printf("Failed to geocode ABA center %q (%s): %v", center.Name, center.Address(), err)
+			break
+		}
+		center.Latitude, center.Longitude, center.GeocodedAt = &result.Latitude, &result.Longitude, &now
+	}
+	return center
+}
+
+// unchanged reports whether applying req would leave the center as it is
+func unchanged(c *models.ABACenter, req models.ABACenterRequest) bool {
+	same := c.Name == req.Name && c.Street == req.Street && c.City == req.City && c.Zip == req.Zip &&
+		c.Phone == req.Phone && c.ServiceType == req.ServiceType &&
+		c.WaitlistAvailability == req.WaitlistAvailability && c.WaitlistNotes == req.WaitlistNotes &&
+		c.DxVerification == req.DxVerification && c.InsuranceAccepted == req.InsuranceAccepted &&
+		c.MediCalPlans == req.MediCalPlans && c.Notes == req.Notes
+	if !same {
+		return false
+	}
+	if req.Latitude == nil {
+		return true
+	}
+	return c.Latitude != nil && c.Longitude != nil && *c.Latitude == *req.Latitude && *c.Longitude == *req.Longitude
+}
+
+func blank(values []string) bool {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
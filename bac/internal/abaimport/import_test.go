@@ -0,0 +1,185 @@
+package abaimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"bac/internal/testutil"
+)
+
+var testHeader = []string{"Center Name", "Address", "City", "Zip Code", "Phone", "Service Type", "Lat", "Lng"}
+
+// testRow returns a valid row for testHeader with the given columns replaced
+func testRow(changes map[int]string) []string {
+	row := []string{"Bright Steps", "1 Main St", "Fresno", "93701", "(559) 555-0100", "Clinic", "", ""}
+	for i, v := range changes {
+		row[i] = v
+	}
+	return row
+}
+
+func TestResolveColumns(t *testing.T) {
+	columns, err := resolveColumns(testHeader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"name": 0, "street": 1, "city": 2, "zip": 3, "phone": 4, "serviceType": 5, "latitude": 6, "longitude": 7}
+	for name, i := range want {
+		if columns[name] != i {
+			t.Errorf("%s read from column %d, want %d", name, columns[name], i)
+		}
+	}
+
+	// An explicit mapping wins over header names
+	columns, err = resolveColumns([]string{"Agency", "Display Name"}, map[string]string{"name": "display_name"})
+	if err != nil || columns["name"] != 1 {
+		t.Errorf("mapped name read from column %d (%v), want 1", columns["name"], err)
+	}
+
+	for _, mapping := range []map[string]string{{"website": "URL"}, {"name": "Missing"}} {
+		if _, err := resolveColumns(testHeader, mapping); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("mapping %v: got %v, want ErrInvalidImport", mapping, err)
+		}
+	}
+}
+
+func TestImportRequiresColumns(t *testing.T) {
+	// Header problems are reported before the database is touched
+	service := NewService(nil, nil)
+	tests := []struct {
+		name    string
+		header  []string
+		mapping map[string]string
+		want    string
+	}{
+		{"missing phone", []string{"Name", "Street", "City", "Zip", "Service Type"}, nil, "phone"},
+		{"missing several", []string{"Name", "Notes"}, nil, "street"},
+		{"unknown mapped column", testHeader, map[string]string{"zip": "Postcode"}, "Postcode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Import(context.Background(), [][]string{tt.header}, Options{Mapping: tt.mapping})
+			if !errors.Is(err, ErrInvalidImport) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want ErrInvalidImport naming %s", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := service.Import(context.Background(), nil, Options{}); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("empty file: got %v, want ErrInvalidImport", err)
+	}
+	if _, err := service.Import(context.Background(), [][]string{testHeader}, Options{Mode: "replace"}); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("unknown mode: got %v, want ErrInvalidImport", err)
+	}
+}
+
+func TestParseRow(t *testing.T) {
+	columns, err := resolveColumns(testHeader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		row     []string
+		problem string
+	}{
+		{"valid", testRow(nil), ""},
+		{"valid with coordinates", testRow(map[int]string{6: "36.7378", 7: "-119.7871"}), ""},
+		{"ZIP+4", testRow(map[int]string{3: "93701-1234"}), ""},
+		{"short row", testRow(nil)[:6], ""},
+		{"coordinate not a number", testRow(map[int]string{6: "36.7N", 7: "-119.7871"}), `latitude: "36.7N" is not a number`},
+		{"latitude out of range", testRow(map[int]string{6: "95", 7: "-119.7871"}), "Latitude must satisfy max=90"},
+		{"longitude out of range", testRow(map[int]string{6: "36.7378", 7: "-190"}), "Longitude must satisfy min=-180"},
+		{"latitude alone", testRow(map[int]string{6: "36.7378"}), "Latitude and Longitude must be given together"},
+		{"blank required field", testRow(map[int]string{0: "  "}), "Name is required"},
+		{"bad ZIP", testRow(map[int]string{3: "9370"}), `Zip "9370" is not a 5 digit ZIP code`},
+		{"short phone", testRow(map[int]string{4: "555-0100"}), `Phone "555-0100" needs at least 10 digits`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, problems := parseRow(tt.row, columns)
+			if tt.problem == "" {
+				if len(problems) > 0 {
+					t.Errorf("unexpected problems %q", problems)
+				}
+				if req.Name != "Bright Steps" || req.Phone != "(559) 555-0100" {
+					t.Errorf("parsed %+v", req)
+				}
+				return
+			}
+			for _, p := range problems {
+				if p == tt.problem {
+					return
+				}
+			}
+			t.Errorf("problems %q, want %q", problems, tt.problem)
+		})
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	key := DedupKey("Bright Steps", "1 Main Street", "Fresno", "93701", "(559) 555-0100")
+	same := []struct{ name, street, city, zip, phone string }{
+		{"BRIGHT STEPS", "1 Main Street", "Fresno", "93701", "(559) 555-0100"},
+		{"Bright-Steps", "1 main st", "FRESNO", "93701-1234", "559.555.0100"},
+		{"Bright Steps", "1 Main St.", "Fresno", "93701", "+1 559 555 0100"},
+	}
+	for _, c := range same {
+		if got := DedupKey(c.name, c.street, c.city, c.zip, c.phone); got != key {
+			t.Errorf("%+v: key %q, want %q", c, got, key)
+		}
+	}
+	if DedupKey("Bright Steps", "2 Main Street", "Fresno", "93701", "(559) 555-0100") == key {
+		t.Error("a different street has the same key")
+	}
+}
+
+func TestImportDryRunReportsRows(t *testing.T) {
+	service := NewService(testutil.Tx(t), nil)
+	name := fmt.Sprintf("Import Test %d", time.Now().UnixNano())
+
+	table := [][]string{
+		testHeader,
+		testRow(map[int]string{0: name}),
+		{"", "", "", "", "", "", "", ""},
+		testRow(map[int]string{0: strings.ToUpper(name), 4: "559.555.0100"}),
+		testRow(map[int]string{0: name + " North", 6: "north", 7: "-119.7871"}),
+		testRow(map[int]string{0: name + " South", 5: ""}),
+	}
+	report, err := service.Import(context.Background(), table, Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blank rows are skipped silently but keep their row numbers
+	want := []struct {
+		row    int
+		status string
+	}{{2, StatusCreated}, {4, StatusSkipped}, {5, StatusError}, {6, StatusError}}
+	if len(report.Results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(report.Results), len(want), report.Results)
+	}
+	for i, w := range want {
+		if r := report.Results[i]; r.Row != w.row || r.Status != w.status {
+			t.Errorf("result %d = row %d %s, want row %d %s", i, r.Row, r.Status, w.row, w.status)
+		}
+	}
+	if msg := report.Results[1].Message; msg != "duplicate of row 2" {
+		t.Errorf("duplicate message = %q", msg)
+	}
+	if report.Rows != 4 || report.Created != 1 || report.Skipped != 1 || report.Errors != 2 || report.Committed {
+		t.Errorf("report counts %+v", report)
+	}
+
+	var count int64
+	if err := service.db.Table("aba_centers").Where("name ILIKE ?", name+"%").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("dry run wrote %d centers", count)
+	}
+}
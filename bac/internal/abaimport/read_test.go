@@ -0,0 +1,115 @@
+package abaimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTableCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][]string
+	}{
+		{"plain", "Name,City\nBright Steps,Fresno\n", [][]string{{"Name", "City"}, {"Bright Steps", "Fresno"}}},
+		{"byte order mark", "\xef\xbb\xbfName,City\nBright Steps,Fresno\n", [][]string{{"Name", "City"}, {"Bright Steps", "Fresno"}}},
+		{"quoted comma and leading space", "Name, City\n\"Steps, Inc\", Fresno\n", [][]string{{"Name", "City"}, {"Steps, Inc", "Fresno"}}},
+		{"ragged rows", "Name,City,Zip\nBright Steps\n", [][]string{{"Name", "City", "Zip"}, {"Bright Steps"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadTable(strings.NewReader(tt.data), "centers.csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTableRejectsBadCSV(t *testing.T) {
+	if _, err := ReadTable(strings.NewReader("Name\n\"unterminated\n"), "centers.csv"); err == nil {
+		t.Error("an unterminated quote was accepted")
+	}
+}
+
+// testXLSX builds a one sheet workbook holding a shared string, an inline
+// string, a number and a boolean, with column B left empty in the second row
+func testXLSX(t *testing.T) []byte {
+	t.Helper()
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+			xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Centers" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>Name</t></si><si><r><t>Bright </t></r><r><t>Steps</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>Latitude</t></is></c><c r="C1" t="inlineStr"><is><t>Open</t></is></c></row>
+			<row r="2"><c r="A2" t="s"><v>1</v></c><c r="C2" t="b"><v>1</v></c></row>
+			<row r="3"><c r="B3"><v>36.7378</v></c></row>
+			</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, body := range parts {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadTableXLSX(t *testing.T) {
+	want := [][]string{
+		{"Name", "Latitude", "Open"},
+		{"Bright Steps", "", "TRUE"},
+		{"", "36.7378"},
+	}
+	// The format is sniffed when the name doesn't say
+	for _, filename := range []string{"centers.xlsx", "upload"} {
+		got, err := ReadTable(bytes.NewReader(testXLSX(t)), filename)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", filename, got, want)
+		}
+	}
+}
+
+func TestReadTableRejectsBadXLSX(t *testing.T) {
+	if _, err := ReadTable(strings.NewReader("not a zip"), "centers.xlsx"); err == nil {
+		t.Error("a file that isn't a zip was accepted")
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	if _, err := archive.Create("xl/styles.xml"); err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+	if _, err := ReadTable(&buf, "centers.xlsx"); err == nil || !strings.Contains(err.Error(), "xl/workbook.xml") {
+		t.Errorf("workbook without xl/workbook.xml: got %v", err)
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "C7": 2, "Z2": 25, "AA10": 26, "AB12": 27, "": -1} {
+		if got := columnIndex(ref); got != want {
+			t.Errorf("columnIndex(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bac/internal/auth"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes registers the role and permission administration routes
+func (s *Server) RegisterAdminRoutes() {
+	roleService := auth.NewRoleService(s.db)
+
+	admin := s.router.Group("/api")
+	admin.Use(s.middleware.AuthMiddleware, s.middleware.RequireUserSession, s.middleware.RequirePermission("manage:roles"))
+	{
+		admin.GET("/roles", func(c *gin.Context) {
+			roles, err := roleService.ListRoles()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get roles"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"roles": roles})
+		})
+
+		admin.GET("/roles/:id", func(c *gin.Context) {
+			id, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+			role, err := roleService.GetRole(id)
+			if err != nil {
+				respondRoleError(c, err, "Failed to get role")
+				return
+			}
+			c.JSON(http.StatusOK, role)
+		})
+
+		admin.POST("/roles", func(c *gin.Context) {
+			var req auth.RoleRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			role, err := roleService.CreateRole(req)
+			if err != nil {
+				respondRoleError(c, err, "Failed to create role")
+				return
+			}
+			c.JSON(http.StatusCreated, role)
+		})
+
+		admin.PUT("/roles/:id", func(c *gin.Context) {
+			id, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+			var req auth.RoleRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			role, err := roleService.UpdateRole(id, req)
+			if err != nil {
+				respondRoleError(c, err, "Failed to update role")
+				return
+			}
+			c.JSON(http.StatusOK, role)
+		})
+
+		admin.DELETE("/roles/:id", func(c *gin.Context) {
+			id, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+			if err := roleService.DeleteRole(id); err != nil {
+				respondRoleError(c, err, "Failed to delete role")
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+		})
+
+		admin.PUT("/roles/:id/permissions/:permissionId", func(c *gin.Context) {
+			roleID, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+			permissionID, ok := intParam(c, "permissionId")
+			if !ok {
+				return
+			}
+			if err := roleService.AttachPermission(roleID, permissionID); err != nil {
+				respondRoleError(c, err, "Failed to attach permission")
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Permission attached successfully"})
+		})
+
+		admin.DELETE("/roles/:id/permissions/:permissionId", func(c *gin.Context) {
+			roleID, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+			permissionID, ok := intParam(c, "permissionId")
+			if !ok {
+				return
+			}
+			if err := roleService.DetachPermission(roleID, permissionID); err != nil {
+				respondRoleError(c, err, "Failed to detach permission")
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Permission detached successfully"})
+		})
+
+		admin.GET("/permissions", func(c *gin.Context) {
+			permissions, err := roleService.ListPermissions()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get permissions"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+		})
+
+		admin.POST("/permissions", func(c *gin.Context) {
+			var req auth.PermissionRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			permission, err := roleService.CreatePermission(req)
+			if err != nil {
+				respondRoleError(c, err, "Failed to create permission")
+				return
+			}
+			c.JSON(http.StatusCreated, permission)
+		})
+
+		admin.DELETE("/permissions/:id", func(c *gin.Context) {
+			id, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+			if err := roleService.DeletePermission(id); err != nil {
+				respondRoleError(c, err, "Failed to delete permission")
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Permission deleted successfully"})
+		})
+
+		admin.GET("/users/:id/roles", func(c *gin.Context) {
+			userID, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+			roles, err := roleService.GetUserRoles(userID)
+			if err != nil {
+				respondRoleError(c, err, "Failed to get user roles")
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"roles": roles})
+		})
+
+		admin.PUT("/users/:id/roles/:roleId", func(c *gin.Context) {
+			userID, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+			roleID, ok := intParam(c, "roleId")
+			if !ok {
+				return
+			}
+			if err := roleService.GrantRole(userID, roleID); err != nil {
+				respondRoleError(c, err, "Failed to grant role")
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Role granted successfully"})
+		})
+
+		admin.DELETE("/users/:id/roles/:roleId", func(c *gin.Context) {
+			userID, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+			roleID, ok := intParam(c, "roleId")
+			if !ok {
+				return
+			}
+			if err := roleService.RevokeRole(userID, roleID); err != nil {
+				respondRoleError(c, err, "Failed to revoke role")
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Role revoked successfully"})
+		})
+	}
+}
+
+// intParam parses a numeric path parameter, writing a 400 response when it is invalid
+func intParam(c *gin.Context, name string) (int, bool) {
+	value, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return value, true
+}
+
+// respondRoleError maps role service errors onto HTTP status codes
+func respondRoleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, auth.ErrRoleNotFound),
+		errors.Is(err, auth.ErrPermissionNotFound),
+		errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrRoleExists),
+		errors.Is(err, auth.ErrPermissionExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Println(message+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
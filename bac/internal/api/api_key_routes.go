@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bac/internal/auth"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAPIKeyRoutes registers the partner API key management routes
+func (s *Server) RegisterAPIKeyRoutes() {
+	keys := s.router.Group("/api/api-keys")
+	keys.Use(s.middleware.AuthMiddleware, s.middleware.RequireUserSession, s.middleware.RequirePermission("manage:api-keys"))
+	{
+		keys.GET("", func(c *gin.Context) {
+			var owner *int
+			if userID := c.Query("user_id"); userID != "" {
+				id, ok := intQuery(c, "user_id")
+				if !ok {
+					return
+				}
+				owner = &id
+			}
+
+			apiKeys, err := s.apiKeys.List(owner)
+			if err != nil {
+				log.Println("API key list error:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get API keys"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"api_keys": apiKeys})
+		})
+
+		keys.POST("", func(c *gin.Context) {
+			var req auth.APIKeyRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			var creatorID *int
+			if id, ok := c.Get("userID"); ok {
+				if idInt, ok := id.(int); ok {
+					creatorID = &idInt
+				}
+			}
+
+			created, err := s.apiKeys.Create(req, creatorID)
+			if err != nil {
+				if errors.Is(err, auth.ErrInvalidAPIScope) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				log.Println("API key creation error:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+				return
+			}
+
+			// The secret is never retrievable again after this response
+			c.JSON(http.StatusCreated, created)
+		})
+
+		keys.DELETE("/:id", func(c *gin.Context) {
+			id, ok := intParam(c, "id")
+			if !ok {
+				return
+			}
+
+			if err := s.apiKeys.Revoke(id); err != nil {
+				if errors.Is(err, auth.ErrAPIKeyNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+					return
+				}
+				log.Println("API key revocation error:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+		})
+	}
+}
+
+// intQuery parses a numeric query parameter, writing a 400 response when it is invalid
+func intQuery(c *gin.Context, name string) (int, bool) {
+	value, err := strconv.Atoi(c.Query(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return value, true
+}
@@ -2,9 +2,12 @@ package api
 
 import (
 	"bac/internal/auth"
-	
+	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	
@@ -13,7 +16,48 @@ import (
 // RegisterAuthRoutes registers the authentication routes
 func (s *Server) RegisterAuthRoutes() {
 	// Create auth service
-	authService := auth.NewAuthService(s.db, []byte(s.config.JWTSecret))
+	mfaService, err := auth.NewMFAService(s.db, s.tokenConfig(), auth.MFAConfig{
+		Issuer:        s.config.MFAIssuer,
+		EncryptionKey: []byte(s.config.MFAEncryptionKey),
+	})
+	if err != nil {
+		log.Fatal("Failed to initialize MFA service:", err)
+	}
+	authService := auth.NewAuthService(s.db, s.tokenConfig(), s.sessions, mfaService, s.config.RequireEmailVerification)
+	roleService := auth.NewRoleService(s.db)
+	throttler := auth.NewLoginThrottler(s.db, auth.ThrottleConfig{
+		MaxAttemptsPerEmail: s.config.LoginMaxAttemptsPerEmail,
+		MaxAttemptsPerIP:    s.config.LoginMaxAttemptsPerIP,
+		Window:              s.config.LoginAttemptWindow,
+		LockoutBase:         s.config.LoginLockoutBase,
+		LockoutMax:          s.config.LoginLockoutMax,
+	})
+	// Every verification or reset email requested counts against the address and
+	// the caller, so the endpoints cannot be used to flood an inbox
+	mailThrottler := auth.NewLoginThrottler(s.db, auth.ThrottleConfig{
+		MaxAttemptsPerEmail: s.config.MailRequestsPerEmail,
+		MaxAttemptsPerIP:    s.config.MailRequestsPerIP,
+		Window:              s.config.MailRequestWindow,
+		LockoutBase:         s.config.MailRequestWindow,
+		LockoutMax:          24 * time.Hour,
+		Scope:               "mail",
+	})
+	limitMail := func(c *gin.Context, email string) bool {
+		ip := c.ClientIP()
+		if err := mailThrottler.Check(email, ip); err != nil {
+			respondThrottled(c, err, "Too many emails requested. Try again later.")
+			return false
+		}
+		if err := mailThrottler.RecordFailure(email, ip); err != nil {
+			log.Println("Failed to count mail request:", err)
+		}
+		return true
+	}
+	verificationService := auth.NewVerificationService(s.db, s.mailer, s.sessions, auth.VerificationConfig{
+		FrontendURL:             s.config.FrontendURL,
+		EmailVerificationExpiry: s.config.EmailVerificationExpiry,
+		PasswordResetExpiry:     s.config.PasswordResetExpiry,
+	})
 
 	s.router.POST("/api/register", func(c *gin.Context) {
 		var req auth.RegisterRequest
@@ -26,7 +70,7 @@ func (s *Server) RegisterAuthRoutes() {
 		if err := authService.Register(req); err != nil {
 			log.Println("Registration error:", err)
 
-			if err.Error() == "email already exists" {
+			if errors.Is(err, auth.ErrEmailExists) {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
@@ -35,7 +79,12 @@ func (s *Server) RegisterAuthRoutes() {
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
+		// The account exists either way; a failed email can be re-requested
+		if err := verificationService.RequestEmailVerification(req.Email); err != nil {
+			log.Println("Verification email error:", err)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully. Check your email to verify your address."})
 	})
 
 	s.router.POST("/api/login", func(c *gin.Context) {
@@ -45,22 +94,167 @@ func (s *Server) RegisterAuthRoutes() {
 			return
 		}
 
-		response, err := authService.Login(req)
+		client := clientInfo(c)
+		if err := throttler.Check(req.Email, client.IPAddress); err != nil {
+			respondLocked(c, err)
+			return
+		}
+
+		response, err := authService.Login(req, client)
 		if err != nil {
-			if err.Error() == "invalid credentials" {
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				if err := throttler.RecordFailure(req.Email, client.IPAddress); err != nil {
+					log.Println("Failed to record login failure:", err)
+				}
+				// The failure that trips the lockout is reported as such
+				if err := throttler.Check(req.Email, client.IPAddress); err != nil {
+					respondLocked(c, err)
+					return
+				}
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 				return
 			}
+			if errors.Is(err, auth.ErrEmailNotVerified) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Email address has not been verified"})
+				return
+			}
+			log.Println("Login error:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
 			return
 		}
 
+		// Failures are only cleared once the second factor has been passed too
+		if response.Challenge != nil {
+			c.JSON(http.StatusOK, response.Challenge)
+			return
+		}
+
+		if err := throttler.RecordSuccess(req.Email); err != nil {
+			log.Println("Failed to reset login failures:", err)
+		}
+
+		c.JSON(http.StatusOK, response.Tokens)
+	})
+
+	s.registerMFARoutes(authService, mfaService, throttler)
+	s.registerOIDCRoutes(authService)
+
+	s.router.POST("/api/token/refresh", func(c *gin.Context) {
+		var req auth.RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		response, err := authService.Refresh(req.RefreshToken, clientInfo(c))
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+				return
+			}
+			log.Println("Token refresh error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token refresh failed"})
+			return
+		}
+
 		c.JSON(http.StatusOK, response)
 	})
 
+	s.router.POST("/api/logout", func(c *gin.Context) {
+		var req auth.RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := authService.Logout(req.RefreshToken); err != nil && !errors.Is(err, auth.ErrInvalidRefreshToken) {
+			log.Println("Logout error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	})
+
+	s.router.POST("/api/email/verify/request", func(c *gin.Context) {
+		var req auth.EmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !limitMail(c, req.Email) {
+			return
+		}
+		if err := verificationService.RequestEmailVerification(req.Email); err != nil {
+			log.Println("Verification email error:", err)
+		}
+
+		// Same response whether or not the account exists
+		c.JSON(http.StatusOK, gin.H{"message": "If the account exists and is unverified, a verification email has been sent"})
+	})
+
+	s.router.POST("/api/email/verify/confirm", func(c *gin.Context) {
+		var req auth.TokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := verificationService.ConfirmEmailVerification(req.Token); err != nil {
+			if errors.Is(err, auth.ErrInvalidUserToken) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+				return
+			}
+			log.Println("Email verification error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Email verification failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+	})
+
+	s.router.POST("/api/password/forgot", func(c *gin.Context) {
+		var req auth.EmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !limitMail(c, req.Email) {
+			return
+		}
+		if err := verificationService.RequestPasswordReset(req.Email); err != nil {
+			log.Println("Password reset email error:", err)
+		}
+
+		// Same response whether or not the account exists
+		c.JSON(http.StatusOK, gin.H{"message": "If the account exists, a password reset email has been sent"})
+	})
+
+	s.router.POST("/api/password/reset", func(c *gin.Context) {
+		var req auth.PasswordResetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := verificationService.ResetPassword(req); err != nil {
+			if errors.Is(err, auth.ErrInvalidUserToken) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+				return
+			}
+			log.Println("Password reset error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Password reset failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+	})
+
 	// Protected routes group
 	authGroup := s.router.Group("/api")
-	authGroup.Use(s.middleware.AuthMiddleware)
+	authGroup.Use(s.middleware.AuthMiddleware, s.middleware.RequireUserSession)
 	{
 		authGroup.GET("/me", func(c *gin.Context) {
 			userID, _ := c.Get("userID")
@@ -72,7 +266,17 @@ func (s *Server) RegisterAuthRoutes() {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user profile"})
 					return
 				}
-				c.JSON(http.StatusOK, profile)
+				roles, err := roleService.GetUserRoleNames(userIDInt)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user roles"})
+					return
+				}
+				permissions, _ := c.Get("permissions")
+				c.JSON(http.StatusOK, gin.H{
+					"user":        profile,
+					"roles":       roles,
+					"permissions": permissions,
+				})
 			} else {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID type"})
 			}
@@ -87,6 +291,75 @@ func (s *Server) RegisterAuthRoutes() {
 
 			c.JSON(http.StatusOK, gin.H{"users": users})
 		})
+
+		// Revoke every session of a user so a compromised account is signed out immediately
+		authGroup.DELETE("/users/:id/sessions", s.middleware.RequirePermission("write:users"), func(c *gin.Context) {
+			userID, err := strconv.Atoi(c.Param("id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+				return
+			}
+
+			if err := s.sessions.RevokeUser(userID); err != nil {
+				log.Println("Session revocation error:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully"})
+		})
+
+		authGroup.POST("/users/:id/unlock", s.middleware.RequirePermission("write:users"), func(c *gin.Context) {
+			userID, err := strconv.Atoi(c.Param("id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+				return
+			}
+
+			var actorID *int
+			if id, ok := c.Get("userID"); ok {
+				if idInt, ok := id.(int); ok {
+					actorID = &idInt
+				}
+			}
+
+			if err := throttler.Unlock(userID, actorID); err != nil {
+				if errors.Is(err, auth.ErrUserNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+					return
+				}
+				log.Println("Account unlock error:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"message": "Account unlocked successfully"})
+		})
+	}
+}
+
+// respondLocked writes a 429 with Retry-After for lockouts, or a 500 for lookup failures
+func respondLocked(c *gin.Context, err error) {
+	respondThrottled(c, err, "Too many failed login attempts. Try again later.")
+}
+
+// respondThrottled answers 429 with Retry-After for a *auth.LockedError
+func respondThrottled(c *gin.Context, err error, message string) {
+	var locked *auth.LockedError
+	if errors.As(err, &locked) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": message})
+		return
+	}
+	log.Println("Throttle error:", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Request failed"})
+}
+
+// clientInfo captures the caller's user agent and IP for session bookkeeping
+func clientInfo(c *gin.Context) auth.ClientInfo {
+	return auth.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
 	}
 }
 
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bac/internal/auth"
+	"bac/internal/config"
+	"bac/internal/mail"
+	"bac/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newTestServer builds the full router on db with test settings
+func newTestServer(t *testing.T, db *gorm.DB, cfg config.Config) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg.JWTSecret = "test secret"
+	cfg.JWTExpiry = time.Minute
+	cfg.RefreshTokenExpiry = time.Hour
+	cfg.MFAEncryptionKey = "test key"
+	cfg.LoginMaxAttemptsPerEmail = 5
+	cfg.LoginMaxAttemptsPerIP = 20
+	cfg.LoginAttemptWindow = 15 * time.Minute
+	cfg.LoginLockoutBase = time.Minute
+	cfg.LoginLockoutMax = time.Hour
+	cfg.MailRequestsPerEmail = 3
+	cfg.MailRequestsPerIP = 10
+	cfg.MailRequestWindow = time.Hour
+	return NewServer(db, &cfg, mail.NewLogMailer("test@example.com", ""), nil, nil)
+}
+
+// offlineDB returns a handle that never connects, for routes that don't use it
+func offlineDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// send runs a request through the router, encoding body as JSON when set
+func send(s *Server, method, path string, body interface{}, header http.Header) *httptest.ResponseRecorder {
+	var reader bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reader).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNewRegistrantCannotListUsers(t *testing.T) {
+	tx := testutil.Tx(t)
+	if err := auth.NewRoleService(tx).SeedDefaults(); err != nil {
+		t.Fatalf("seed roles: %v", err)
+	}
+	server := newTestServer(t, tx, config.Config{})
+
+	credentials := gin.H{"email": fmt.Sprintf("registrant-%d@example.com", time.Now().UnixNano()), "password": "correct horse"}
+	register := gin.H{"email": credentials["email"], "password": credentials["password"], "first_name": "New", "last_name": "Registrant"}
+	if w := send(server, http.MethodPost, "/api/register", register, nil); w.Code != http.StatusCreated {
+		t.Fatalf("register: got %d %s", w.Code, w.Body)
+	}
+	w := send(server, http.MethodPost, "/api/login", credentials, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: got %d %s", w.Code, w.Body)
+	}
+	var tokens auth.TokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil || tokens.Token == "" {
+		t.Fatalf("login response %s: %v", w.Body, err)
+	}
+
+	bearer := http.Header{"Authorization": {"Bearer " + tokens.Token}}
+	if w := send(server, http.MethodGet, "/api/me", nil, bearer); w.Code != http.StatusOK {
+		t.Fatalf("me: got %d %s", w.Code, w.Body)
+	}
+	if w := send(server, http.MethodGet, "/api/users", nil, bearer); w.Code != http.StatusForbidden {
+		t.Errorf("list users as a new registrant: got %d, want 403", w.Code)
+	}
+}
+
+func TestOnlyAdminsReadUsers(t *testing.T) {
+	for role, permissions := range auth.DefaultRoles {
+		granted := false
+		for _, p := range permissions {
+			granted = granted || p == "read:users"
+		}
+		if granted != (role == "admin") {
+			t.Errorf("%s has read:users = %v", role, granted)
+		}
+	}
+}
+
+func TestClientIPIgnoresSpoofedHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		remote  string
+		want    string
+	}{
+		{"no trusted proxies", nil, "198.51.100.7:4000", "198.51.100.7"},
+		{"request from a trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.5:4000", "203.0.113.9"},
+		{"request from outside the trusted range", []string{"10.0.0.0/8"}, "198.51.100.7:4000", "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, offlineDB(t), config.Config{TrustedProxies: tt.trusted})
+			server.router.GET("/test/client-ip", func(c *gin.Context) {
+				c.String(http.StatusOK, clientInfo(c).IPAddress)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/test/client-ip", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set("X-Forwarded-For", "203.0.113.9")
+			req.Header.Set("X-Real-IP", "203.0.113.9")
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("client IP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordResetRequestsAreThrottled(t *testing.T) {
+	server := newTestServer(t, testutil.Tx(t), config.Config{})
+	email := gin.H{"email": fmt.Sprintf("flood-%d@example.com", time.Now().UnixNano())}
+
+	for i := 0; i < 3; i++ {
+		if w := send(server, http.MethodPost, "/api/password/forgot", email, nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d %s", i+1, w.Code, w.Body)
+		}
+	}
+	w := send(server, http.MethodPost, "/api/password/forgot", email, nil)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("request over the limit: got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	// Reset requests don't count towards the login lockout
+	login := gin.H{"email": email["email"], "password": "wrong password"}
+	if w := send(server, http.MethodPost, "/api/login", login, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("login after the reset limit: got %d, want 401", w.Code)
+	}
+}
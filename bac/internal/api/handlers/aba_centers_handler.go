@@ -3,20 +3,65 @@
 package handlers
 
 import (
+	"bac/internal/abaimport"
+	"bac/internal/audit"
+	"bac/internal/export"
+	"bac/internal/geocode"
+	"bac/internal/insurance"
 	"bac/internal/models"
+	"bac/internal/waitlist"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
-	"net/http"
 )
 
 // ABACentersHandler handles ABA center-related requests
 type ABACentersHandler struct {
 	DB *gorm.DB
+	// Geocoder is optional; without one centers only get coordinates supplied in the request
+	Geocoder  geocode.Geocoder
+	insurance *insurance.Service
+	waitlist  *waitlist.Service
 }
 
 // This should be in internal/api/handlers/aba_centers_handler.go
-func NewABACenterHandler(db *gorm.DB) *ABACentersHandler {
-    return &ABACentersHandler{DB: db}
+func NewABACenterHandler(db *gorm.DB, geocoder geocode.Geocoder) *ABACentersHandler {
+    return &ABACentersHandler{DB: db, Geocoder: geocoder, insurance: insurance.NewService(db), waitlist: waitlist.NewService(db)}
+}
+
+// locate fills in the center's coordinates, preferring ones supplied in the request.
+// A failed lookup clears them rather than leaving a pin at the old address.
+func (h *ABACentersHandler) locate(c *gin.Context, center *models.ABACenter, input models.ABACenterRequest) {
+	now := time.Now()
+	if input.Latitude != nil && input.Longitude != nil {
+		center.Latitude, center.Longitude, center.GeocodedAt = input.Latitude, input.Longitude, &now
+		return
+	}
+
+	center.Latitude, center.Longitude, center.GeocodedAt = nil, nil, nil
+	if h.Geocoder == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	result, err := h.Geocoder.Geocode(ctx, center.Address())
+	if err != nil {
+		log.Printf("Failed to geocode ABA center %q (%s): %v", center.Name, center.Address(), err)
+		return
+	}
+	center.Latitude, center.Longitude, center.GeocodedAt = &result.Latitude, &result.Longitude, &now
 }
 
 // CreateABACenter creates a new ABA therapy center
@@ -41,13 +86,35 @@ func (h *ABACentersHandler) CreateABACenter(c *gin.Context) {
 		InsuranceAccepted:    input.InsuranceAccepted,
 		MediCalPlans:         input.MediCalPlans,
 		Notes:                input.Notes,
+		CreatedBy:            actorID(c),
+		UpdatedBy:            actorID(c),
 	}
-
-	// Create record in database
-	if result := h.DB.Create(&center); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	h.locate(c, &center, input)
+
+	// Create record in database along with its insurance and plan links
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&center).Error; err != nil {
+			return err
+		}
+		if err := h.insurance.Sync(tx, center.ID, input, true); err != nil {
+			return err
+		}
+		if input.Waitlist != nil {
+			if _, err := h.waitlist.Record(tx, center.ID, *input.Waitlist, actorID(c)); err != nil {
+				return err
+			}
+		}
+		return auditABACenter(tx, audit.OpCreate, actorID(c), nil, center.ID)
+	})
+	if err != nil {
+		if isInvalidWrite(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.DB.Preload("InsuranceCarriers").Preload("MediCalPlanRefs").First(&center, "id = ?", center.ID)
 
 	// Return response
 	c.JSON(http.StatusCreated, gin.H{
@@ -57,24 +124,40 @@ func (h *ABACentersHandler) CreateABACenter(c *gin.Context) {
 	})
 }
 
-// GetABACenters retrieves all ABA centers
+// GetABACenters retrieves all ABA centers, or those inside ?bbox=
 func (h *ABACentersHandler) GetABACenters(c *gin.Context) {
 	var centers []models.ABACenter
 
-	if result := h.DB.Find(&centers); result.Error != nil {
+	query, done := viewportQuery(c, h.DB, "aba_centers")
+	if done {
+		return
+	}
+	if result := query.Preload("InsuranceCarriers").Preload("MediCalPlanRefs").Find(&centers); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ABA centers"})
 		return
 	}
 
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, len(centers), func(i int) (gin.H, error) {
+			center := centers[i]
+			return geoJSONFeature(center.ID, pointGeometry(center.Latitude, center.Longitude), center)
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, centers)
 }
 
 // GetABACenterByID retrieves a specific ABA center by ID
 func (h *ABACentersHandler) GetABACenterByID(c *gin.Context) {
 	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ABA center ID format"})
+		return
+	}
 	var center models.ABACenter
 
-	if result := h.DB.First(&center, id); result.Error != nil {
+	if result := h.DB.Preload("InsuranceCarriers").Preload("MediCalPlanRefs").First(&center, "id = ?", id); result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "ABA center not found"})
 		return
 	}
@@ -85,10 +168,14 @@ func (h *ABACentersHandler) GetABACenterByID(c *gin.Context) {
 // UpdateABACenter updates an existing ABA center
 func (h *ABACentersHandler) UpdateABACenter(c *gin.Context) {
 	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ABA center ID format"})
+		return
+	}
 	var center models.ABACenter
 
 	// Check if the center exists
-	if result := h.DB.First(&center, id); result.Error != nil {
+	if result := h.DB.First(&center, "id = ?", id); result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "ABA center not found"})
 		return
 	}
@@ -114,15 +201,65 @@ func (h *ABACentersHandler) UpdateABACenter(c *gin.Context) {
 		InsuranceAccepted:    input.InsuranceAccepted,
 		MediCalPlans:         input.MediCalPlans,
 		Notes:                input.Notes,
+		UpdatedBy:            actorID(c),
 	}
 
-	if result := h.DB.Model(&center).Updates(updates); result.Error != nil {
+	// Only look the address up again when it changed or coordinates were supplied
+	addressChanged := updates.Street != center.Street || updates.City != center.City || updates.Zip != center.Zip
+	relocate := addressChanged || (input.Latitude != nil && input.Longitude != nil)
+	if relocate {
+		h.locate(c, &updates, input)
+	}
+	// Updates skips empty strings, so links follow the text as it will be stored
+	linkInput := input
+	if linkInput.InsuranceAccepted == "" {
+		linkInput.InsuranceAccepted = center.InsuranceAccepted
+	}
+	if linkInput.MediCalPlans == "" {
+		linkInput.MediCalPlans = center.MediCalPlans
+	}
+	insuranceChanged := linkInput.InsuranceAccepted != center.InsuranceAccepted || linkInput.MediCalPlans != center.MediCalPlans
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := audit.Load(tx, audit.EntityABACenter, center.ID.String())
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&center).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := h.insurance.Sync(tx, center.ID, linkInput, insuranceChanged); err != nil {
+			return err
+		}
+		if input.Waitlist != nil {
+			if _, err := h.waitlist.Record(tx, center.ID, *input.Waitlist, actorID(c)); err != nil {
+				return err
+			}
+		}
+		if relocate {
+			// Updates skips nil fields, so write the coordinates explicitly
+			err := tx.Model(&center).Updates(map[string]interface{}{
+				"latitude":    updates.Latitude,
+				"longitude":   updates.Longitude,
+				"geocoded_at": updates.GeocodedAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return auditABACenter(tx, audit.OpUpdate, actorID(c), before, center.ID)
+	})
+	if err != nil {
+		if isInvalidWrite(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ABA center"})
 		return
 	}
 
 	// Fetch updated center
-	h.DB.First(&center, id)
+	h.DB.Preload("InsuranceCarriers").Preload("MediCalPlanRefs").First(&center, "id = ?", id)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -131,19 +268,36 @@ func (h *ABACentersHandler) UpdateABACenter(c *gin.Context) {
 	})
 }
 
-// DeleteABACenter deletes an ABA center
+// DeleteABACenter moves an ABA center to the trash
 func (h *ABACentersHandler) DeleteABACenter(c *gin.Context) {
 	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ABA center ID format"})
+		return
+	}
 	var center models.ABACenter
 
 	// Check if the center exists
-	if result := h.DB.First(&center, id); result.Error != nil {
+	if result := h.DB.First(&center, "id = ?", id); result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "ABA center not found"})
 		return
 	}
 
-	// Delete the center
-	if result := h.DB.Delete(&center); result.Error != nil {
+	// Move the center to the trash, keeping its last state in the audit log
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := audit.Load(tx, audit.EntityABACenter, center.ID.String())
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&center).UpdateColumn("deleted_by", actorID(c)).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&center).Error; err != nil {
+			return err
+		}
+		return auditABACenter(tx, audit.OpDelete, actorID(c), before, center.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ABA center"})
 		return
 	}
@@ -154,12 +308,153 @@ func (h *ABACentersHandler) DeleteABACenter(c *gin.Context) {
 	})
 }
 
-// SearchABACenters searches for ABA centers based on criteria
+// SearchABACenters searches for ABA centers based on criteria. With lat and lng
+// it only returns centers within radius miles (default 10), nearest first.
 func (h *ABACentersHandler) SearchABACenters(c *gin.Context) {
 	var centers []models.ABACenter
-	query := h.DB.Model(&models.ABACenter{})
 
-	// Add filters based on query parameters
+	near, ok := parseProximity(c)
+	if !ok {
+		return
+	}
+	query, err := filterABACenters(c, h.DB.Model(&models.ABACenter{}), near)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	order, err := abaCenterOrder(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if order != "" {
+		query = query.Order(order)
+	}
+
+	if near != nil {
+		nearby := []models.NearbyABACenter{}
+		err := query.
+			Select("aba_centers.*, ST_Distance(location::geography, "+proximityPoint+") / 1609.344 AS distance_miles", near.lng, near.lat).
+			Order("distance_miles").
+			Scan(&nearby).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search ABA centers"})
+			return
+		}
+		for i := range nearby {
+			nearby[i].DistanceMiles = math.Round(nearby[i].DistanceMiles*100) / 100
+		}
+		c.JSON(http.StatusOK, nearby)
+		return
+	}
+
+	// Execute query
+	if result := query.Find(&centers); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search ABA centers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, centers)
+}
+// proximity is a radius search around a point, radius in miles
+type proximity struct {
+	lat, lng, radius float64
+}
+
+const proximityPoint = "ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography"
+
+// parseProximity reads ?lat=&lng=&radius= (miles, default 10). It returns nil
+// without a point, and ok is false after it has answered with a 400.
+func parseProximity(c *gin.Context) (*proximity, bool) {
+	if c.Query("lat") == "" && c.Query("lng") == "" {
+		return nil, true
+	}
+
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(c.Query("lng"), 64)
+	radius, radiusErr := strconv.ParseFloat(c.DefaultQuery("radius", "10"), 64)
+	if latErr != nil || lngErr != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng must both be valid coordinates"})
+		return nil, false
+	}
+	if radiusErr != nil || radius <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "radius must be a positive number of miles"})
+		return nil, false
+	}
+	return &proximity{lat: lat, lng: lng, radius: radius}, true
+}
+
+// auditABACenter records a change to a center, reading its state after the
+// change from tx. before is nil for creates.
+func auditABACenter(tx *gorm.DB, op string, actor *int, before interface{}, id uuid.UUID) error {
+	change := audit.Change{EntityType: audit.EntityABACenter, EntityID: id.String(), Operation: op, ActorID: actor, Before: before}
+	if op != audit.OpDelete {
+		after, err := audit.Load(tx, audit.EntityABACenter, id.String())
+		if err != nil {
+			return err
+		}
+		change.After = after
+	}
+	return audit.Record(tx, change)
+}
+
+// auditCenterChange runs change inside tx and records what it did to the center
+func auditCenterChange(tx *gorm.DB, actor *int, id uuid.UUID, change func() error) error {
+	before, err := audit.Load(tx, audit.EntityABACenter, id.String())
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	return auditABACenter(tx, audit.OpUpdate, actor, before, id)
+}
+
+// isInvalidWrite reports whether a write named a carrier or plan ID that
+// does not exist or carried an invalid waitlist check
+func isInvalidWrite(err error) bool {
+	return errors.Is(err, insurance.ErrUnknownCarrier) || errors.Is(err, insurance.ErrUnknownPlan) ||
+		errors.Is(err, waitlist.ErrFutureCheck)
+}
+
+// parseIDs reads a comma separated list of integer IDs
+func parseIDs(value string) ([]int, error) {
+	ids := []int{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// filterLinked applies ?<param>=1,2 with ?<param>_match=any|all for carriers or plans
+func filterLinked(c *gin.Context, query *gorm.DB, param, kind string) (*gorm.DB, error) {
+	value := c.Query(param)
+	if value == "" {
+		return query, nil
+	}
+	ids, err := parseIDs(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a comma separated list of IDs", param)
+	}
+	match := c.DefaultQuery(param+"_match", "any")
+	if match != "any" && match != "all" {
+		return nil, fmt.Errorf("%s_match must be any or all", param)
+	}
+	return insurance.FilterCenters(query, kind, ids, match == "all")
+}
+
+// filterABACenters applies the search filters shared by search and export.
+// insurance_ids and medi_cal_plan_ids match linked carriers and plans exactly;
+// the older insurance text filter still matches the free-text column.
+// waitlist_status and verified_within_days filter on the latest waitlist check.
+func filterABACenters(c *gin.Context, query *gorm.DB, near *proximity) (*gorm.DB, error) {
 	if city := c.Query("city"); city != "" {
 		query = query.Where("city ILIKE ?", "%"+city+"%")
 	}
@@ -176,11 +471,166 @@ func (h *ABACentersHandler) SearchABACenters(c *gin.Context) {
 		query = query.Where("medi_cal_plans IS NOT NULL AND medi_cal_plans != ''")
 	}
 
-	// Execute query
-	if result := query.Find(&centers); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search ABA centers"})
+	if near != nil {
+		query = query.Where("location IS NOT NULL AND ST_DWithin(location::geography, "+proximityPoint+", ?)",
+			near.lng, near.lat, near.radius*1609.344)
+	}
+
+	if value := c.Query("waitlist_status"); value != "" {
+		statuses := strings.Split(value, ",")
+		unknown := false
+		known := []string{}
+		for _, status := range statuses {
+			switch status = strings.TrimSpace(status); {
+			case status == "unknown":
+				unknown = true
+			case isWaitlistStatus(status):
+				known = append(known, status)
+			default:
+				return nil, errors.New("waitlist_status must list open, short, long, closed or unknown")
+			}
+		}
+		switch {
+		case unknown && len(known) > 0:
+			query = query.Where("(aba_centers.waitlist_status IN ? OR aba_centers.waitlist_status IS NULL)", known)
+		case unknown:
+			query = query.Where("aba_centers.waitlist_status IS NULL")
+		default:
+			query = query.Where("aba_centers.waitlist_status IN ?", known)
+		}
+	}
+
+	if value := c.Query("verified_within_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return nil, errors.New("verified_within_days must be a whole number of days")
+		}
+		query = query.Where("aba_centers.last_verified_at >= ?", time.Now().AddDate(0, 0, -days))
+	}
+
+	query, err := filterLinked(c, query, "insurance_ids", insurance.KindInsurance)
+	if err != nil {
+		return nil, err
+	}
+	return filterLinked(c, query, "medi_cal_plan_ids", insurance.KindMediCal)
+}
+
+func isWaitlistStatus(status string) bool {
+	for _, s := range models.WaitlistStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// abaCenterOrder reads ?sort=name|waitlist|freshness. waitlist puts the shortest
+// waits first and freshness the most recently verified; "" means no sort was asked for.
+func abaCenterOrder(c *gin.Context) (string, error) {
+	switch c.Query("sort") {
+	case "":
+		return "", nil
+	case "name":
+		return "aba_centers.name, aba_centers.id", nil
+	case "waitlist":
+		return waitlist.StatusOrder + ", aba_centers.estimated_wait_weeks NULLS LAST, aba_centers.last_verified_at DESC NULLS LAST", nil
+	case "freshness":
+		return "aba_centers.last_verified_at DESC NULLS LAST", nil
+	default:
+		return "", errors.New("sort must be name, waitlist or freshness")
+	}
+}
+
+// ExportABACenters downloads the centers matching the search filters as CSV, XLSX or KML
+func (h *ABACentersHandler) ExportABACenters(c *gin.Context) {
+	near, ok := parseProximity(c)
+	if !ok {
+		return
+	}
+	query, err := filterABACenters(c, h.DB.Model(&models.ABACenter{}), near)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	order, err := abaCenterOrder(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if order == "" {
+		order = "name, id"
+	}
+	query = query.Order(order)
+
+	columns := []string{"Name", "Street", "City", "ZIP", "Phone", "Service Type", "Waitlist",
+		"Waitlist Status", "Estimated Wait (Weeks)", "Last Verified", "Waitlist Notes", "Dx Verification", "Insurance Accepted", "Medi-Cal Plans", "Notes",
+		"Latitude", "Longitude"}
+	streamExport(c, query, "aba-centers", columns, func(center models.ABACenter) export.Row {
+		return export.Row{
+			Name: center.Name,
+			Values: []string{center.Name, center.Street, center.City, center.Zip, center.Phone,
+				center.ServiceType, center.WaitlistAvailability, stringValue(center.WaitlistStatus),
+				intValue(center.EstimatedWaitWeeks), timeValue(center.LastVerifiedAt), center.WaitlistNotes, center.DxVerification,
+				center.InsuranceAccepted, center.MediCalPlans, center.Notes,
+				formatCoordinate(center.Latitude), formatCoordinate(center.Longitude)},
+			Latitude:  center.Latitude,
+			Longitude: center.Longitude,
+		}
+	})
+}
 
-	c.JSON(http.StatusOK, centers)
-}
\ No newline at end of file
+// ImportABACenters loads centers from an uploaded CSV or XLSX file (multipart
+// field "file"). ?mode=upsert|insert, ?dry_run=true reports without writing,
+// ?skip_invalid=true commits the valid rows when others fail, and mapping is a
+// JSON object of request field to column header.
+func (h *ABACentersHandler) ImportABACenters(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload a CSV or XLSX file in the file field"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer f.Close()
+
+	mapping := map[string]string{}
+	if raw := c.DefaultPostForm("mapping", c.Query("mapping")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mapping must be a JSON object of field to column header"})
+			return
+		}
+	}
+
+	table, err := abaimport.ReadTable(f, file.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := abaimport.NewService(h.DB, h.Geocoder).Import(c.Request.Context(), table, abaimport.Options{
+		Mapping:     mapping,
+		Mode:        c.DefaultQuery("mode", abaimport.ModeUpsert),
+		DryRun:      c.Query("dry_run") == "true",
+		SkipInvalid: c.Query("skip_invalid") == "true",
+		ActorID:     actorID(c),
+	})
+	if err != nil {
+		if errors.Is(err, abaimport.ErrInvalidImport) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("ABA center import failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import ABA centers"})
+		return
+	}
+
+	// Invalid rows block a real import unless skip_invalid is set
+	if !report.DryRun && !report.Committed && report.Errors > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bac/internal/models"
+	"bac/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteABACenterMovesItToTrash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tx := testutil.Tx(t)
+
+	center := models.ABACenter{Name: "Trash Test Center", Street: "1 Main St", City: "Fresno", Zip: "93701",
+		Phone: "5595550100", ServiceType: "Clinic"}
+	if err := tx.Create(&center).Error; err != nil {
+		t.Fatalf("create center: %v", err)
+	}
+
+	centers := NewABACenterHandler(tx, nil)
+	trash := NewTrashHandler(tx, 0)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("userID", 1) })
+	router.GET("/aba-centers/:id", centers.GetABACenterByID)
+	router.DELETE("/aba-centers/:id", centers.DeleteABACenter)
+	router.POST("/trash/:entity_type/:id/restore", trash.RestoreItem)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	id := center.ID.String()
+
+	if w := do(http.MethodDelete, "/aba-centers/"+id); w.Code != http.StatusOK {
+		t.Fatalf("delete: got %d %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodGet, "/aba-centers/"+id); w.Code != http.StatusNotFound {
+		t.Fatalf("get after delete: got %d, want 404", w.Code)
+	}
+
+	var deletedBy *int
+	if err := tx.Unscoped().Model(&models.ABACenter{}).Where("id = ?", id).Pluck("deleted_by", &deletedBy).Error; err != nil {
+		t.Fatalf("read deleted_by: %v", err)
+	}
+	if deletedBy == nil || *deletedBy != 1 {
+		t.Errorf("deleted_by = %v, want 1", deletedBy)
+	}
+
+	if w := do(http.MethodPost, "/trash/aba_centers/"+id+"/restore"); w.Code != http.StatusOK {
+		t.Fatalf("restore: got %d %s", w.Code, w.Body)
+	}
+	w := do(http.MethodGet, "/aba-centers/"+id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get after restore: got %d %s", w.Code, w.Body)
+	}
+	var restored models.ABACenter
+	if err := json.Unmarshal(w.Body.Bytes(), &restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Name != center.Name {
+		t.Errorf("restored name = %q, want %q", restored.Name, center.Name)
+	}
+
+	if w := do(http.MethodPost, "/trash/aba_centers/"+id+"/restore"); w.Code != http.StatusNotFound {
+		t.Errorf("second restore: got %d, want 404", w.Code)
+	}
+}
+
+func TestDeleteABACenterRejectsInvalidID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/aba-centers/:id", NewABACenterHandler(nil, nil).DeleteABACenter)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/aba-centers/1%20OR%201=1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got %d, want 400", w.Code)
+	}
+}
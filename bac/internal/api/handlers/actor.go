@@ -0,0 +1,17 @@
+package handlers
+
+import "github.com/gin-gonic/gin"
+
+// actorID returns the ID of the authenticated user making the request,
+// or nil when the route is not behind the auth middleware
+func actorID(c *gin.Context) *int {
+	value, exists := c.Get("userID")
+	if !exists {
+		return nil
+	}
+	id, ok := value.(int)
+	if !ok {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,121 @@
+// internal/api/handlers/audit_handler.go
+
+package handlers
+
+import (
+	"bac/internal/audit"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditHandler serves the change history of directory records and reverts them
+type AuditHandler struct {
+	DB    *gorm.DB
+	audit *audit.Service
+}
+
+// NewAuditHandler creates a new AuditHandler instance
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{DB: db, audit: audit.NewService(db)}
+}
+
+// GetAuditLog lists changes across the directory, newest first, filtered by
+// ?entity_type=, ?entity_id= and ?actor_id=
+func (h *AuditHandler) GetAuditLog(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	filter := audit.Filter{
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		Limit:      limit,
+		Offset:     offset,
+	}
+	if filter.EntityType != "" && !audit.IsEntityType(filter.EntityType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": audit.ErrUnknownEntity.Error()})
+		return
+	}
+	if value := c.Query("actor_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "actor_id must be a user ID"})
+			return
+		}
+		filter.ActorID = &id
+	}
+
+	entries, err := h.audit.Entries(filter)
+	if err != nil {
+		fmt.Printf("Error listing audit log: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetHistory returns a handler listing one record's changes, newest first.
+// Each entry's id is the version to pass to the revert endpoint.
+func (h *AuditHandler) GetHistory(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+		limit, offset, ok := pageParams(c)
+		if !ok {
+			return
+		}
+
+		entries, err := h.audit.Entries(audit.Filter{EntityType: entityType, EntityID: id.String(), Limit: limit, Offset: offset})
+		if err != nil {
+			fmt.Printf("Error listing history of %s %s: %v\n", entityType, id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve history"})
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// Revert returns a handler that restores a record to a version from its
+// history, recreating it if it was deleted
+func (h *AuditHandler) Revert(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+		var input struct {
+			Version int64 `json:"version" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entry, err := h.audit.Revert(entityType, id, input.Version, actorID(c))
+		if err != nil {
+			switch {
+			case errors.Is(err, audit.ErrVersionNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			case errors.Is(err, audit.ErrDeletedVersion):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, audit.ErrNothingToRevert):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				fmt.Printf("Error reverting %s %s to version %d: %v\n", entityType, id, input.Version, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revert"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, entry)
+	}
+}
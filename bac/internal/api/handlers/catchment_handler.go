@@ -0,0 +1,173 @@
+// internal/api/handlers/catchment_handler.go
+
+package handlers
+
+import (
+	"bac/internal/catchment"
+	"bac/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CatchmentHandler answers which regional center serves a location and imports service areas
+type CatchmentHandler struct {
+	DB         *gorm.DB
+	catchments *catchment.Service
+}
+
+// NewCatchmentHandler creates a new CatchmentHandler instance
+func NewCatchmentHandler(db *gorm.DB) *CatchmentHandler {
+	return &CatchmentHandler{DB: db, catchments: catchment.NewService(db)}
+}
+
+// LookupCatchment returns the responsible regional center and its nearest office
+// for either ?zip= or ?lat=&lng=
+func (h *CatchmentHandler) LookupCatchment(c *gin.Context) {
+	var (
+		result *models.CatchmentLookup
+		err    error
+	)
+
+	if zip := c.Query("zip"); zip != "" {
+		result, err = h.catchments.LookupZip(zip)
+	} else {
+		lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+		lng, lngErr := strconv.ParseFloat(c.Query("lng"), 64)
+		if latErr != nil || lngErr != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a zip, or a valid lat and lng"})
+			return
+		}
+		result, err = h.catchments.LookupPoint(lat, lng)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, catchment.ErrNoCatchment):
+			c.JSON(http.StatusNotFound, gin.H{"error": "No regional center serves this location"})
+		case errors.Is(err, catchment.ErrUnknownZip):
+			c.JSON(http.StatusNotFound, gin.H{"error": "ZIP code not found"})
+		default:
+			fmt.Printf("Error looking up catchment: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up regional center"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetCatchments returns service areas as a GeoJSON FeatureCollection,
+// optionally for one regional center
+func (h *CatchmentHandler) GetCatchments(c *gin.Context) {
+	var rows []struct {
+		ID               int
+		RegionalCenterID int
+		RegionalCenter   string
+		Name             string
+		Geometry         string
+	}
+
+	query := h.DB.Table("regional_center_catchments AS rc").
+		Select(`rc.id, rc.regional_center_id, r.regional_center, rc.name,
+			ST_AsGeoJSON(rc.geom, 6) AS geometry`).
+		Joins("JOIN regional_centers r ON r.id = rc.regional_center_id AND r.deleted_at IS NULL").
+		Order("rc.id")
+	if centerID := c.Query("regional_center_id"); centerID != "" {
+		id, err := strconv.Atoi(centerID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid regional_center_id"})
+			return
+		}
+		query = query.Where("rc.regional_center_id = ?", id)
+	}
+
+	if err := query.Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve catchments"})
+		return
+	}
+
+	features := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		features = append(features, gin.H{
+			"type":     "Feature",
+			"id":       row.ID,
+			"geometry": json.RawMessage(row.Geometry),
+			"properties": gin.H{
+				"regional_center_id": row.RegionalCenterID,
+				"regional_center":    row.RegionalCenter,
+				"name":               row.Name,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"type": "FeatureCollection", "features": features})
+}
+
+// ImportCatchments loads service area polygons from an uploaded GeoJSON file
+// (multipart field "file") or a GeoJSON request body
+func (h *CatchmentHandler) ImportCatchments(c *gin.Context) {
+	body, source, ok := geoJSONUpload(c)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	report, err := h.catchments.ImportCatchments(body, catchment.ImportOptions{
+		CenterProperty: c.Query("center_property"),
+		NameProperty:   c.Query("name_property"),
+		ZipProperty:    c.Query("zip_property"),
+		Source:         c.DefaultQuery("source", source),
+		Replace:        c.Query("replace") == "true",
+		DryRun:         c.Query("dry_run") == "true",
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ImportZipAreas loads ZIP code tabulation area polygons used by ZIP lookups
+func (h *CatchmentHandler) ImportZipAreas(c *gin.Context) {
+	body, _, ok := geoJSONUpload(c)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	report, err := h.catchments.ImportZipAreas(body, catchment.ZipImportOptions{
+		ZipProperty: c.Query("zip_property"),
+		DryRun:      c.Query("dry_run") == "true",
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// geoJSONUpload opens the uploaded file, falling back to the raw request body
+func geoJSONUpload(c *gin.Context) (io.ReadCloser, string, bool) {
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return nil, "", false
+		}
+		return f, file.Filename, true
+	}
+	if c.Request.ContentLength == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload a GeoJSON file in the file field or send it as the request body"})
+		return nil, "", false
+	}
+	return c.Request.Body, "upload", true
+}
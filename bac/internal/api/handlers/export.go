@@ -0,0 +1,93 @@
+// internal/api/handlers/export.go
+
+package handlers
+
+import (
+	"bac/internal/export"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// streamExport writes the rows of query as a ?format=csv|xlsx|kml download,
+// scanning one record at a time from the database cursor
+func streamExport[T any](c *gin.Context, query *gorm.DB, title string, columns []string, toRow func(T) export.Row) {
+	format, ok := export.Formats[c.DefaultQuery("format", "csv")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, xlsx or kml"})
+		return
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		fmt.Printf("Error exporting %s: %v\n", title, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export " + title})
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("%s-%s.%s", title, time.Now().Format("2006-01-02"), format.Extension)
+	c.Header("Content-Type", format.ContentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(format.Name, c.Writer, title, columns)
+	if err != nil {
+		fmt.Printf("Error exporting %s: %v\n", title, err)
+		return
+	}
+
+	// Once streaming has started the status is sent, so failures can only be logged
+	for rows.Next() {
+		var record T
+		if err := query.ScanRows(rows, &record); err != nil {
+			fmt.Printf("Error exporting %s: %v\n", title, err)
+			return
+		}
+		if err := w.Write(toRow(record)); err != nil {
+			fmt.Printf("Error exporting %s: %v\n", title, err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		fmt.Printf("Error exporting %s: %v\n", title, err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		fmt.Printf("Error exporting %s: %v\n", title, err)
+	}
+}
+
+// formatCoordinate renders an optional coordinate for a spreadsheet cell
+func formatCoordinate(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// stringValue, intValue and timeValue render optional fields for a spreadsheet cell
+func stringValue(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func intValue(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func timeValue(v *time.Time) string {
+	if v == nil {
+		return ""
+	}
+	return v.Format(time.RFC3339)
+}
@@ -0,0 +1,67 @@
+// internal/api/handlers/geojson.go
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const geoJSONContentType = "application/geo+json"
+
+// wantsGeoJSON reports whether the client asked for GeoJSON, with ?format=geojson
+// or an Accept header naming application/geo+json
+func wantsGeoJSON(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return strings.EqualFold(format, "geojson")
+	}
+	return strings.Contains(c.GetHeader("Accept"), geoJSONContentType)
+}
+
+// pointGeometry returns a GeoJSON Point, or nil for records without coordinates.
+// Spreadsheet imports stored missing coordinates as 0, 0, so that counts as missing.
+func pointGeometry(lat, lng *float64) interface{} {
+	if lat == nil || lng == nil || (*lat == 0 && *lng == 0) {
+		return nil
+	}
+	return gin.H{"type": "Point", "coordinates": []float64{*lng, *lat}}
+}
+
+// geoJSONFeature wraps a record as a Feature whose properties are the record's JSON fields
+func geoJSONFeature(id interface{}, geometry interface{}, record interface{}) (gin.H, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	properties := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &properties); err != nil {
+		return nil, err
+	}
+	return gin.H{"type": "Feature", "id": id, "geometry": geometry, "properties": properties}, nil
+}
+
+// respondGeoJSON writes n records as a FeatureCollection, building each with feature
+func respondGeoJSON(c *gin.Context, n int, feature func(i int) (gin.H, error)) {
+	features := make([]gin.H, 0, n)
+	for i := 0; i < n; i++ {
+		f, err := feature(i)
+		if err != nil {
+			fmt.Printf("Error encoding GeoJSON feature: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode GeoJSON"})
+			return
+		}
+		features = append(features, f)
+	}
+
+	body, err := json.Marshal(gin.H{"type": "FeatureCollection", "features": features})
+	if err != nil {
+		fmt.Printf("Error encoding GeoJSON: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode GeoJSON"})
+		return
+	}
+	c.Data(http.StatusOK, geoJSONContentType, body)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWantsGeoJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		want   bool
+	}{
+		{"plain request", "", "", false},
+		{"format parameter", "?format=GeoJSON", "", true},
+		{"accept header", "", "application/geo+json, application/json;q=0.9", true},
+		{"format wins over accept", "?format=json", "application/geo+json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/aba-centers"+tt.query, nil)
+			c.Request.Header.Set("Accept", tt.accept)
+			if got := wantsGeoJSON(c); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointGeometry(t *testing.T) {
+	lat, lng, zero := 36.7378, -119.7871, 0.0
+	tests := []struct {
+		name     string
+		lat, lng *float64
+		want     interface{}
+	}{
+		{"located", &lat, &lng, gin.H{"type": "Point", "coordinates": []float64{lng, lat}}},
+		{"on the equator", &zero, &lng, gin.H{"type": "Point", "coordinates": []float64{lng, zero}}},
+		{"on the prime meridian", &lat, &zero, gin.H{"type": "Point", "coordinates": []float64{zero, lat}}},
+		{"imported as 0, 0", &zero, &zero, nil},
+		{"no latitude", nil, &lng, nil},
+		{"no longitude", &lat, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pointGeometry(tt.lat, tt.lng); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespondGeoJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lat, lng, zero := 36.7378, -119.7871, 0.0
+	records := []struct {
+		ID        string   `json:"id"`
+		Name      string   `json:"name"`
+		Latitude  *float64 `json:"latitude"`
+		Longitude *float64 `json:"longitude"`
+	}{
+		{"a", "Bright Steps", &lat, &lng},
+		{"b", "Null Island", &zero, &zero},
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	respondGeoJSON(c, len(records), func(i int) (gin.H, error) {
+		r := records[i]
+		return geoJSONFeature(r.ID, pointGeometry(r.Latitude, r.Longitude), r)
+	})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != geoJSONContentType {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Type     string `json:"type"`
+			ID       string `json:"id"`
+			Geometry *struct {
+				Type        string    `json:"type"`
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &collection); err != nil {
+		t.Fatal(err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Fatalf("got %s", w.Body)
+	}
+	located, missing := collection.Features[0], collection.Features[1]
+	if located.Type != "Feature" || located.ID != "a" || located.Geometry == nil ||
+		!reflect.DeepEqual(located.Geometry.Coordinates, []float64{lng, lat}) {
+		t.Errorf("located feature %+v", located)
+	}
+	if located.Properties["name"] != "Bright Steps" {
+		t.Errorf("properties %v", located.Properties)
+	}
+	// Records without a location are kept with a null geometry
+	if missing.ID != "b" || missing.Geometry != nil {
+		t.Errorf("0, 0 feature %+v", missing)
+	}
+}
+
+func TestRespondGeoJSONReportsFeatureErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	respondGeoJSON(c, 1, func(int) (gin.H, error) {
+		return nil, errors.New("unencodable")
+	})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got %d, want 500", w.Code)
+	}
+}
@@ -44,11 +44,16 @@ func (h *GeolocationHandler) SearchNearby(c *gin.Context) {
 	}
 
 	var results []models.NearbyResource
+	// find_nearby_resources does not return coordinates, so join them back in
 	query := `
         SELECT 
-            id, name, description, address, 
-            distance_miles, diagnoses, contact_info
-        FROM find_nearby_resources($1, $2, $3, $4)
+            f.id, f.name, f.description, f.address, 
+            f.distance_miles, f.diagnoses, f.contact_info,
+            r.latitude, r.longitude
+        FROM find_nearby_resources($1, $2, $3, $4) f
+        JOIN resources r ON r.id = f.id
+        WHERE r.deleted_at IS NULL
+        ORDER BY f.distance_miles
     `
 
 	if err := h.db.Raw(query, lat, lng, radius, pq.Array(diagnoses)).Scan(&results).Error; err != nil {
@@ -56,5 +61,13 @@ func (h *GeolocationHandler) SearchNearby(c *gin.Context) {
 		return
 	}
 
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, len(results), func(i int) (gin.H, error) {
+			r := results[i]
+			return geoJSONFeature(r.ID, pointGeometry(&r.Latitude, &r.Longitude), r)
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, results)
 }
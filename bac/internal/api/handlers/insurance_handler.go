@@ -0,0 +1,112 @@
+// internal/api/handlers/insurance_handler.go
+
+package handlers
+
+import (
+	"bac/internal/insurance"
+	"bac/internal/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// InsuranceHandler serves the insurance carrier and Medi-Cal plan reference
+// lists and the queue of free-text values that could not be mapped
+type InsuranceHandler struct {
+	DB        *gorm.DB
+	insurance *insurance.Service
+}
+
+// NewInsuranceHandler creates a new InsuranceHandler instance
+func NewInsuranceHandler(db *gorm.DB) *InsuranceHandler {
+	return &InsuranceHandler{DB: db, insurance: insurance.NewService(db)}
+}
+
+// GetInsuranceCarriers lists the carriers ABA center search can filter by
+func (h *InsuranceHandler) GetInsuranceCarriers(c *gin.Context) {
+	carriers, err := h.insurance.Carriers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve insurance carriers"})
+		return
+	}
+	c.JSON(http.StatusOK, carriers)
+}
+
+// GetMediCalPlans lists the Medi-Cal plans ABA center search can filter by
+func (h *InsuranceHandler) GetMediCalPlans(c *gin.Context) {
+	plans, err := h.insurance.Plans()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve Medi-Cal plans"})
+		return
+	}
+	c.JSON(http.StatusOK, plans)
+}
+
+// GetReviews lists unmapped insurance and plan values, pending ones by default
+func (h *InsuranceHandler) GetReviews(c *gin.Context) {
+	status := c.DefaultQuery("status", insurance.StatusPending)
+	if status == "all" {
+		status = ""
+	}
+	reviews, err := h.insurance.Reviews(status)
+	if err != nil {
+		fmt.Printf("Error listing insurance reviews: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve review queue"})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// ResolveReview maps a queued value onto a carrier or plan, or ignores it
+func (h *InsuranceHandler) ResolveReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	var input insurance.Resolution
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A mapping links the review's center to a carrier or plan, which shows
+	// in the center's history
+	var review *models.InsuranceMappingReview
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var pending models.InsuranceMappingReview
+		if err := tx.Select("aba_center_id").First(&pending, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return insurance.ErrReviewNotFound
+			}
+			return err
+		}
+		return auditCenterChange(tx.Unscoped(), actorID(c), pending.ABACenterID, func() error {
+			var err error
+			review, err = insurance.NewService(tx).Resolve(id, input, actorID(c))
+			return err
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, insurance.ErrReviewNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, insurance.ErrReviewResolved):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, insurance.ErrInvalidResolved),
+			errors.Is(err, insurance.ErrUnknownCarrier),
+			errors.Is(err, insurance.ErrUnknownPlan):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			fmt.Printf("Error resolving insurance review %d: %v\n", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve review item"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"bac/internal/export"
+	"bac/internal/models"
 	"net/http"
 	"log"
 	"strings"
@@ -9,17 +11,6 @@ import (
 	"gorm.io/gorm"
 )
 
-// Provider struct
-type Provider struct {
-	ID                  int     `json:"id" gorm:"primaryKey"`
-	Name                string  `json:"name"`
-	Phone               string  `json:"phone"`
-	CoverageAreas       string  `json:"coverage_areas"`
-	CenterBasedServices string  `json:"center_based_services"`
-	Latitude            float64 `json:"latitude"`
-	Longitude           float64 `json:"longitude"`
-	Areas               string  `json:"areas"` // ✅ Treat as a plain string
-}
 // ProvidersHandler struct
 type ProvidersHandler struct {
 	DB *gorm.DB
@@ -36,10 +27,16 @@ func NewProvidersHandler(db *gorm.DB) *ProvidersHandler {
 
 // ✅ Gin-compatible function to get providers
 func (h *ProvidersHandler) GetProviders(c *gin.Context) {
-	var providers []Provider
+	var providers []models.Provider
+
+	// ✅ Limit to the map viewport, or answer with clusters
+	query, done := viewportQuery(c, h.DB, "providers")
+	if done {
+		return
+	}
 
 	// ✅ Fetch providers using GORM
-	result := h.DB.Find(&providers)
+	result := query.Find(&providers)
 	if result.Error != nil {
 		log.Println("Database Query Error:", result.Error)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
@@ -77,6 +74,15 @@ func (h *ProvidersHandler) GetProviders(c *gin.Context) {
 		})
 	}
 
+	// ✅ Return GeoJSON when asked, for GIS tools
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, len(formattedProviders), func(i int) (gin.H, error) {
+			p := formattedProviders[i]
+			return geoJSONFeature(p.ID, pointGeometry(&p.Latitude, &p.Longitude), p)
+		})
+		return
+	}
+
 	// ✅ Return transformed JSON
 	c.JSON(http.StatusOK, formattedProviders)
 }
@@ -89,3 +95,22 @@ func splitAreasString(s string) []string {
 	}
 	return strings.Split(trimmedAreas, ",")
 }
+
+// ExportProviders downloads providers, optionally inside ?bbox=, as CSV, XLSX or KML
+func (h *ProvidersHandler) ExportProviders(c *gin.Context) {
+	query, done := viewportQuery(c, h.DB.Model(&models.Provider{}), "providers")
+	if done {
+		return
+	}
+
+	columns := []string{"Name", "Phone", "Coverage Areas", "Center Based Services", "Areas", "Latitude", "Longitude"}
+	streamExport(c, query.Order("name, id"), "providers", columns, func(p models.Provider) export.Row {
+		return export.Row{
+			Name: p.Name,
+			Values: []string{p.Name, p.Phone, p.CoverageAreas, p.CenterBasedServices, p.Areas,
+				formatCoordinate(&p.Latitude), formatCoordinate(&p.Longitude)},
+			Latitude:  &p.Latitude,
+			Longitude: &p.Longitude,
+		}
+	})
+}
@@ -3,12 +3,12 @@
 package handlers
 
 import (
+	"bac/internal/export"
 	"bac/internal/models" // Change this line to use the local import path
 	"fmt"                 // Add this for debug logging
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"net/http"
-	"regexp"
 	"strconv"
 )
 
@@ -21,28 +21,19 @@ type RegionalCenterHandler struct {
 func NewRegionalCenterHandler(db *gorm.DB) *RegionalCenterHandler {
 	return &RegionalCenterHandler{DB: db}
 }
-func parseCoordinates(coordStr string) (float64, float64, error) {
-	re := regexp.MustCompile(`\((-?\d+\.?\d*),\s*(-?\d+\.?\d*)\)`)
-	matches := re.FindStringSubmatch(coordStr)
 
-	if len(matches) < 3 {
-		return 0, 0, fmt.Errorf("invalid coordinates: %s", coordStr)
-	}
-
-	lat, err1 := strconv.ParseFloat(matches[1], 64)
-	lng, err2 := strconv.ParseFloat(matches[2], 64)
-	if err1 != nil || err2 != nil {
-		return 0, 0, fmt.Errorf("error parsing coordinates: %v, %v", err1, err2)
-	}
-
-	return lat, lng, nil
-}
-
-// GetAllRegionalCenters retrieves all regional centers
+// GetAllRegionalCenters retrieves all regional centers that have a location,
+// optionally only those inside ?bbox=
 func (h *RegionalCenterHandler) GetAllRegionalCenters(c *gin.Context) {
 	var centers []models.RegionalCenter
 
-	result := h.DB.Find(&centers)
+	query, done := viewportQuery(c, h.DB, "regional_centers")
+	if done {
+		return
+	}
+	result := query.Select(models.RegionalCenterColumns).
+		Where("location IS NOT NULL").
+		Find(&centers)
 	if result.Error != nil {
 		fmt.Printf("Error getting centers: %v\n", result.Error)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve regional centers"})
@@ -51,30 +42,6 @@ func (h *RegionalCenterHandler) GetAllRegionalCenters(c *gin.Context) {
 
 	response := make([]map[string]interface{}, 0, len(centers))
 	for _, center := range centers {
-		// Extract coordinates using regular expressions
-		coordStr := center.LocationCoordinates
-		fmt.Printf("Processing coordinates: %s\n", coordStr)
-
-		// Look for pattern (number, number) anywhere in the string
-		re := regexp.MustCompile(`\((-?\d+\.?\d*),\s*(-?\d+\.?\d*)\)`)
-		matches := re.FindStringSubmatch(coordStr)
-
-		if len(matches) < 3 {
-			fmt.Printf("No valid coordinates found for center %s\n", center.RegionalCenter)
-			continue
-		}
-
-		lat, lng, err := parseCoordinates(center.LocationCoordinates)
-		if err != nil {
-			fmt.Printf("Error parsing coordinates for center %s: %v\n", center.RegionalCenter, err)
-			continue
-		}
-		// if err1 != nil || err2 != nil {
-		// 	fmt.Printf("Error parsing coordinates for center %s: %v, %v\n",
-		// 		center.RegionalCenter, err1, err2)
-		// 	continue
-		// }
-
 		// Combine address components
 		fullAddress := center.Address
 		if center.Suite != "" {
@@ -85,25 +52,35 @@ func (h *RegionalCenterHandler) GetAllRegionalCenters(c *gin.Context) {
 		response = append(response, map[string]interface{}{
 			"id":        center.ID,
 			"name":      center.RegionalCenter,
-			"latitude":  lat,
-			"longitude": lng,
+			"latitude":  center.Latitude,
+			"longitude": center.Longitude,
 			"address":   fullAddress,
 			"phone":     center.Telephone,
 			"website":   center.Website,
 			"type":      center.OfficeType,
 		})
 	}
-	
-	fmt.Printf("Sending %d centers\n", len(response))
+
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, len(centers), func(i int) (gin.H, error) {
+			return geoJSONFeature(centers[i].ID, pointGeometry(centers[i].Latitude, centers[i].Longitude), response[i])
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
 // GetRegionalCenterByID retrieves a specific regional center by ID
 func (h *RegionalCenterHandler) GetRegionalCenterByID(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid regional center ID format"})
+		return
+	}
 	var center models.RegionalCenter
 
-	if result := h.DB.First(&center, id); result.Error != nil {
+	if result := h.DB.Select(models.RegionalCenterColumns).First(&center, "id = ?", id); result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Regional center not found"})
 		return
 	}
@@ -117,22 +94,13 @@ func (h *RegionalCenterHandler) SearchRegionalCenters(c *gin.Context) {
 	var totalCount int64
 
 	var regionalCenter models.RegionalCenter
-	query := h.DB.Model(&regionalCenter)
-
-	// Apply filters
-	if county := c.Query("county"); county != "" {
-		query = query.Where("county_served ILIKE ?", "%"+county+"%")
-	}
-	if district := c.Query("district"); district != "" {
-		query = query.Where("los_angeles_health_district ILIKE ?", "%"+district+"%")
-	}
-	if officeType := c.Query("office_type"); officeType != "" {
-		query = query.Where("office_type = ?", officeType)
-	}
-	if city := c.Query("city"); city != "" {
-		query = query.Where("city ILIKE ?", "%"+city+"%")
+	query := filterRegionalCenters(c, h.DB.Model(&regionalCenter))
+	// Count every match before paging; a fresh session keeps Count out of the page query
+	if err := query.Session(&gorm.Session{}).Count(&totalCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search regional centers"})
+		return
 	}
-	query.Count(&totalCount)
+	query = query.Select(models.RegionalCenterColumns)
 
 
 	// Add pagination logic
@@ -153,40 +121,96 @@ func (h *RegionalCenterHandler) SearchRegionalCenters(c *gin.Context) {
 		"data":       centers,
 		"page":       page,
 		"pageSize":   pageSize,
-		"totalCount": result.RowsAffected, // Total results matching the query
+		"totalCount": totalCount, // Total results matching the query
 	})
 }
-// FindNearestCenters finds regional centers near a given location
+// FindNearestCenters returns the closest regional center offices to a point,
+// nearest first, limited to those within the search radius
 func (h *RegionalCenterHandler) FindNearestCenters(c *gin.Context) {
-    lat := c.Query("lat")
-    lng := c.Query("lng")
-    distance := c.DefaultQuery("distance", "16093.4") // Default: 10 miles in meters
-
-    if lat == "" || lng == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Latitude and longitude are required"})
-        return
-    }
-
-    var centers []struct {
-        ID       uint    `json:"id"`
-        Name     string  `json:"name"`
-        Distance float64 `json:"distance"`
-    }
-
-    	query := `
-		SELECT id, regional_center AS name, 
-		       ST_Y(location) AS latitude, ST_X(location) AS longitude,
-		       address || ', ' || COALESCE(suite, '') || ', ' || city || ', ' || state || ' ' || zip_code AS address,
-		       telephone AS phone, website, office_type AS type
-		FROM regional_centers
-		WHERE location IS NOT NULL
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid latitude is required"})
+		return
+	}
+
+	lng, err := strconv.ParseFloat(c.Query("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid longitude is required"})
+		return
+	}
+
+	// Radius in meters; the default is 10 miles
+	distance, err := strconv.ParseFloat(c.DefaultQuery("distance", "16093.4"), 64)
+	if err != nil || distance <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Distance must be a positive number of meters"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit < 1 || limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and 50"})
+		return
+	}
+
+	centers := []models.NearbyRegionalCenter{}
+	query := `
+		SELECT ` + models.NearbyRegionalCenterColumns + `
+		FROM regional_centers,
+		     (SELECT ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography AS point) AS search
+		WHERE geog IS NOT NULL
+		  AND deleted_at IS NULL
+		  AND ST_DWithin(geog, search.point, ?)
+		ORDER BY geog <-> search.point
+		LIMIT ?
 	`
 
+	if err := h.DB.Raw(query, lng, lat, distance, limit).Scan(&centers).Error; err != nil {
+		fmt.Printf("Error finding nearest centers: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find nearest centers"})
+		return
+	}
+
+	for i := range centers {
+		centers[i].SetDistanceMiles()
+	}
+
+	c.JSON(http.StatusOK, centers)
+}
 
-if err := h.DB.Raw(query, lat, lng, distance).Scan(&centers).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find nearest centers"})
-        return
-    }
+// filterRegionalCenters applies the search filters shared by search and export
+func filterRegionalCenters(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if county := c.Query("county"); county != "" {
+		query = query.Where("county_served ILIKE ?", "%"+county+"%")
+	}
+	if district := c.Query("district"); district != "" {
+		query = query.Where("los_angeles_health_district ILIKE ?", "%"+district+"%")
+	}
+	if officeType := c.Query("office_type"); officeType != "" {
+		query = query.Where("office_type = ?", officeType)
+	}
+	if city := c.Query("city"); city != "" {
+		query = query.Where("city ILIKE ?", "%"+city+"%")
+	}
+	return query
+}
 
-    c.JSON(http.StatusOK, centers)
-}
\ No newline at end of file
+// ExportRegionalCenters downloads the offices matching the search filters as CSV, XLSX or KML
+func (h *RegionalCenterHandler) ExportRegionalCenters(c *gin.Context) {
+	query := filterRegionalCenters(c, h.DB.Model(&models.RegionalCenter{})).
+		Select(models.RegionalCenterColumns).
+		Order("regional_center, id")
+
+	columns := []string{"Regional Center", "Office Type", "Address", "Suite", "City", "State", "ZIP",
+		"Telephone", "Website", "County Served", "LA Health District", "Latitude", "Longitude"}
+	streamExport(c, query, "regional-centers", columns, func(center models.RegionalCenter) export.Row {
+		return export.Row{
+			Name: center.RegionalCenter,
+			Values: []string{center.RegionalCenter, center.OfficeType, center.Address, center.Suite,
+				center.City, center.State, center.ZipCode, center.Telephone, center.Website,
+				center.CountyServed, center.LosAngelesHealthDistrict,
+				formatCoordinate(center.Latitude), formatCoordinate(center.Longitude)},
+			Latitude:  center.Latitude,
+			Longitude: center.Longitude,
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetRegionalCenterByIDRejectsInvalidID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/regional-centers/:id", NewRegionalCenterHandler(nil).GetRegionalCenterByID)
+
+	for _, id := range []string{"1%20OR%201=1", "abc", "1.5"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/regional-centers/"+id, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", id, w.Code)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"bac/internal/audit"
+	"bac/internal/export"
 	"bac/internal/models"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 	"gorm.io/gorm"
 	"net/http"
+	"strings"
 	"github.com/google/uuid"
 
 )
@@ -33,10 +36,18 @@ func (h *ResourceHandler) CreateResource(c *gin.Context) {
 		Latitude:    input.Latitude,
 		Longitude:   input.Longitude,
 		Diagnoses:   pq.StringArray(input.Diagnoses),
+		CreatedBy:   actorID(c),
+		UpdatedBy:   actorID(c),
 	}
 
-	if result := h.DB.Create(&resource); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&resource).Error; err != nil {
+			return err
+		}
+		return auditResource(tx, audit.OpCreate, actorID(c), nil, resource.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -49,6 +60,8 @@ func (h *ResourceHandler) CreateResource(c *gin.Context) {
 		Latitude:    resource.Latitude,
 		Longitude:   resource.Longitude,
 		Diagnoses:   input.Diagnoses,
+		CreatedBy:   resource.CreatedBy,
+		UpdatedBy:   resource.UpdatedBy,
 		CreatedAt:   resource.CreatedAt,
 		UpdatedAt:   resource.UpdatedAt,
 	}
@@ -81,9 +94,17 @@ func (h *ResourceHandler) UpdateResource(c *gin.Context) {
 		Latitude:    input.Latitude,
 		Longitude:   input.Longitude,
 		Diagnoses:   pq.StringArray(input.Diagnoses),
+		UpdatedBy:   actorID(c),
 	}
 
-	if result := h.DB.Model(&resource).Updates(updateData); result.Error != nil {
+	before := resource
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&resource).Updates(updateData).Error; err != nil {
+			return err
+		}
+		return auditResource(tx, audit.OpUpdate, actorID(c), &before, resource.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update resource"})
 		return
 	}
@@ -98,6 +119,8 @@ func (h *ResourceHandler) UpdateResource(c *gin.Context) {
 		Latitude:    resource.Latitude,
 		Longitude:   resource.Longitude,
 		Diagnoses:   input.Diagnoses,
+		CreatedBy:   resource.CreatedBy,
+		UpdatedBy:   resource.UpdatedBy,
 		CreatedAt:   resource.CreatedAt,
 		UpdatedAt:   resource.UpdatedAt,
 	}
@@ -105,6 +128,23 @@ func (h *ResourceHandler) UpdateResource(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// auditResource records a change to a resource, reading its state after the
+// change from tx. before is nil for creates.
+func auditResource(tx *gorm.DB, op string, actor *int, before *models.Resource, id string) error {
+	change := audit.Change{EntityType: audit.EntityResource, EntityID: id, Operation: op, ActorID: actor}
+	if before != nil {
+		change.Before = before
+	}
+	if op != audit.OpDelete {
+		var after models.Resource
+		if err := tx.First(&after, "id = ?", id).Error; err != nil {
+			return err
+		}
+		change.After = &after
+	}
+	return audit.Record(tx, change)
+}
+
 func (h *ResourceHandler) GetResource(c *gin.Context) {
     id := c.Param("id")
 
@@ -128,6 +168,8 @@ func (h *ResourceHandler) GetResource(c *gin.Context) {
         Latitude:    resource.Latitude,
         Longitude:   resource.Longitude,
         Diagnoses:   []string(resource.Diagnoses),
+        CreatedBy:   resource.CreatedBy,
+        UpdatedBy:   resource.UpdatedBy,
         CreatedAt:   resource.CreatedAt,
         UpdatedAt:   resource.UpdatedAt,
     }
@@ -160,17 +202,31 @@ func (h *ResourceHandler) DeleteResource(c *gin.Context) {
 		return
 	}
 
-	// Delete the resource
-	if result := h.DB.Delete(&resource); result.Error != nil {
+	// Move the resource to the trash, keeping its last state in the audit log
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&resource).UpdateColumn("deleted_by", actorID(c)).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&resource).Error; err != nil {
+			return err
+		}
+		return auditResource(tx, audit.OpDelete, actorID(c), &resource, resource.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete resource"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Resource deleted successfully"})
 }
+// GetResources retrieves all resources, or those inside ?bbox=
 func (h *ResourceHandler) GetResources(c *gin.Context) {
 	var resources []models.Resource
-	if result := h.DB.Find(&resources); result.Error != nil {
+	query, done := viewportQuery(c, h.DB, "resources")
+	if done {
+		return
+	}
+	if result := query.Find(&resources); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
@@ -186,11 +242,21 @@ func (h *ResourceHandler) GetResources(c *gin.Context) {
 			Latitude:    r.Latitude,
 			Longitude:   r.Longitude,
 			Diagnoses:   []string(r.Diagnoses), // Convert pq.StringArray to []string
+			CreatedBy:   r.CreatedBy,
+			UpdatedBy:   r.UpdatedBy,
 			CreatedAt:   r.CreatedAt,
 			UpdatedAt:   r.UpdatedAt,
 		}
 	}
 
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, len(response), func(i int) (gin.H, error) {
+			r := response[i]
+			return geoJSONFeature(r.ID, pointGeometry(&r.Latitude, &r.Longitude), r)
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
@@ -210,7 +276,22 @@ func (h *ResourceHandler) CreateResourceCenter(c *gin.Context) {
 		return
 	}
 
-	if err := h.DB.Create(&center).Error; err != nil {
+	center.CreatedBy = actorID(c)
+	center.UpdatedBy = actorID(c)
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&center).Error; err != nil {
+			return err
+		}
+		after, err := audit.Load(tx, audit.EntityResourceCenter, center.ID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{
+			EntityType: audit.EntityResourceCenter, EntityID: center.ID, Operation: audit.OpCreate, ActorID: actorID(c), After: after,
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -248,3 +329,22 @@ func (h *ResourceHandler) GetResourceCenters(c *gin.Context) {
 
 	c.JSON(http.StatusOK, centers)
 }
+
+// ExportResources downloads resources, optionally inside ?bbox=, as CSV, XLSX or KML
+func (h *ResourceHandler) ExportResources(c *gin.Context) {
+	query, done := viewportQuery(c, h.DB.Model(&models.Resource{}), "resources")
+	if done {
+		return
+	}
+
+	columns := []string{"Name", "Description", "Address", "Diagnoses", "Latitude", "Longitude"}
+	streamExport(c, query.Order("name, id"), "resources", columns, func(r models.Resource) export.Row {
+		return export.Row{
+			Name: r.Name,
+			Values: []string{r.Name, r.Description, r.Address, strings.Join(r.Diagnoses, ", "),
+				formatCoordinate(&r.Latitude), formatCoordinate(&r.Longitude)},
+			Latitude:  &r.Latitude,
+			Longitude: &r.Longitude,
+		}
+	})
+}
@@ -0,0 +1,101 @@
+// internal/api/handlers/tiles_handler.go
+
+package handlers
+
+import (
+	"bac/internal/maplayers"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tileMaxAge is how long browsers and CDNs may reuse a tile, in seconds.
+// Directory edits show up on the map within this window.
+const tileMaxAge = 300
+
+// TilesHandler serves directory layers as Mapbox Vector Tiles
+type TilesHandler struct {
+	DB     *gorm.DB
+	layers *maplayers.Service
+}
+
+// NewTilesHandler creates a new TilesHandler instance
+func NewTilesHandler(db *gorm.DB) *TilesHandler {
+	return &TilesHandler{DB: db, layers: maplayers.NewService(db)}
+}
+
+// GetLayers lists the tile layers with the fields and filters each accepts
+func (h *TilesHandler) GetLayers(c *gin.Context) {
+	layers := make([]gin.H, 0, len(maplayers.Layers))
+	for _, name := range maplayers.Names() {
+		layer := maplayers.Layers[name]
+		layers = append(layers, gin.H{
+			"name":    name,
+			"tiles":   fmt.Sprintf("/api/tiles/%s/{z}/{x}/{y}.pbf", name),
+			"fields":  layer.FieldNames(),
+			"filters": layer.FilterNames(),
+			"maxzoom": maplayers.MaxZoom,
+		})
+	}
+	c.JSON(http.StatusOK, layers)
+}
+
+// GetTile returns one vector tile for /tiles/:layer/:z/:x/:y.pbf. ?fields=
+// limits the feature properties and filter parameters narrow the features.
+func (h *TilesHandler) GetTile(c *gin.Context) {
+	layer, err := maplayers.Get(c.Param("layer"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	z, zErr := strconv.Atoi(c.Param("z"))
+	x, xErr := strconv.Atoi(c.Param("x"))
+	y, yErr := strconv.Atoi(strings.TrimSuffix(c.Param("y"), ".pbf"))
+	if zErr != nil || xErr != nil || yErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tile coordinates must be integers"})
+		return
+	}
+
+	req := maplayers.TileRequest{Z: z, X: x, Y: y, Filters: layerFilters(c, layer)}
+	if fields := c.Query("fields"); fields != "" {
+		req.Fields = strings.Split(fields, ",")
+	}
+
+	tile, err := h.layers.Tile(layer, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, maplayers.ErrInvalidTile),
+			errors.Is(err, maplayers.ErrUnknownField),
+			errors.Is(err, maplayers.ErrUnknownFilter):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			fmt.Printf("Error rendering tile %s/%d/%d/%d: %v\n", layer.Name, z, x, y, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render tile"})
+		}
+		return
+	}
+
+	sum := sha1.Sum(tile)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", tileMaxAge))
+	c.Header("ETag", etag)
+	if match := c.GetHeader("If-None-Match"); match == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// Map clients treat 204 as an empty tile and skip decoding it
+	if len(tile) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.Data(http.StatusOK, "application/vnd.mapbox-vector-tile", tile)
+}
@@ -0,0 +1,69 @@
+// internal/api/handlers/trash_handler.go
+
+package handlers
+
+import (
+	"bac/internal/trash"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TrashHandler lets admins review and restore deleted directory records
+type TrashHandler struct {
+	trash *trash.Service
+}
+
+// NewTrashHandler creates a new TrashHandler instance
+func NewTrashHandler(db *gorm.DB, retention time.Duration) *TrashHandler {
+	return &TrashHandler{trash: trash.NewService(db, retention)}
+}
+
+// GetTrash lists deleted records, most recently deleted first, optionally
+// narrowed to one ?entity_type=, with ?limit= and ?offset= paging
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	items, total, err := h.trash.List(c.Query("entity_type"), limit, offset)
+	if err != nil {
+		if errors.Is(err, trash.ErrInvalidEntityType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Printf("Error listing trash: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"total": total, "items": items})
+}
+
+// RestoreItem takes a deleted record out of the trash
+func (h *TrashHandler) RestoreItem(c *gin.Context) {
+	entityType, id := c.Param("entity_type"), c.Param("id")
+
+	if err := h.trash.Restore(entityType, id, actorID(c)); err != nil {
+		switch {
+		case errors.Is(err, trash.ErrInvalidEntityType):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, trash.ErrNotInTrash):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			fmt.Printf("Error restoring %s %s: %v\n", entityType, id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore record"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Record restored successfully",
+	})
+}
@@ -0,0 +1,164 @@
+// internal/api/handlers/verification_handler.go
+
+package handlers
+
+import (
+	"bac/internal/verification"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerificationHandler serves the queue of directory records flagged for re-verification
+type VerificationHandler struct {
+	verification *verification.Service
+}
+
+// NewVerificationHandler creates a new VerificationHandler instance
+func NewVerificationHandler(service *verification.Service) *VerificationHandler {
+	return &VerificationHandler{verification: service}
+}
+
+// GetTasks lists the queue. Filters: ?status= (open and assigned by default,
+// "all" for everything), ?entity_type=, ?reason=, ?assigned_to= (a user ID or "me"),
+// with ?limit= (default 50, at most 500) and ?offset= paging.
+func (h *VerificationHandler) GetTasks(c *gin.Context) {
+	filter := verification.TaskFilter{
+		EntityType: c.Query("entity_type"),
+		Reason:     c.Query("reason"),
+	}
+	switch status := c.Query("status"); status {
+	case "":
+		// The default view is the work still to do
+		filter.Statuses = []string{verification.StatusOpen, verification.StatusAssigned}
+	case "all":
+	case verification.StatusOpen, verification.StatusAssigned, verification.StatusCompleted:
+		filter.Statuses = []string{status}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, assigned, completed or all"})
+		return
+	}
+	if filter.EntityType != "" && !verification.IsEntityType(filter.EntityType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": verification.ErrInvalidEntityType.Error()})
+		return
+	}
+
+	switch assignee := c.Query("assigned_to"); assignee {
+	case "":
+	case "me":
+		filter.AssignedTo = actorID(c)
+		if filter.AssignedTo == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "assigned_to=me needs a signed-in user"})
+			return
+		}
+	default:
+		id, err := strconv.Atoi(assignee)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "assigned_to must be a user ID or me"})
+			return
+		}
+		filter.AssignedTo = &id
+	}
+
+	var ok bool
+	if filter.Limit, filter.Offset, ok = pageParams(c); !ok {
+		return
+	}
+
+	tasks, total, err := h.verification.Tasks(filter)
+	if err != nil {
+		fmt.Printf("Error listing verification tasks: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve verification tasks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"total": total, "tasks": tasks})
+}
+
+// AssignTask assigns a task to the user in the body, or to the caller when no user is given
+func (h *VerificationHandler) AssignTask(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input struct {
+		UserID *int `json:"userId"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.UserID == nil {
+		input.UserID = actorID(c)
+	}
+	if input.UserID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "userId is required"})
+		return
+	}
+
+	task, err := h.verification.Assign(id, *input.UserID)
+	if err != nil {
+		h.taskFailed(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// CompleteTask closes a task with an outcome of verified, updated or dismissed
+func (h *VerificationHandler) CompleteTask(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input verification.Completion
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.verification.Complete(id, input, actorID(c))
+	if err != nil {
+		h.taskFailed(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *VerificationHandler) taskFailed(c *gin.Context, id int64, err error) {
+	switch {
+	case errors.Is(err, verification.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, verification.ErrTaskCompleted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, verification.ErrUserNotFound),
+		errors.Is(err, verification.ErrInvalidOutcome):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("Error updating verification task %d: %v\n", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update verification task"})
+	}
+}
+
+// RunScan runs the stale-record scan now instead of waiting for the schedule
+func (h *VerificationHandler) RunScan(c *gin.Context) {
+	summary, err := h.verification.Scan(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, verification.ErrScanRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Printf("Error running verification scan: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Verification scan failed"})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
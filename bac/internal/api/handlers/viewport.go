@@ -0,0 +1,76 @@
+// internal/api/handlers/viewport.go
+
+package handlers
+
+import (
+	"bac/internal/maplayers"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// viewportQuery applies ?bbox= to a list query for the named map layer. With
+// ?cluster=true&zoom=N it answers with clusters itself and reports done.
+func viewportQuery(c *gin.Context, db *gorm.DB, layerName string) (*gorm.DB, bool) {
+	layer, err := maplayers.Get(layerName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, true
+	}
+
+	var bbox *maplayers.BBox
+	if value := c.Query("bbox"); value != "" {
+		if bbox, err = maplayers.ParseBBox(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, true
+		}
+	}
+
+	if c.Query("cluster") == "true" {
+		zoom, err := strconv.Atoi(c.Query("zoom"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": maplayers.ErrInvalidZoom.Error()})
+			return nil, true
+		}
+		clusters, err := maplayers.NewService(db).Clusters(layer, maplayers.ClusterRequest{
+			Zoom:    zoom,
+			BBox:    bbox,
+			Filters: layerFilters(c, layer),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, maplayers.ErrInvalidZoom), errors.Is(err, maplayers.ErrUnknownFilter):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				fmt.Printf("Error clustering %s: %v\n", layerName, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cluster " + layerName})
+			}
+			return nil, true
+		}
+		c.JSON(http.StatusOK, clusters)
+		return nil, true
+	}
+
+	if bbox != nil {
+		clause, args := bbox.Condition(layer)
+		db = db.Where(clause, args...)
+	}
+	return db, false
+}
+
+// layerFilters collects the query parameters that are filters on layer. Clients
+// share one set of map parameters across layers, so other layers' filters are
+// ignored rather than rejected.
+func layerFilters(c *gin.Context, layer *maplayers.Layer) map[string]string {
+	filters := map[string]string{}
+	for name, values := range c.Request.URL.Query() {
+		if _, ok := layer.Filters[name]; ok && len(values) > 0 {
+			filters[name] = values[0]
+		}
+	}
+	return filters
+}
@@ -0,0 +1,84 @@
+// internal/api/handlers/waitlist_handler.go
+
+package handlers
+
+import (
+	"bac/internal/models"
+	"bac/internal/waitlist"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WaitlistHandler records ABA center waitlist checks and serves their history
+type WaitlistHandler struct {
+	DB       *gorm.DB
+	waitlist *waitlist.Service
+}
+
+// NewWaitlistHandler creates a new WaitlistHandler instance
+func NewWaitlistHandler(db *gorm.DB) *WaitlistHandler {
+	return &WaitlistHandler{DB: db, waitlist: waitlist.NewService(db)}
+}
+
+// GetWaitlistHistory lists a center's waitlist checks, most recent first.
+// ?limit= caps the number of entries (default 50, at most 500).
+func (h *WaitlistHandler) GetWaitlistHistory(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ABA center ID"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	entries, err := h.waitlist.History(id, limit)
+	if err != nil {
+		if errors.Is(err, waitlist.ErrCenterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Printf("Error listing waitlist history for %s: %v\n", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve waitlist history"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// RecordWaitlist adds a waitlist check for a center and updates its current status
+func (h *WaitlistHandler) RecordWaitlist(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ABA center ID"})
+		return
+	}
+
+	var input models.WaitlistRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.waitlist.RecordForCenter(id, input, actorID(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, waitlist.ErrCenterNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, waitlist.ErrFutureCheck):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			fmt.Printf("Error recording waitlist for %s: %v\n", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record waitlist check"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"bac/internal/auth"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerMFARoutes registers the second login step and TOTP self-service routes
+func (s *Server) registerMFARoutes(authService *auth.AuthService, mfaService *auth.MFAService, throttler *auth.LoginThrottler) {
+	// parseChallenge binds the request, validates the challenge token and checks the lockout
+	parseChallenge := func(c *gin.Context) (*auth.MFALoginRequest, *auth.ChallengeClaims, bool) {
+		var req auth.MFALoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, nil, false
+		}
+
+		challenge, err := mfaService.ParseChallenge(req.MFAToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA challenge"})
+			return nil, nil, false
+		}
+
+		if err := throttler.Check(challenge.Email, c.ClientIP()); err != nil {
+			respondLocked(c, err)
+			return nil, nil, false
+		}
+		return &req, challenge, true
+	}
+
+	// Second login step for enrolled users
+	s.router.POST("/api/login/mfa", func(c *gin.Context) {
+		req, challenge, ok := parseChallenge(c)
+		if !ok {
+			return
+		}
+
+		tokens, err := authService.CompleteMFALogin(challenge, req.Code, clientInfo(c))
+		if err != nil {
+			respondMFAError(c, throttler, challenge.Email, err)
+			return
+		}
+
+		if err := throttler.RecordSuccess(challenge.Email); err != nil {
+			log.Println("Failed to reset login failures:", err)
+		}
+		c.JSON(http.StatusOK, tokens)
+	})
+
+	// Forced enrollment for users whose role requires MFA but who have not set it up
+	s.router.POST("/api/login/mfa/setup", func(c *gin.Context) {
+		_, challenge, ok := parseChallenge(c)
+		if !ok {
+			return
+		}
+		if !challenge.EnrollmentRequired {
+			c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+			return
+		}
+
+		userID, err := challenge.UserID()
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA challenge"})
+			return
+		}
+
+		setup, err := mfaService.Setup(userID)
+		if err != nil {
+			respondMFAError(c, throttler, challenge.Email, err)
+			return
+		}
+		c.JSON(http.StatusOK, setup)
+	})
+
+	s.router.POST("/api/login/mfa/enable", func(c *gin.Context) {
+		req, challenge, ok := parseChallenge(c)
+		if !ok {
+			return
+		}
+		if !challenge.EnrollmentRequired {
+			c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+			return
+		}
+
+		tokens, recoveryCodes, err := authService.CompleteMFAEnrollment(challenge, req.Code, clientInfo(c))
+		if err != nil {
+			respondMFAError(c, throttler, challenge.Email, err)
+			return
+		}
+
+		if err := throttler.RecordSuccess(challenge.Email); err != nil {
+			log.Println("Failed to reset login failures:", err)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"tokens":         tokens,
+			"recovery_codes": recoveryCodes,
+		})
+	})
+
+	// Self-service enrollment for signed-in users
+	mfa := s.router.Group("/api/mfa")
+	mfa.Use(s.middleware.AuthMiddleware, s.middleware.RequireUserSession)
+	{
+		mfa.GET("", func(c *gin.Context) {
+			userID := c.GetInt("userID")
+			enabled, err := mfaService.IsEnabled(userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get MFA status"})
+				return
+			}
+			required, err := mfaService.IsRequired(userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get MFA status"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"enabled": enabled, "required": required})
+		})
+
+		mfa.POST("/setup", func(c *gin.Context) {
+			setup, err := mfaService.Setup(c.GetInt("userID"))
+			if err != nil {
+				respondMFAError(c, throttler, c.GetString("email"), err)
+				return
+			}
+			c.JSON(http.StatusOK, setup)
+		})
+
+		mfa.POST("/enable", func(c *gin.Context) {
+			var req auth.MFACodeRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := throttler.Check(c.GetString("email"), c.ClientIP()); err != nil {
+				respondLocked(c, err)
+				return
+			}
+
+			recoveryCodes, err := mfaService.Enable(c.GetInt("userID"), req.Code)
+			if err != nil {
+				respondMFAError(c, throttler, c.GetString("email"), err)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+		})
+
+		mfa.POST("/disable", func(c *gin.Context) {
+			var req auth.MFACodeRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := throttler.Check(c.GetString("email"), c.ClientIP()); err != nil {
+				respondLocked(c, err)
+				return
+			}
+
+			if err := mfaService.Disable(c.GetInt("userID"), req.Code); err != nil {
+				respondMFAError(c, throttler, c.GetString("email"), err)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "MFA disabled successfully"})
+		})
+
+		mfa.POST("/recovery-codes", func(c *gin.Context) {
+			var req auth.MFACodeRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := throttler.Check(c.GetString("email"), c.ClientIP()); err != nil {
+				respondLocked(c, err)
+				return
+			}
+
+			recoveryCodes, err := mfaService.RegenerateRecoveryCodes(c.GetInt("userID"), req.Code)
+			if err != nil {
+				respondMFAError(c, throttler, c.GetString("email"), err)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+		})
+	}
+}
+
+// respondMFAError maps MFA errors onto HTTP status codes. Wrong codes count
+// towards the same lockout as wrong passwords.
+func respondMFAError(c *gin.Context, throttler *auth.LoginThrottler, email string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		if err := throttler.RecordFailure(email, c.ClientIP()); err != nil {
+			log.Println("Failed to record MFA failure:", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+	case errors.Is(err, auth.ErrInvalidMFAChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA challenge"})
+	case errors.Is(err, auth.ErrMFAEnrollmentPending):
+		c.JSON(http.StatusForbidden, gin.H{"error": "MFA enrollment is required before signing in"})
+	case errors.Is(err, auth.ErrMFANotEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA is not set up"})
+	case errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+	case errors.Is(err, auth.ErrMFARequiredByRole):
+		c.JSON(http.StatusForbidden, gin.H{"error": "MFA is required for one of your roles"})
+	case errors.Is(err, auth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		log.Println("MFA error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "MFA request failed"})
+	}
+}
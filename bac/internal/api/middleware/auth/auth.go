@@ -1,16 +1,26 @@
 package middleware
 
 import (
+	"bac/internal/auth"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-// AuthMiddleware creates a middleware that validates JWT tokens
-func AuthMiddleware(jwtSecret []byte) gin.HandlerFunc {
+// AuthMiddleware creates a middleware that accepts either a JWT in the
+// Authorization header or a partner key in the X-API-Key header. JWTs whose
+// session has been revoked are rejected; API keys are rate limited per key.
+func AuthMiddleware(tokens auth.TokenConfig, sessions auth.SessionValidator, apiKeys auth.APIKeyValidator, limiter *auth.RateLimiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey, apiKeys, limiter)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
@@ -25,35 +35,73 @@ func AuthMiddleware(jwtSecret []byte) gin.HandlerFunc {
 			return
 		}
 
-		// Extract the token
+		// Extract and verify the token
 		tokenString := authHeader[7:] // Remove "Bearer " prefix
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := auth.ParseAccessToken(tokens, tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
+		userID, err := claims.UserID()
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
 			c.Abort()
 			return
 		}
 
+		active, err := sessions.IsSessionActive(claims.SessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate session"})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// Set user info in context
-		c.Set("userID", int(claims["sub"].(float64)))
-		c.Set("email", claims["email"].(string))
-		c.Set("permissions", claims["permissions"])
+		c.Set("userID", userID)
+		c.Set("email", claims.Email)
+		c.Set("permissions", claims.Permissions)
+		c.Set("sessionID", claims.SessionID)
 
 		c.Next()
 	}
 }
 
+func authenticateAPIKey(c *gin.Context, apiKey string, apiKeys auth.APIKeyValidator, limiter *auth.RateLimiter) {
+	principal, err := apiKeys.ValidateAPIKey(apiKey, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate API key"})
+		}
+		c.Abort()
+		return
+	}
+
+	if ok, retryAfter := limiter.Allow(principal.KeyID, principal.RateLimitPerMinute); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "API key rate limit exceeded"})
+		c.Abort()
+		return
+	}
+
+	// Scopes are permission names, so RequirePermission works unchanged
+	if principal.UserID != nil {
+		c.Set("userID", *principal.UserID)
+	}
+	c.Set("apiKeyID", principal.KeyID)
+	c.Set("permissions", principal.Scopes)
+
+	c.Next()
+}
+
 // RequirePermission creates a middleware that checks if the user has a specific permission
 func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -64,7 +112,7 @@ func RequirePermission(permission string) gin.HandlerFunc {
 			return
 		}
 
-		permissions, ok := permissionsInterface.([]interface{})
+		permissions, ok := permissionsInterface.([]string)
 		if !ok {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid permissions format"})
 			c.Abort()
@@ -73,7 +121,7 @@ func RequirePermission(permission string) gin.HandlerFunc {
 
 		hasPermission := false
 		for _, p := range permissions {
-			if p.(string) == permission {
+			if p == permission {
 				hasPermission = true
 				break
 			}
@@ -87,4 +135,17 @@ func RequirePermission(permission string) gin.HandlerFunc {
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// RequireUserSession rejects requests authenticated with an API key. Use it on
+// account routes that only make sense for a signed-in person.
+func RequireUserSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := c.Get("apiKeyID"); isAPIKey {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a user login"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bac/internal/auth"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerOIDCRoutes registers single sign-on through the configured OpenID Connect provider.
+// The browser is sent back to the frontend with a one-time code in the URL fragment, which
+// the frontend exchanges for tokens, or an MFA challenge, at /api/oidc/token.
+func (s *Server) registerOIDCRoutes(authService *auth.AuthService) {
+	if s.config.OIDCDiscoveryURL == "" {
+		return
+	}
+
+	oidcService := auth.NewOIDCService(s.db, authService, auth.OIDCConfig{
+		DiscoveryURL: s.config.OIDCDiscoveryURL,
+		ClientID:     s.config.OIDCClientID,
+		ClientSecret: s.config.OIDCClientSecret,
+		RedirectURL:  s.config.OIDCRedirectURL,
+		Scopes:       s.config.OIDCScopes,
+		GroupsClaim:  s.config.OIDCGroupsClaim,
+		GroupRoles:   s.config.OIDCGroupRoles,
+		MFAValues:    s.config.OIDCMFAValues,
+	})
+
+	// The state cookie ties the callback to the browser that started the login
+	const stateCookie = "oidc_state"
+	secure := strings.HasPrefix(s.config.OIDCRedirectURL, "https://")
+	setStateCookie := func(c *gin.Context, value string, maxAge int) {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(stateCookie, value, maxAge, "/api/oidc", "", secure, true)
+	}
+
+	redirectError := func(c *gin.Context, code string) {
+		c.Redirect(http.StatusFound, s.config.FrontendURL+"/login?sso_error="+url.QueryEscape(code))
+	}
+
+	s.router.GET("/api/oidc/login", func(c *gin.Context) {
+		authURL, state, err := oidcService.AuthorizationURL()
+		if err != nil {
+			log.Println("OIDC login error:", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Single sign-on is unavailable"})
+			return
+		}
+		setStateCookie(c, state, int(auth.OIDCStateExpiry.Seconds()))
+		c.Redirect(http.StatusFound, authURL)
+	})
+
+	s.router.GET("/api/oidc/callback", func(c *gin.Context) {
+		browserState, _ := c.Cookie(stateCookie)
+		setStateCookie(c, "", -1)
+
+		if providerError := c.Query("error"); providerError != "" {
+			log.Println("OIDC provider error:", providerError, c.Query("error_description"))
+			redirectError(c, providerError)
+			return
+		}
+
+		state, code := c.Query("state"), c.Query("code")
+		if state == "" || code == "" {
+			redirectError(c, "invalid_request")
+			return
+		}
+
+		loginCode, err := oidcService.Callback(state, browserState, code)
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrInvalidOIDCState):
+				redirectError(c, "expired")
+			case errors.Is(err, auth.ErrOIDCEmailUnverified):
+				redirectError(c, "email_unverified")
+			case errors.Is(err, auth.ErrOIDCMFARequired):
+				redirectError(c, "mfa_required")
+			default:
+				log.Println("OIDC callback error:", err)
+				redirectError(c, "server_error")
+			}
+			return
+		}
+
+		fragment := url.Values{}
+		fragment.Set("code", loginCode)
+		c.Redirect(http.StatusFound, s.config.FrontendURL+"/auth/callback#"+fragment.Encode())
+	})
+
+	s.router.POST("/api/oidc/token", func(c *gin.Context) {
+		var req auth.OIDCCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := oidcService.Exchange(req.Code, clientInfo(c))
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidUserToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+				return
+			}
+			log.Println("OIDC code exchange error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+			return
+		}
+
+		// Accounts with MFA finish at /api/login/mfa like a password login
+		if result.Challenge != nil {
+			c.JSON(http.StatusOK, result.Challenge)
+			return
+		}
+		c.JSON(http.StatusOK, result.Tokens)
+	})
+}
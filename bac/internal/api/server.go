@@ -2,10 +2,16 @@ package api
 
 import (
 	"bac/internal/api/handlers"
+	"bac/internal/audit"
+	"bac/internal/auth"
 	authMiddleware "bac/internal/api/middleware/auth" // Import with alias
 	"bac/internal/config"
+	"bac/internal/geocode"
+	"bac/internal/mail"
+	"bac/internal/verification"
 	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
@@ -19,9 +25,15 @@ type Server struct {
 	db     *gorm.DB
 	config *config.Config
 	server *http.Server
+	sessions *auth.SessionService
+	apiKeys  *auth.APIKeyService
+	mailer   mail.Mailer
+	geocoder geocode.Geocoder
+	verifier *verification.Service
 	middleware struct {
 		AuthMiddleware    gin.HandlerFunc
 		RequirePermission func(string) gin.HandlerFunc
+		RequireUserSession gin.HandlerFunc
   }
 }
 
@@ -30,8 +42,12 @@ type Logger interface {
     // Other methods as needed
 }
 
-func NewServer(db *gorm.DB, cfg *config.Config) *Server {
+func NewServer(db *gorm.DB, cfg *config.Config, mailer mail.Mailer, geocoder geocode.Geocoder, verifier *verification.Service) *Server {
 	router := gin.Default()
+	// Only proxies we run may set the client IP that login throttling keys on
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted proxies:", err)
+	}
 
 	// Add CORS middleware
 	router.Use(cors.New(cors.Config{
@@ -39,7 +55,7 @@ func NewServer(db *gorm.DB, cfg *config.Config) *Server {
 		// AllowAllOrigins: true, // TEMPORARY: Allow all origins (use carefully in production)
 
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -48,29 +64,55 @@ func NewServer(db *gorm.DB, cfg *config.Config) *Server {
 		router: router,
 		db:     db,
 		config: cfg,
+		mailer: mailer,
+		geocoder: geocoder,
+		verifier: verifier,
 		server: &http.Server{
 			Addr:    ":" + cfg.Port,
 			Handler: router,
 		},
 	}
 	
+	server.sessions = auth.NewSessionService(db, cfg.RefreshTokenExpiry)
+	server.apiKeys = auth.NewAPIKeyService(db, cfg.APIKeyDefaultRateLimit)
+
 	// Initialize middleware
-	server.middleware.AuthMiddleware = authMiddleware.AuthMiddleware([]byte(cfg.JWTSecret))
+	server.middleware.AuthMiddleware = authMiddleware.AuthMiddleware(server.tokenConfig(), server.sessions, server.apiKeys, auth.NewRateLimiter())
 	server.middleware.RequirePermission = authMiddleware.RequirePermission
+	server.middleware.RequireUserSession = authMiddleware.RequireUserSession()
 		
 	// Register routes
 	server.RegisterAuthRoutes()
+	server.RegisterAdminRoutes()
+	server.RegisterAPIKeyRoutes()
 	server.setupRoutes()
 	return server
 }
 
+// tokenConfig builds the access token settings shared by login and the auth middleware
+func (s *Server) tokenConfig() auth.TokenConfig {
+	return auth.TokenConfig{
+		Secret:   []byte(s.config.JWTSecret),
+		Issuer:   s.config.JWTIssuer,
+		Audience: s.config.JWTAudience,
+		Expiry:   s.config.JWTExpiry,
+	}
+}
+
 // Rest of the file stays the same
 func (s *Server) setupRoutes() {
 	resourceHandler := handlers.NewResourceHandler(s.db)
 	geoHandler := handlers.NewGeolocationHandler(s.db)
 	regionalCenterHandler := handlers.NewRegionalCenterHandler(s.db)
-	abaCentersHandler := handlers.NewABACenterHandler(s.db)
+	abaCentersHandler := handlers.NewABACenterHandler(s.db, s.geocoder)
 	providersHandler := handlers.NewProvidersHandler(s.db)
+	catchmentHandler := handlers.NewCatchmentHandler(s.db)
+	tilesHandler := handlers.NewTilesHandler(s.db)
+	insuranceHandler := handlers.NewInsuranceHandler(s.db)
+	waitlistHandler := handlers.NewWaitlistHandler(s.db)
+	verificationHandler := handlers.NewVerificationHandler(s.verifier)
+	auditHandler := handlers.NewAuditHandler(s.db)
+	trashHandler := handlers.NewTrashHandler(s.db, s.config.TrashRetention)
 	api := s.router.Group("/api")
 	{
 		api.HEAD("/regional-centers", func(c *gin.Context) {
@@ -80,28 +122,74 @@ func (s *Server) setupRoutes() {
 		// Existing routes remain the same
 		api.GET("/resources/nearby", geoHandler.SearchNearby)
 		api.GET("/resources", resourceHandler.GetResources)
-		api.POST("/resources", resourceHandler.CreateResource)
+		api.GET("/resources/export", resourceHandler.ExportResources)
 		api.GET("/resources/:id", resourceHandler.GetResource)
-		api.PUT("/resources/:id", resourceHandler.UpdateResource)
-		api.DELETE("/resources/:id", resourceHandler.DeleteResource)
 		api.GET("/resource-center", resourceHandler.GetResourceCenters)
-		api.POST("/resource-center", resourceHandler.CreateResourceCenter)
 		api.GET("/resource-center/:id", resourceHandler.GetResourceCenterByID)
 
 		// Regional Centers routes - simplified and corrected
 		api.GET("/regional-centers", regionalCenterHandler.GetAllRegionalCenters)
 		api.GET("/regional-centers/search", regionalCenterHandler.SearchRegionalCenters)
 		api.GET("/regional-centers/nearest", regionalCenterHandler.FindNearestCenters)
+		api.GET("/regional-centers/catchment", catchmentHandler.LookupCatchment)
+		api.GET("/regional-centers/catchments", catchmentHandler.GetCatchments)
+		api.GET("/regional-centers/export", regionalCenterHandler.ExportRegionalCenters)
 		api.GET("/regional-centers/:id", regionalCenterHandler.GetRegionalCenterByID)
 
 		api.GET("/aba-centers", abaCentersHandler.GetABACenters)
-		api.POST("/aba-centers", abaCentersHandler.CreateABACenter)
 		api.GET("/aba-centers/search", abaCentersHandler.SearchABACenters)
+		api.GET("/aba-centers/export", abaCentersHandler.ExportABACenters)
 		api.GET("/aba-centers/:id", abaCentersHandler.GetABACenterByID)
-		api.PUT("/aba-centers/:id", abaCentersHandler.UpdateABACenter)
-		api.DELETE("/aba-centers/:id", abaCentersHandler.DeleteABACenter)
+		api.GET("/aba-centers/:id/waitlist-history", waitlistHandler.GetWaitlistHistory)
 
 		api.GET("/providers", providersHandler.GetProviders)
+		api.GET("/providers/export", providersHandler.ExportProviders)
+
+		// Reference lists for the insurance_ids and medi_cal_plan_ids filters
+		api.GET("/insurance-carriers", insuranceHandler.GetInsuranceCarriers)
+		api.GET("/medi-cal-plans", insuranceHandler.GetMediCalPlans)
+
+		// Vector tiles for the map; the y segment carries the .pbf extension
+		api.GET("/tiles", tilesHandler.GetLayers)
+		api.GET("/tiles/:layer/:z/:x/:y", tilesHandler.GetTile)
+
+		// Write routes require a signed-in user holding the matching permission
+		protected := api.Group("")
+		protected.Use(s.middleware.AuthMiddleware)
+		{
+			protected.POST("/resources", s.middleware.RequirePermission("write:resources"), resourceHandler.CreateResource)
+			protected.PUT("/resources/:id", s.middleware.RequirePermission("write:resources"), resourceHandler.UpdateResource)
+			protected.DELETE("/resources/:id", s.middleware.RequirePermission("delete:resources"), resourceHandler.DeleteResource)
+			protected.GET("/resources/:id/history", s.middleware.RequirePermission("read:audit"), auditHandler.GetHistory(audit.EntityResource))
+			protected.POST("/resources/:id/revert", s.middleware.RequirePermission("write:resources"), auditHandler.Revert(audit.EntityResource))
+			protected.POST("/resource-center", s.middleware.RequirePermission("write:resource-centers"), resourceHandler.CreateResourceCenter)
+
+			protected.POST("/aba-centers", s.middleware.RequirePermission("write:aba-centers"), abaCentersHandler.CreateABACenter)
+			protected.POST("/aba-centers/import", s.middleware.RequirePermission("write:aba-centers"), abaCentersHandler.ImportABACenters)
+			protected.PUT("/aba-centers/:id", s.middleware.RequirePermission("write:aba-centers"), abaCentersHandler.UpdateABACenter)
+			protected.DELETE("/aba-centers/:id", s.middleware.RequirePermission("delete:aba-centers"), abaCentersHandler.DeleteABACenter)
+			protected.POST("/aba-centers/:id/waitlist", s.middleware.RequirePermission("write:aba-centers"), waitlistHandler.RecordWaitlist)
+			protected.GET("/aba-centers/:id/history", s.middleware.RequirePermission("read:audit"), auditHandler.GetHistory(audit.EntityABACenter))
+			protected.POST("/aba-centers/:id/revert", s.middleware.RequirePermission("write:aba-centers"), auditHandler.Revert(audit.EntityABACenter))
+
+			protected.POST("/regional-centers/catchments/import", s.middleware.RequirePermission("manage:catchments"), catchmentHandler.ImportCatchments)
+			protected.POST("/zip-areas/import", s.middleware.RequirePermission("manage:catchments"), catchmentHandler.ImportZipAreas)
+
+			protected.GET("/insurance-reviews", s.middleware.RequirePermission("manage:insurance"), insuranceHandler.GetReviews)
+			protected.POST("/insurance-reviews/:id/resolve", s.middleware.RequirePermission("manage:insurance"), insuranceHandler.ResolveReview)
+
+			protected.GET("/audit-log", s.middleware.RequirePermission("read:audit"), auditHandler.GetAuditLog)
+
+			// Records flagged as stale or failing automated checks
+			protected.GET("/verification-tasks", s.middleware.RequirePermission("manage:verification"), verificationHandler.GetTasks)
+			protected.POST("/verification-tasks/scan", s.middleware.RequirePermission("manage:verification"), verificationHandler.RunScan)
+			protected.POST("/verification-tasks/:id/assign", s.middleware.RequirePermission("manage:verification"), verificationHandler.AssignTask)
+			protected.POST("/verification-tasks/:id/complete", s.middleware.RequirePermission("manage:verification"), verificationHandler.CompleteTask)
+
+			// Deleted records waiting to be purged; admin only
+			protected.GET("/trash", s.middleware.RequirePermission("manage:trash"), trashHandler.GetTrash)
+			protected.POST("/trash/:entity_type/:id/restore", s.middleware.RequirePermission("manage:trash"), trashHandler.RestoreItem)
+		}
 
 		// Debug route
 		api.GET("/routes", func(c *gin.Context) {
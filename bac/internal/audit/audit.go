@@ -0,0 +1,419 @@
+// Package audit keeps a field-level history of changes to directory records.
+// Writers call Record inside their transaction with the record as it was
+// before and after the change, read with Load; the history can then be listed
+// or reverted. Every directory table is audited, but only ABA centers and
+// resources can be reverted. The insurance carrier and Medi-Cal plan lists are
+// reference data and are not audited; a center's links to them are part of
+// the center's snapshot.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"bac/internal/insurance"
+	"bac/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Operations, matching audit_log.operation
+const (
+	OpCreate  = "create"
+	OpUpdate  = "update"
+	OpDelete  = "delete"
+	OpRevert  = "revert"
+	OpRestore = "restore"
+	OpPurge   = "purge"
+)
+
+// Entity types, named after their tables
+const (
+	EntityABACenter      = "aba_centers"
+	EntityResource       = "resources"
+	EntityRegionalCenter = "regional_centers"
+	EntityResourceCenter = "resource_centers"
+	EntityProvider       = "providers"
+)
+
+var (
+	ErrUnknownEntity   = errors.New("unknown entity type")
+	ErrNotRevertable   = errors.New("records of this type can't be reverted")
+	ErrVersionNotFound = errors.New("version not found in this record's history")
+	ErrDeletedVersion  = errors.New("that version is a deletion; revert to the version before it")
+	ErrNothingToRevert = errors.New("record already matches that version")
+)
+
+// ignoredFields change on every write, so they are kept in snapshots but left out of diffs
+var ignoredFields = map[string]bool{
+	"createdAt": true, "updatedAt": true, "created_at": true, "updated_at": true,
+	"updatedBy": true, "updated_by": true,
+}
+
+// entity describes how to load and restore one audited table
+type entity struct {
+	newRecord func() interface{}
+	// snapshot adds data kept outside the table to a loaded record
+	snapshot func(tx *gorm.DB, record interface{}) (interface{}, error)
+	// revertable tables have a revert route; their keys are UUIDs
+	revertable bool
+	// keep maps columns a revert leaves alone to their JSON field names. They
+	// are verification details that describe the record's upkeep, not its content.
+	keep map[string]string
+	// afterRevert brings derived data back in step with the restored record,
+	// given the version's snapshot
+	afterRevert func(tx *gorm.DB, record interface{}, state models.JSON) error
+}
+
+var entities = map[string]entity{
+	EntityABACenter: {
+		newRecord:  func() interface{} { return &models.ABACenter{} },
+		snapshot:   centerWithLinks,
+		revertable: true,
+		keep: map[string]string{
+			"waitlist_status":      "waitlistStatus",
+			"estimated_wait_weeks": "estimatedWaitWeeks",
+			"last_verified_at":     "lastVerifiedAt",
+			"record_verified_at":   "recordVerifiedAt",
+		},
+		afterRevert: restoreLinks,
+	},
+	EntityResource: {
+		newRecord:  func() interface{} { return &models.Resource{} },
+		revertable: true,
+		keep:       map[string]string{"record_verified_at": "record_verified_at"},
+	},
+	EntityRegionalCenter: {
+		newRecord: func() interface{} { return &models.RegionalCenter{} },
+	},
+	EntityResourceCenter: {
+		newRecord: func() interface{} { return &models.ResourceCenter{} },
+	},
+	EntityProvider: {
+		newRecord: func() interface{} { return &models.Provider{} },
+	},
+}
+
+// centerSnapshot is an ABA center with the carriers and plans it is linked
+// to, so mappings show in its history and a revert can restore them
+type centerSnapshot struct {
+	*models.ABACenter
+	InsuranceCarrierIDs []int `json:"insuranceCarrierIds"`
+	MediCalPlanIDs      []int `json:"mediCalPlanIds"`
+}
+
+func centerWithLinks(tx *gorm.DB, record interface{}) (interface{}, error) {
+	center := record.(*models.ABACenter)
+	carriers, plans, err := insurance.Links(tx, center.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &centerSnapshot{ABACenter: center, InsuranceCarrierIDs: carriers, MediCalPlanIDs: plans}, nil
+}
+
+// restoreLinks puts back the links a version recorded. Versions from before
+// links were recorded have them parsed from the text again.
+func restoreLinks(tx *gorm.DB, record interface{}, state models.JSON) error {
+	center := record.(*models.ABACenter)
+	var links struct {
+		InsuranceCarrierIDs *[]int `json:"insuranceCarrierIds"`
+		MediCalPlanIDs      *[]int `json:"mediCalPlanIds"`
+	}
+	if err := json.Unmarshal(state, &links); err != nil {
+		return err
+	}
+	req := models.ABACenterRequest{
+		InsuranceAccepted:   center.InsuranceAccepted,
+		MediCalPlans:        center.MediCalPlans,
+		InsuranceCarrierIDs: links.InsuranceCarrierIDs,
+		MediCalPlanIDs:      links.MediCalPlanIDs,
+	}
+	return insurance.NewService(tx).Sync(tx, center.ID, req, true)
+}
+
+// FieldChange is one field's value before and after a change
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Change describes a write to record
+type Change struct {
+	EntityType string
+	EntityID   string
+	Operation  string
+	ActorID    *int
+	// Before is nil for creates and After is nil for deletes
+	Before       interface{}
+	After        interface{}
+	RevertedFrom *int64
+}
+
+// Record writes an audit entry inside tx. Updates that change nothing but
+// bookkeeping fields are not recorded.
+func Record(tx *gorm.DB, change Change) error {
+	before, beforeFields, err := snapshot(change.Before)
+	if err != nil {
+		return err
+	}
+	after, afterFields, err := snapshot(change.After)
+	if err != nil {
+		return err
+	}
+
+	diff := Diff(beforeFields, afterFields)
+	if len(diff) == 0 && change.Operation == OpUpdate {
+		return nil
+	}
+	changes, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.AuditEntry{
+		EntityType:   change.EntityType,
+		EntityID:     change.EntityID,
+		Operation:    change.Operation,
+		ActorID:      change.ActorID,
+		Changes:      changes,
+		BeforeState:  before,
+		AfterState:   after,
+		RevertedFrom: change.RevertedFrom,
+	}).Error
+}
+
+// snapshot renders a record as its API JSON and as a field map for diffing
+func snapshot(record interface{}) (models.JSON, map[string]interface{}, error) {
+	if record == nil || reflect.ValueOf(record).Kind() == reflect.Ptr && reflect.ValueOf(record).IsNil() {
+		return nil, nil, nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, nil, fmt.Errorf("snapshot: %w", err)
+	}
+	fields, err := decodeFields(data)
+	return data, fields, err
+}
+
+func decodeFields(data []byte) (map[string]interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	return fields, nil
+}
+
+// Diff lists the fields whose values differ, sorted by name. A nil map stands
+// for a record that does not exist, so every field shows as added or removed.
+func Diff(before, after map[string]interface{}) []FieldChange {
+	names := map[string]bool{}
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	changes := []FieldChange{}
+	for name := range names {
+		if ignoredFields[name] {
+			continue
+		}
+		b, a := before[name], after[name]
+		if !reflect.DeepEqual(b, a) {
+			changes = append(changes, FieldChange{Field: name, Before: b, After: a})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// Service reads and reverts the history
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a new Service instance
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// IsEntityType reports whether name is an audited table
+func IsEntityType(name string) bool {
+	_, ok := entities[name]
+	return ok
+}
+
+// Load reads a record the way the audit log snapshots it. Pass tx.Unscoped()
+// to read records in the trash.
+func Load(tx *gorm.DB, entityType, id string) (interface{}, error) {
+	e, ok := entities[entityType]
+	if !ok {
+		return nil, ErrUnknownEntity
+	}
+	record := e.newRecord()
+	if err := tx.First(record, "id::text = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if e.snapshot != nil {
+		return e.snapshot(tx, record)
+	}
+	return record, nil
+}
+
+// Filter narrows the audit log listing; empty fields match everything
+type Filter struct {
+	EntityType string
+	EntityID   string
+	ActorID    *int
+	Limit      int
+	Offset     int
+}
+
+// Entries lists audit entries, newest first
+func (s *Service) Entries(filter Filter) ([]models.AuditEntry, error) {
+	query := s.db.Model(&models.AuditEntry{})
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	entries := []models.AuditEntry{}
+	err := query.Order("id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&entries).Error
+	return entries, err
+}
+
+// Revert restores a record to the state it had after the given history entry,
+// taking it out of the trash or recreating it if it has since been deleted.
+// The revert is itself recorded.
+func (s *Service) Revert(entityType string, id uuid.UUID, version int64, actorID *int) (*models.AuditEntry, error) {
+	e, ok := entities[entityType]
+	if !ok {
+		return nil, ErrUnknownEntity
+	}
+	if !e.revertable {
+		return nil, ErrNotRevertable
+	}
+
+	var result models.AuditEntry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var entry models.AuditEntry
+		err := tx.Where("id = ? AND entity_type = ? AND entity_id = ?", version, entityType, id.String()).
+			First(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrVersionNotFound
+		} else if err != nil {
+			return err
+		}
+		if len(entry.AfterState) == 0 {
+			return ErrDeletedVersion
+		}
+
+		// Soft deleted records are loaded too so they can be brought back
+		var current interface{}
+		err = tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(e.newRecord(), "id = ?", id).Error
+		if err == nil {
+			current, err = Load(tx.Unscoped(), entityType, id.String())
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			current = nil
+		} else if err != nil {
+			return err
+		}
+		var trashed int64
+		if current != nil {
+			err := tx.Unscoped().Table(entityType).Where("id = ? AND deleted_at IS NOT NULL", id).Count(&trashed).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		target := e.newRecord()
+		if err := json.Unmarshal(entry.AfterState, target); err != nil {
+			return fmt.Errorf("version %d: %w", version, err)
+		}
+		if current != nil && trashed == 0 {
+			_, currentFields, err := snapshot(current)
+			if err != nil {
+				return err
+			}
+			targetFields, err := decodeFields(entry.AfterState)
+			if err != nil {
+				return err
+			}
+			for _, field := range e.keep {
+				delete(currentFields, field)
+				delete(targetFields, field)
+			}
+			// Older versions may not hold every field a snapshot has now
+			for field := range currentFields {
+				if _, ok := targetFields[field]; !ok {
+					delete(currentFields, field)
+				}
+			}
+			if len(Diff(currentFields, targetFields)) == 0 {
+				return ErrNothingToRevert
+			}
+		}
+
+		omit := []string{clause.Associations}
+		for column := range e.keep {
+			omit = append(omit, column)
+		}
+		// target has no deletion time, so saving it also clears deleted_at
+		if current != nil {
+			err = tx.Unscoped().Omit(omit...).Save(target).Error
+		} else {
+			err = tx.Omit(omit...).Create(target).Error
+		}
+		if err != nil {
+			return err
+		}
+		err = tx.Table(entityType).Where("id = ?", id).
+			UpdateColumns(map[string]interface{}{"updated_by": actorID, "deleted_by": nil}).Error
+		if err != nil {
+			return err
+		}
+		if e.afterRevert != nil {
+			if err := e.afterRevert(tx, target, entry.AfterState); err != nil {
+				return err
+			}
+		}
+
+		restored, err := Load(tx, entityType, id.String())
+		if err != nil {
+			return err
+		}
+		err = Record(tx, Change{
+			EntityType:   entityType,
+			EntityID:     id.String(),
+			Operation:    OpRevert,
+			ActorID:      actorID,
+			Before:       current,
+			After:        restored,
+			RevertedFrom: &entry.ID,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Where("entity_type = ? AND entity_id = ?", entityType, id.String()).
+			Order("id DESC").First(&result).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
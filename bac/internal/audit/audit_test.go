@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"bac/internal/models"
+	"bac/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// testRecord has the kinds of fields directory records carry
+type testRecord struct {
+	Name      string      `json:"name"`
+	Tags      []string    `json:"tags"`
+	Address   testAddress `json:"address"`
+	Meta      models.JSON `json:"meta"`
+	Phone     *string     `json:"phone"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
+
+type testAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+func fieldsOf(t *testing.T, record interface{}) map[string]interface{} {
+	t.Helper()
+	_, fields, err := snapshot(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func TestDiff(t *testing.T) {
+	phone := "5595550100"
+	base := testRecord{
+		Name:      "Center",
+		Tags:      []string{"aba", "speech"},
+		Address:   testAddress{City: "Fresno", Zip: "93701"},
+		Meta:      models.JSON(`{"hours": {"mon": "9-5"}, "beds": 4}`),
+		UpdatedAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name   string
+		change func(r *testRecord)
+		want   []string
+	}{
+		{"no change", func(r *testRecord) {}, nil},
+		{"bookkeeping only", func(r *testRecord) { r.UpdatedAt = r.UpdatedAt.Add(time.Hour) }, nil},
+		{"top level field", func(r *testRecord) { r.Name = "Renamed" }, []string{"name"}},
+		{"nested struct field", func(r *testRecord) { r.Address.Zip = "93702" }, []string{"address"}},
+		{"slice order", func(r *testRecord) { r.Tags = []string{"speech", "aba"} }, []string{"tags"}},
+		{"nil to set pointer", func(r *testRecord) { r.Phone = &phone }, []string{"phone"}},
+		{"JSON value nested deep", func(r *testRecord) { r.Meta = models.JSON(`{"hours": {"mon": "9-6"}, "beds": 4}`) }, []string{"meta"}},
+		{"JSON key order and spacing", func(r *testRecord) { r.Meta = models.JSON(`{"beds":4,"hours":{"mon":"9-5"}}`) }, nil},
+		{"several fields sorted", func(r *testRecord) { r.Name, r.Address.City = "Renamed", "Clovis" }, []string{"address", "name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := base
+			tt.change(&after)
+			changes := Diff(fieldsOf(t, &base), fieldsOf(t, &after))
+
+			got := []string{}
+			for _, c := range changes {
+				got = append(got, c.Field)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("changed fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffNestedValues(t *testing.T) {
+	before := testRecord{Address: testAddress{City: "Fresno", Zip: "93701"}}
+	after := testRecord{Address: testAddress{City: "Fresno", Zip: "93702"}}
+
+	changes := Diff(fieldsOf(t, &before), fieldsOf(t, &after))
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(changes))
+	}
+	want := FieldChange{
+		Field:  "address",
+		Before: map[string]interface{}{"city": "Fresno", "zip": "93701"},
+		After:  map[string]interface{}{"city": "Fresno", "zip": "93702"},
+	}
+	if !reflect.DeepEqual(changes[0], want) {
+		t.Errorf("got %+v, want %+v", changes[0], want)
+	}
+}
+
+func TestDiffCreateAndDelete(t *testing.T) {
+	record := fieldsOf(t, &testRecord{Name: "Center", Tags: []string{"aba"}})
+	// Null fields and bookkeeping fields are left out of a create
+	got := []string{}
+	for _, c := range Diff(nil, record) {
+		got = append(got, c.Field)
+	}
+	if want := []string{"address", "name", "tags"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("create lists %v, want %v", got, want)
+	}
+	for _, c := range Diff(record, nil) {
+		if c.After != nil {
+			t.Errorf("delete of %s has after value %v", c.Field, c.After)
+		}
+	}
+}
+
+func TestRevertRestoresChangedField(t *testing.T) {
+	tx := testutil.Tx(t)
+
+	resource := models.Resource{Name: "Original", Description: "Kept", Address: "1 Main St"}
+	if err := tx.Create(&resource).Error; err != nil {
+		t.Fatalf("create resource: %v", err)
+	}
+	if err := Record(tx, Change{EntityType: EntityResource, EntityID: resource.ID, Operation: OpCreate, After: &resource}); err != nil {
+		t.Fatalf("record create: %v", err)
+	}
+	var created models.AuditEntry
+	if err := tx.Where("entity_type = ? AND entity_id = ?", EntityResource, resource.ID).First(&created).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	before := resource
+	if err := tx.Model(&resource).Update("name", "Renamed").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := Record(tx, Change{EntityType: EntityResource, EntityID: resource.ID, Operation: OpUpdate, Before: &before, After: &resource}); err != nil {
+		t.Fatalf("record update: %v", err)
+	}
+
+	id := uuid.MustParse(resource.ID)
+	service := NewService(tx)
+	entry, err := service.Revert(EntityResource, id, created.ID, nil)
+	if err != nil {
+		t.Fatalf("revert: %v", err)
+	}
+
+	var reverted models.Resource
+	if err := tx.First(&reverted, "id = ?", resource.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Name != "Original" || reverted.Description != "Kept" {
+		t.Errorf("after revert name %q description %q, want Original and Kept", reverted.Name, reverted.Description)
+	}
+	if entry.Operation != OpRevert || entry.RevertedFrom == nil || *entry.RevertedFrom != created.ID {
+		t.Errorf("revert entry = %s from %v, want revert from %d", entry.Operation, entry.RevertedFrom, created.ID)
+	}
+
+	// Reverting again changes nothing
+	if _, err := service.Revert(EntityResource, id, created.ID, nil); !errors.Is(err, ErrNothingToRevert) {
+		t.Errorf("second revert: got %v, want ErrNothingToRevert", err)
+	}
+}
+
+func TestCenterHistoryShowsLinks(t *testing.T) {
+	tx := testutil.Tx(t)
+
+	var carrier models.InsuranceCarrier
+	if err := tx.Order("id").First(&carrier).Error; err != nil {
+		t.Skipf("no insurance carriers: %v", err)
+	}
+	center := models.ABACenter{Name: "Linked", Street: "1 Main St", City: "Fresno", Zip: "93701", Phone: "5595550100", ServiceType: "Clinic"}
+	if err := tx.Create(&center).Error; err != nil {
+		t.Fatal(err)
+	}
+	before, err := Load(tx, EntityABACenter, center.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Exec("INSERT INTO aba_center_insurance_carriers (aba_center_id, insurance_carrier_id) VALUES (?, ?)", center.ID, carrier.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := Load(tx, EntityABACenter, center.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := Diff(fieldsOf(t, before), fieldsOf(t, after))
+	if len(changes) != 1 || changes[0].Field != "insuranceCarrierIds" {
+		t.Fatalf("changes = %+v, want insuranceCarrierIds", changes)
+	}
+	if got := changes[0].After; !reflect.DeepEqual(got, []interface{}{float64(carrier.ID)}) {
+		t.Errorf("linked carriers after = %v, want [%d]", got, carrier.ID)
+	}
+}
+
+func TestOnlyUUIDTablesRevert(t *testing.T) {
+	for _, entityType := range []string{EntityRegionalCenter, EntityResourceCenter, EntityProvider} {
+		if !IsEntityType(entityType) {
+			t.Errorf("%s is not audited", entityType)
+		}
+		if _, err := NewService(nil).Revert(entityType, uuid.New(), 1, nil); !errors.Is(err, ErrNotRevertable) {
+			t.Errorf("revert %s: got %v, want ErrNotRevertable", entityType, err)
+		}
+	}
+}
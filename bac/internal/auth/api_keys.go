@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"bac/internal/models"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+const apiKeyPrefix = "bac"
+
+var (
+	ErrInvalidAPIKey   = errors.New("invalid api key")
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	ErrInvalidAPIScope = errors.New("invalid api key scope")
+)
+
+// restrictedScopes can never be granted to an API key so a leaked key cannot
+// be used to mint more keys or change who has access
+var restrictedScopes = map[string]bool{
+	"manage:roles":    true,
+	"manage:api-keys": true,
+	"write:users":     true,
+}
+
+// APIKeyRequest structure
+type APIKeyRequest struct {
+	Name               string     `json:"name" binding:"required"`
+	Organization       string     `json:"organization"`
+	UserID             *int       `json:"user_id"`
+	Scopes             []string   `json:"scopes"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+	RateLimitPerMinute int        `json:"rate_limit_per_minute" binding:"gte=0"`
+}
+
+// CreatedAPIKey is returned once, at creation, and is the only time the secret is visible
+type CreatedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// APIKeyPrincipal describes the caller behind a valid API key
+type APIKeyPrincipal struct {
+	KeyID              int
+	UserID             *int
+	Scopes             []string
+	RateLimitPerMinute int
+}
+
+// APIKeyValidator resolves an API key presented in a request
+type APIKeyValidator interface {
+	ValidateAPIKey(key, ip string) (*APIKeyPrincipal, error)
+}
+
+// APIKeyService issues, lists, revokes and validates API keys
+type APIKeyService struct {
+	db               *gorm.DB
+	defaultRateLimit int
+}
+
+// NewAPIKeyService creates a new APIKeyService instance
+func NewAPIKeyService(db *gorm.DB, defaultRateLimit int) *APIKeyService {
+	return &APIKeyService{db: db, defaultRateLimit: defaultRateLimit}
+}
+
+// Create issues a new key. Each scope must be an existing permission.
+func (s *APIKeyService) Create(req APIKeyRequest, creatorID *int) (*CreatedAPIKey, error) {
+	scopes, err := s.validateScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := req.UserID
+	if owner == nil {
+		owner = creatorID
+	}
+
+	rateLimit := req.RateLimitPerMinute
+	if rateLimit == 0 {
+		rateLimit = s.defaultRateLimit
+	}
+
+	prefix, secret, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	plain := fmt.Sprintf("%s_%s_%s", apiKeyPrefix, prefix, secret)
+
+	key := models.APIKey{
+		Name:               req.Name,
+		Organization:       req.Organization,
+		UserID:             owner,
+		Prefix:             prefix,
+		KeyHash:            hashToken(plain),
+		Scopes:             pq.StringArray(scopes),
+		RateLimitPerMinute: rateLimit,
+		ExpiresAt:          req.ExpiresAt,
+		CreatedBy:          creatorID,
+	}
+	if err := s.db.Create(&key).Error; err != nil {
+		log.Println("Database error while creating api key:", err)
+		return nil, err
+	}
+
+	return &CreatedAPIKey{APIKey: key, Key: plain}, nil
+}
+
+// List returns API keys, optionally limited to one owner
+func (s *APIKeyService) List(userID *int) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	query := s.db.Order("created_at DESC")
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	if err := query.Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke disables a key immediately
+func (s *APIKeyService) Revoke(id int) error {
+	result := s.db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// ValidateAPIKey checks a presented key and records when and from where it was used
+func (s *APIKeyService) ValidateAPIKey(key, ip string) (*APIKeyPrincipal, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var apiKey models.APIKey
+	if err := s.db.Where("prefix = ? AND key_hash = ?", parts[1], hashToken(key)).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Only write last-used once a minute so busy integrations do not hammer the row
+	if err := s.db.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", apiKey.ID, now.Add(-time.Minute)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error; err != nil {
+		log.Println("Failed to record api key usage:", err)
+	}
+
+	return &APIKeyPrincipal{
+		KeyID:              apiKey.ID,
+		UserID:             apiKey.UserID,
+		Scopes:             []string(apiKey.Scopes),
+		RateLimitPerMinute: apiKey.RateLimitPerMinute,
+	}, nil
+}
+
+func (s *APIKeyService) validateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return []string{}, nil
+	}
+
+	for _, scope := range scopes {
+		if restrictedScopes[scope] {
+			return nil, fmt.Errorf("%w: %s cannot be granted to an api key", ErrInvalidAPIScope, scope)
+		}
+	}
+
+	var known []string
+	if err := s.db.Model(&models.Permission{}).Where("name IN ?", scopes).Pluck("name", &known).Error; err != nil {
+		return nil, err
+	}
+	if len(known) != len(uniqueStrings(scopes)) {
+		return nil, fmt.Errorf("%w: every scope must be an existing permission", ErrInvalidAPIScope)
+	}
+	return uniqueStrings(scopes), nil
+}
+
+// generateAPIKey returns a short lookup prefix and a high-entropy secret
+func generateAPIKey() (string, string, error) {
+	prefixBytes := make([]byte, 6)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", err
+	}
+	secret, err := generateSecureToken()
+	if err != nil {
+		return "", "", err
+	}
+	// The prefix is split on "_", so keep it to letters and digits
+	prefix := strings.NewReplacer("-", "a", "_", "b").Replace(base64.RawURLEncoding.EncodeToString(prefixBytes))
+	return prefix, secret, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
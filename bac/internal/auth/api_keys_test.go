@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAPIKeyRejectsMalformedKeys(t *testing.T) {
+	// Malformed keys are turned away before the database is touched
+	service := NewAPIKeyService(nil, 60)
+	for _, key := range []string{"", "bac", "bac_prefix", "key_prefix_secret", "BAC_prefix_secret", "bac-prefix-secret"} {
+		if _, err := service.ValidateAPIKey(key, "198.51.100.7"); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("ValidateAPIKey(%q): got %v, want ErrInvalidAPIKey", key, err)
+		}
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		prefix, secret, err := generateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prefix) != 8 || strings.ContainsAny(prefix, "_-") {
+			t.Fatalf("prefix %q must be 8 letters and digits", prefix)
+		}
+		if seen[prefix] {
+			t.Fatalf("prefix %q repeated", prefix)
+		}
+		seen[prefix] = true
+
+		// A secret may itself hold "_", so the key splits into three parts at most
+		parts := strings.SplitN(apiKeyPrefix+"_"+prefix+"_"+secret, "_", 3)
+		if len(parts) != 3 || parts[1] != prefix || parts[2] != secret {
+			t.Fatalf("key for prefix %q and secret %q splits into %q", prefix, secret, parts)
+		}
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	clock := &testClock{now: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewRateLimiter()
+	limiter.now = clock.Now
+
+	// A new bucket starts full
+	for i := 0; i < 6; i++ {
+		if ok, _ := limiter.Allow(1, 6); !ok {
+			t.Fatalf("request %d of 6 was refused", i+1)
+		}
+	}
+	ok, wait := limiter.Allow(1, 6)
+	if ok || wait != 10*time.Second {
+		t.Fatalf("request over the limit: allowed %v, wait %v, want refused for 10s", ok, wait)
+	}
+
+	// Six a minute refills one token every ten seconds
+	clock.Advance(5 * time.Second)
+	if ok, wait := limiter.Allow(1, 6); ok || wait != 5*time.Second {
+		t.Errorf("half a token: allowed %v, wait %v, want refused for 5s", ok, wait)
+	}
+	clock.Advance(5 * time.Second)
+	if ok, _ := limiter.Allow(1, 6); !ok {
+		t.Error("refused after a token refilled")
+	}
+
+	// Refill stops at the limit however long the key is idle
+	clock.Advance(time.Hour)
+	for i := 0; i < 6; i++ {
+		if ok, _ := limiter.Allow(1, 6); !ok {
+			t.Fatalf("request %d after an idle hour was refused", i+1)
+		}
+	}
+	if ok, _ := limiter.Allow(1, 6); ok {
+		t.Error("bucket held more than the limit after an idle hour")
+	}
+
+	// Keys have their own buckets, and zero means unlimited
+	if ok, _ := limiter.Allow(2, 6); !ok {
+		t.Error("another key was refused")
+	}
+	if ok, wait := limiter.Allow(1, 0); !ok || wait != 0 {
+		t.Errorf("unlimited key: allowed %v, wait %v", ok, wait)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"log"
 
+	"bac/internal/models"
+
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -16,99 +18,246 @@ type RegisterRequest struct {
     Password  string `json:"password" binding:"required,min=6"`
 }
 
-// User model
-type User struct {
-	ID           uint   `json:"id" gorm:"primaryKey"`
-	Email        string `json:"email" gorm:"uniqueIndex"`
-	FirstName    string `json:"first_name" gorm:"column:first_name"`
-	LastName     string `json:"last_name" gorm:"column:last_name"`
-	PasswordHash string `json:"password_hash"`
-}
-
 // AuthService struct
 type AuthService struct {
-	db        *gorm.DB
-	jwtSecret []byte
+	db       *gorm.DB
+	users    *UserRepository
+	tokens   TokenConfig
+	sessions *SessionService
+	mfa      *MFAService
+
+	requireVerifiedEmail bool
 }
 
 // NewAuthService creates a new AuthService instance
-func NewAuthService(db *gorm.DB, jwtSecret []byte) *AuthService {
+func NewAuthService(db *gorm.DB, tokens TokenConfig, sessions *SessionService, mfa *MFAService, requireVerifiedEmail bool) *AuthService {
 	return &AuthService{
-		db:        db,
-		jwtSecret: jwtSecret,
+		db:       db,
+		users:    NewUserRepository(db),
+		tokens:   tokens,
+		sessions: sessions,
+		mfa:      mfa,
+
+		requireVerifiedEmail: requireVerifiedEmail,
 	}
 }
 
+// LoginResult holds either a full token pair or, for MFA accounts, a challenge
+// that must be completed with a code before tokens are issued
+type LoginResult struct {
+	Tokens    *TokenResponse
+	Challenge *MFAChallenge
+}
+
+// DefaultRegistrationRole is granted to every newly registered account
+const DefaultRegistrationRole = "viewer"
+
+// ErrInvalidCredentials is returned when the email or password does not match
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
 // LoginRequest structure
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 }
 
-func (s *AuthService) Login(req LoginRequest) (map[string]string, error) {
-	var user User
-	result := s.db.Where("email = ?", req.Email).First(&user)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid credentials")
+// RefreshRequest structure
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func (s *AuthService) Login(req LoginRequest, client ClientInfo) (*LoginResult, error) {
+	user, err := s.users.FindByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidCredentials
 		}
-		return nil, result.Error
+		return nil, err
 	}
 
 	// Compare password
-	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 
-	// TODO: Generate a JWT token here
-	token := "mock-jwt-token"
+	if s.requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
 
-	return map[string]string{"token": token}, nil
+	return s.completeLogin(user, client)
 }
 
-// GetUserProfile fetches a user's profile
-func (s *AuthService) GetUserProfile(userID int) (User, error) {
-	var user User
+// completeLogin starts a session for a user who has proven who they are, or
+// returns an MFA challenge first for accounts with MFA enabled or holding a role
+// that requires it. Password and single sign-on logins both finish here.
+func (s *AuthService) completeLogin(user models.User, client ClientInfo) (*LoginResult, error) {
+	enabled, err := s.mfa.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := s.mfa.IsRequired(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled || required {
+		challenge, err := s.mfa.IssueChallenge(user.ID, user.Email, !enabled)
+		if err != nil {
+			log.Println("Error signing mfa challenge:", err)
+			return nil, err
+		}
+		return &LoginResult{Challenge: challenge}, nil
+	}
+
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: tokens}, nil
+}
+
+// CompleteMFALogin finishes a two-step login with a TOTP or recovery code
+func (s *AuthService) CompleteMFALogin(challenge *ChallengeClaims, code string, client ClientInfo) (*TokenResponse, error) {
+	if challenge.EnrollmentRequired {
+		return nil, ErrMFAEnrollmentPending
+	}
+
+	user, err := s.challengeUser(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfa.Verify(user.ID, code); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, client)
+}
+
+// CompleteMFAEnrollment enables MFA for a user whose role requires it and signs them in.
+// The caller must have started enrollment with MFAService.Setup.
+func (s *AuthService) CompleteMFAEnrollment(challenge *ChallengeClaims, code string, client ClientInfo) (*TokenResponse, []string, error) {
+	user, err := s.challengeUser(challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recoveryCodes, err := s.mfa.Enable(user.ID, code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, recoveryCodes, nil
+}
+
+func (s *AuthService) challengeUser(challenge *ChallengeClaims) (models.User, error) {
+	userID, err := challenge.UserID()
+	if err != nil {
+		return models.User{}, ErrInvalidMFAChallenge
+	}
 
-	result := s.db.First(&user, userID)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return User{}, errors.New("user not found")
+	user, err := s.users.FindByID(userID)
+	if errors.Is(err, ErrUserNotFound) {
+		return models.User{}, ErrInvalidMFAChallenge
+	}
+	return user, err
+}
+
+func (s *AuthService) startSession(user models.User, client ClientInfo) (*TokenResponse, error) {
+	session, err := s.sessions.Start(user.ID, client)
+	if err != nil {
+		log.Println("Database error while starting session:", err)
+		return nil, err
+	}
+
+	return s.issueTokens(user.ID, user.Email, session)
+}
+
+// Refresh rotates a refresh token and issues a new access token for the same session
+func (s *AuthService) Refresh(refreshToken string, client ClientInfo) (*TokenResponse, error) {
+	session, err := s.sessions.Rotate(refreshToken, client)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.FindByID(session.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidRefreshToken
 		}
-		log.Println("Database error while fetching user profile:", result.Error)
-		return User{}, result.Error
+		return nil, err
 	}
 
-	return user, nil
+	return s.issueTokens(user.ID, user.Email, session)
 }
 
-// GetAllUsers returns a list of all users
-func (s *AuthService) GetAllUsers() ([]User, error) {
-	var users []User
+// Logout revokes the session that the refresh token belongs to
+func (s *AuthService) Logout(refreshToken string) error {
+	return s.sessions.Revoke(refreshToken)
+}
 
-	result := s.db.Find(&users)
-	if result.Error != nil {
-		log.Println("Database error while fetching all users:", result.Error)
-		return nil, result.Error
+// issueTokens signs an access token with fresh permissions and attaches the session's refresh token
+func (s *AuthService) issueTokens(userID int, email string, session *Session) (*TokenResponse, error) {
+	permissions, err := s.GetUserPermissions(userID)
+	if err != nil {
+		log.Println("Database error while resolving permissions:", err)
+		return nil, err
 	}
 
-	return users, nil
+	token, err := IssueAccessToken(s.tokens, userID, email, permissions, session.FamilyID.String())
+	if err != nil {
+		log.Println("Error signing access token:", err)
+		return nil, err
+	}
+
+	token.RefreshToken = session.RefreshToken
+	token.RefreshExpiresAt = session.ExpiresAt.Unix()
+	return &token, nil
 }
 
-// Register a new user
-func (s *AuthService) Register(req RegisterRequest) error {
-	var existingUser User
-	result := s.db.Where("email = ?", req.Email).First(&existingUser)
+// GetUserPermissions resolves the distinct permission names granted to a user
+// through the user_roles and role_permissions join tables
+func (s *AuthService) GetUserPermissions(userID int) ([]string, error) {
+	permissions := []string{}
+	err := s.db.Raw(`
+		SELECT DISTINCT p.name
+		FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN user_roles ur ON ur.role_id = rp.role_id
+		WHERE ur.user_id = ?
+		ORDER BY p.name
+	`, userID).Scan(&permissions).Error
+	if err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
 
-	if result.Error == nil {
-		log.Println("Email already exists:", req.Email)
-		return errors.New("email already exists")
-	} else if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		log.Println("Database error while checking existing user:", result.Error)
-		return result.Error
+// GetUserProfile fetches a user's profile
+func (s *AuthService) GetUserProfile(userID int) (models.User, error) {
+	user, err := s.users.FindByID(userID)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		log.Println("Database error while fetching user profile:", err)
 	}
+	return user, err
+}
 
+// GetAllUsers returns a list of all users
+func (s *AuthService) GetAllUsers() ([]models.User, error) {
+	users, err := s.users.List()
+	if err != nil {
+		log.Println("Database error while fetching all users:", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// Register a new user
+func (s *AuthService) Register(req RegisterRequest) error {
 	// Hash the password before storing
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -116,16 +265,19 @@ func (s *AuthService) Register(req RegisterRequest) error {
 		return err
 	}
 
-	// Create new user
-newUser := User{
-    Email:        req.Email,
-    FirstName:    req.FirstName,
-    LastName:     req.LastName,
-    PasswordHash: string(hashedPassword),
-}
-
+	newUser := models.User{
+		Email:        req.Email,
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+		PasswordHash: string(hashedPassword),
+	}
 
-	if err := s.db.Create(&newUser).Error; err != nil {
+	// New accounts start with read-only access until an admin grants more
+	if err := s.users.Create(&newUser, DefaultRegistrationRole); err != nil {
+		if errors.Is(err, ErrEmailExists) {
+			log.Println("Email already exists:", req.Email)
+			return err
+		}
 		log.Println("Database error while creating user:", err)
 		return err
 	}
@@ -0,0 +1,418 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"bac/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	recoveryCodeCount  = 10
+	mfaChallengeExpiry = 5 * time.Minute
+	mfaAudienceSuffix  = ":mfa"
+)
+
+var (
+	ErrInvalidMFACode       = errors.New("invalid mfa code")
+	ErrInvalidMFAChallenge  = errors.New("invalid or expired mfa challenge")
+	ErrMFANotEnrolled       = errors.New("mfa is not set up")
+	ErrMFAAlreadyEnabled    = errors.New("mfa is already enabled")
+	ErrMFARequiredByRole    = errors.New("mfa is required for one of your roles")
+	ErrMFAEnrollmentPending = errors.New("mfa enrollment is required before signing in")
+)
+
+// MFAConfig holds the settings for TOTP enrollment and login challenges
+type MFAConfig struct {
+	Issuer        string // shown as the account label in authenticator apps
+	EncryptionKey []byte // key material for encrypting TOTP secrets at rest
+}
+
+// MFASetup is returned when a user starts TOTP enrollment
+type MFASetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFAChallenge is returned by Login instead of tokens when a second factor is needed
+type MFAChallenge struct {
+	MFARequired        bool   `json:"mfa_required"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	MFAToken           string `json:"mfa_token"`
+	ExpiresAt          int64  `json:"expires_at"`
+}
+
+// ChallengeClaims identify the user behind an MFA challenge token
+type ChallengeClaims struct {
+	Email              string `json:"email"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the numeric user ID stored in the subject claim
+func (c *ChallengeClaims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// MFACodeRequest structure
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFALoginRequest structure. Code may be a TOTP code or a recovery code.
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code"`
+}
+
+// MFAService manages TOTP enrollment, recovery codes and login challenges
+type MFAService struct {
+	db     *gorm.DB
+	tokens TokenConfig
+	config MFAConfig
+	aead   cipher.AEAD
+}
+
+// NewMFAService creates a new MFAService instance
+func NewMFAService(db *gorm.DB, tokens TokenConfig, config MFAConfig) (*MFAService, error) {
+	key := sha256.Sum256(config.EncryptionKey)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAService{
+		db:     db,
+		tokens: tokens,
+		config: config,
+		aead:   aead,
+	}, nil
+}
+
+// IsEnabled reports whether the user has completed TOTP enrollment
+func (s *MFAService) IsEnabled(userID int) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.UserMFA{}).Where("user_id = ? AND enabled", userID).Count(&count).Error
+	return count > 0, err
+}
+
+// IsRequired reports whether any of the user's roles requires MFA
+func (s *MFAService) IsRequired(userID int) (bool, error) {
+	var required bool
+	err := s.db.Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM roles r
+			JOIN user_roles ur ON ur.role_id = r.id
+			WHERE ur.user_id = ? AND r.require_mfa
+		)
+	`, userID).Scan(&required).Error
+	return required, err
+}
+
+// Setup generates a new pending TOTP secret. It only takes effect once Enable
+// is called with a valid code, so an abandoned setup does not lock anyone out.
+func (s *MFAService) Setup(userID int) (*MFASetup, error) {
+	user, err := NewUserRepository(s.db).FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment := models.UserMFA{UserID: userID, SecretEncrypted: encrypted}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret_encrypted", "last_used_step", "updated_at"}),
+	}).Create(&enrollment).Error; err != nil {
+		log.Println("Database error while saving mfa secret:", err)
+		return nil, err
+	}
+
+	return &MFASetup{
+		Secret:          secret,
+		ProvisioningURI: totpProvisioningURI(s.config.Issuer, user.Email, secret),
+	}, nil
+}
+
+// Enable confirms enrollment with a code from the authenticator app and
+// returns a fresh set of recovery codes
+func (s *MFAService) Enable(userID int, code string) ([]string, error) {
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		enrollment, err := s.lockEnrollment(tx, userID)
+		if err != nil {
+			return err
+		}
+		if enrollment.Enabled {
+			return ErrMFAAlreadyEnabled
+		}
+
+		if err := s.verifyTOTP(tx, enrollment, code); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(enrollment).Updates(map[string]interface{}{
+			"enabled":    true,
+			"enabled_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable removes the user's enrollment after checking a current code.
+// Users holding a role that requires MFA cannot turn it off.
+func (s *MFAService) Disable(userID int, code string) error {
+	required, err := s.IsRequired(userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequiredByRole
+	}
+
+	if err := s.Verify(userID, code); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.UserMFA{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes replaces every recovery code after checking a current code
+func (s *MFAService) RegenerateRecoveryCodes(userID int, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Verify accepts either a current TOTP code or an unused recovery code
+func (s *MFAService) Verify(userID int, code string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		enrollment, err := s.lockEnrollment(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !enrollment.Enabled {
+			return ErrMFANotEnrolled
+		}
+
+		if err := s.verifyTOTP(tx, enrollment, code); err == nil || !errors.Is(err, ErrInvalidMFACode) {
+			return err
+		}
+		return s.useRecoveryCode(tx, userID, code)
+	})
+}
+
+// IssueChallenge signs a short-lived token that proves the password step succeeded
+func (s *MFAService) IssueChallenge(userID int, email string, enrollmentRequired bool) (*MFAChallenge, error) {
+	now := time.Now()
+	expiresAt := now.Add(mfaChallengeExpiry)
+	claims := ChallengeClaims{
+		Email:              email,
+		EnrollmentRequired: enrollmentRequired,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(userID),
+			Issuer:    s.tokens.Issuer,
+			Audience:  jwt.ClaimStrings{s.tokens.Audience + mfaAudienceSuffix},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.tokens.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAChallenge{
+		MFARequired:        true,
+		EnrollmentRequired: enrollmentRequired,
+		MFAToken:           signed,
+		ExpiresAt:          expiresAt.Unix(),
+	}, nil
+}
+
+// ParseChallenge validates an MFA challenge token. The separate audience keeps
+// challenge tokens from being accepted as access tokens and vice versa.
+func (s *MFAService) ParseChallenge(tokenString string) (*ChallengeClaims, error) {
+	claims := &ChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return s.tokens.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.tokens.Issuer),
+		jwt.WithAudience(s.tokens.Audience+mfaAudienceSuffix),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return claims, nil
+}
+
+func (s *MFAService) lockEnrollment(tx *gorm.DB, userID int) (*models.UserMFA, error) {
+	var enrollment models.UserMFA
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).
+		First(&enrollment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func (s *MFAService) verifyTOTP(tx *gorm.DB, enrollment *models.UserMFA, code string) error {
+	secret, err := s.decrypt(enrollment.SecretEncrypted)
+	if err != nil {
+		return err
+	}
+
+	step, ok := validateTOTP(secret, code, time.Now(), enrollment.LastUsedStep)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	return tx.Model(enrollment).Update("last_used_step", step).Error
+}
+
+func (s *MFAService) useRecoveryCode(tx *gorm.DB, userID int, code string) error {
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return ErrInvalidMFACode
+	}
+
+	var codes []models.MFARecoveryCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Find(&codes).Error; err != nil {
+		return err
+	}
+
+	for _, candidate := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(candidate.CodeHash), []byte(normalized)) == nil {
+			return tx.Model(&candidate).Update("used_at", time.Now()).Error
+		}
+	}
+	return ErrInvalidMFACode
+}
+
+func (s *MFAService) replaceRecoveryCodes(tx *gorm.DB, userID int) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.MFARecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, models.MFARecoveryCode{UserID: userID, CodeHash: string(hash)})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *MFAService) encrypt(plain string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *MFAService) decrypt(encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < s.aead.NonceSize() {
+		return "", errors.New("mfa secret is corrupted")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// generateRecoveryCode returns a code such as "k7qzm-3xw2p"
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
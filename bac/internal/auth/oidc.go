@@ -0,0 +1,634 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"bac/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OIDCStateExpiry is how long a started login stays valid
+const OIDCStateExpiry = 10 * time.Minute
+
+// oidcLoginCodeExpiry is how long the frontend has to exchange the one-time
+// code it is sent back with after the callback
+const oidcLoginCodeExpiry = time.Minute
+
+var (
+	ErrInvalidOIDCState    = errors.New("invalid or expired oidc state")
+	ErrOIDCEmailUnverified = errors.New("identity provider did not verify the email address")
+	ErrOIDCMFARequired     = errors.New("account requires multi-factor authentication at the identity provider")
+)
+
+// OIDCCodeRequest structure
+type OIDCCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// OIDCConfig holds the OpenID Connect client settings
+type OIDCConfig struct {
+	DiscoveryURL string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+	// GroupRoles maps identity provider group names to local role names
+	GroupRoles map[string]string
+	// MFAValues are the amr or acr values that show the provider checked a
+	// second factor; linking an account that uses MFA requires one of them
+	MFAValues []string
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// OIDCService signs users in through an external OpenID Connect provider using
+// the authorization code flow with PKCE, then issues this API's own tokens
+type OIDCService struct {
+	db     *gorm.DB
+	auth   *AuthService
+	config OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+}
+
+// NewOIDCService creates a new OIDCService instance. Discovery happens lazily
+// on first use so the API still starts when the provider is unreachable.
+func NewOIDCService(db *gorm.DB, authService *AuthService, config OIDCConfig) *OIDCService {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if len(config.MFAValues) == 0 {
+		config.MFAValues = []string{"mfa"}
+	}
+	return &OIDCService{
+		db:     db,
+		auth:   authService,
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthorizationURL starts a login and returns the provider URL to redirect the
+// browser to, along with the state that browser must present at the callback
+func (s *OIDCService) AuthorizationURL() (authURL, state string, err error) {
+	discovery, err := s.getDiscovery()
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err = generateSecureToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := generateSecureToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := generateSecureToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	// Clear out abandoned logins while we are here
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCState{}).Error; err != nil {
+		log.Println("Failed to purge expired oidc states:", err)
+	}
+	if err := s.db.Create(&models.OIDCState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(OIDCStateExpiry),
+	}).Error; err != nil {
+		return "", "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", s.config.ClientID)
+	params.Set("redirect_uri", s.config.RedirectURL)
+	params.Set("scope", strings.Join(s.config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", pkceChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), state, nil
+}
+
+// pkceChallenge derives the S256 code challenge sent for a code verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Callback completes the provider side of a login: it redeems the state,
+// exchanges the code, verifies the ID token and provisions or links the local
+// user. It returns a short-lived one-time code for Exchange rather than tokens,
+// so no credentials travel in the redirect URL.
+// browserState is the state kept by the browser that started the login; it must
+// match so a callback URL from someone else's login cannot be replayed.
+func (s *OIDCService) Callback(state, browserState, code string) (string, error) {
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return "", ErrInvalidOIDCState
+	}
+	saved, err := s.consumeState(state)
+	if err != nil {
+		return "", err
+	}
+
+	idToken, err := s.exchangeCode(code, saved.CodeVerifier)
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := s.verifyIDToken(idToken, saved.Nonce)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.provisionUser(claims)
+	if err != nil {
+		return "", err
+	}
+
+	return issueUserToken(s.db, user.ID, models.TokenPurposeOIDCLogin, oidcLoginCodeExpiry)
+}
+
+// Exchange redeems a one-time code from Callback. Like a password login it
+// returns tokens, or an MFA challenge for accounts that use MFA.
+func (s *OIDCService) Exchange(code string, client ClientInfo) (*LoginResult, error) {
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := redeemUserToken(tx, code, models.TokenPurposeOIDCLogin)
+		if err != nil {
+			return err
+		}
+		user, err = NewUserRepository(tx).FindByID(userToken.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.auth.completeLogin(user, client)
+}
+
+func (s *OIDCService) consumeState(state string) (*models.OIDCState, error) {
+	var saved models.OIDCState
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("state = ?", state).
+			First(&saved).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidOIDCState
+			}
+			return err
+		}
+		return tx.Delete(&saved).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(saved.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	return &saved, nil
+}
+
+func (s *OIDCService) exchangeCode(code, verifier string) (string, error) {
+	discovery, err := s.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", s.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+	// Public clients identify themselves in the body; confidential ones use client_secret_basic
+	if s.config.ClientSecret == "" {
+		form.Set("client_id", s.config.ClientID)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error calling token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error decoding token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response did not include an id_token")
+	}
+	return body.IDToken, nil
+}
+
+func (s *OIDCService) verifyIDToken(idToken, nonce string) (jwt.MapClaims, error) {
+	discovery, err := s.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(idToken, claims, s.lookupKey,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(s.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("invalid id token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// provisionUser finds the user linked to the identity, otherwise links an existing
+// account with the same verified email, otherwise creates a new account
+func (s *OIDCService) provisionUser(claims jwt.MapClaims) (models.User, error) {
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	email := normalizeEmail(stringClaim(claims, "email"))
+	if subject == "" || email == "" {
+		return models.User{}, errors.New("id token is missing the sub or email claim")
+	}
+
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+		switch {
+		case err == nil:
+			if user, err = NewUserRepository(tx).FindByID(identity.UserID); err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// Linking by email is only safe when the provider vouches for the address
+			if verified, ok := claims["email_verified"].(bool); !ok || !verified {
+				return ErrOIDCEmailUnverified
+			}
+
+			// Local addresses keep the case they were registered with
+			err = tx.Where("LOWER(email) = LOWER(?)", email).Order("id").First(&user).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				user, err = s.createUser(tx, email, claims)
+			case err == nil:
+				// A second factor protecting the account must not be bypassed by
+				// whoever controls the address at the provider
+				err = s.checkLinkMFA(user.ID, claims)
+			}
+			if err != nil {
+				return err
+			}
+
+			identity = models.UserIdentity{UserID: user.ID, Issuer: issuer, Subject: subject}
+			if err := tx.Create(&identity).Error; err != nil {
+				return err
+			}
+			log.Printf("Linked %s identity %s to user %d", issuer, subject, user.ID)
+		default:
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&identity).Updates(map[string]interface{}{
+			"email":         email,
+			"last_login_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		return s.syncRoles(tx, user.ID, stringsClaim(claims, s.config.GroupsClaim))
+	})
+	return user, err
+}
+
+// checkLinkMFA refuses to link an account that has MFA enabled, or holds a
+// role requiring it, unless the provider asserts it checked a second factor
+func (s *OIDCService) checkLinkMFA(userID int, claims jwt.MapClaims) error {
+	enabled, err := s.auth.mfa.IsEnabled(userID)
+	if err != nil {
+		return err
+	}
+	required, err := s.auth.mfa.IsRequired(userID)
+	if err != nil {
+		return err
+	}
+	if (enabled || required) && !assertsMFA(claims, s.config.MFAValues) {
+		return ErrOIDCMFARequired
+	}
+	return nil
+}
+
+// assertsMFA reports whether the token's amr or acr claim holds one of values
+func assertsMFA(claims jwt.MapClaims, values []string) bool {
+	asserted := stringsClaim(claims, "amr")
+	if acr := stringClaim(claims, "acr"); acr != "" {
+		asserted = append(asserted, acr)
+	}
+	for _, a := range asserted {
+		for _, v := range values {
+			if a == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *OIDCService) createUser(tx *gorm.DB, email string, claims jwt.MapClaims) (models.User, error) {
+	// SSO users never sign in with a password; store an unguessable one
+	random, err := generateSecureToken()
+	if err != nil {
+		return models.User{}, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(random), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	now := time.Now()
+	user := models.User{
+		Email:           email,
+		FirstName:       stringClaim(claims, "given_name"),
+		LastName:        stringClaim(claims, "family_name"),
+		PasswordHash:    string(hash),
+		EmailVerifiedAt: &now,
+	}
+	if err := NewUserRepository(tx).Create(&user, DefaultRegistrationRole); err != nil {
+		return models.User{}, err
+	}
+
+	log.Println("Provisioned user from identity provider:", email)
+	return user, nil
+}
+
+// syncRoles makes the user's membership of every IdP-mapped role match their
+// current groups. Roles that are not part of the mapping are left alone.
+func (s *OIDCService) syncRoles(tx *gorm.DB, userID int, groups []string) error {
+	if len(s.config.GroupRoles) == 0 {
+		return nil
+	}
+
+	mapped := make([]string, 0, len(s.config.GroupRoles))
+	for _, role := range s.config.GroupRoles {
+		mapped = append(mapped, role)
+	}
+	desired := []string{}
+	for _, group := range groups {
+		if role, ok := s.config.GroupRoles[group]; ok {
+			desired = append(desired, role)
+		}
+	}
+
+	removeQuery := tx.Exec(`
+		DELETE FROM user_roles
+		WHERE user_id = ? AND role_id IN (SELECT id FROM roles WHERE name IN ?)
+	`, userID, mapped)
+	if len(desired) > 0 {
+		removeQuery = tx.Exec(`
+			DELETE FROM user_roles
+			WHERE user_id = ? AND role_id IN (SELECT id FROM roles WHERE name IN ? AND name NOT IN ?)
+		`, userID, mapped, desired)
+	}
+	if removeQuery.Error != nil {
+		return removeQuery.Error
+	}
+
+	if len(desired) == 0 {
+		return nil
+	}
+	return tx.Exec(`
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT ?, id FROM roles WHERE name IN ?
+		ON CONFLICT DO NOTHING
+	`, userID, desired).Error
+}
+
+func (s *OIDCService) getDiscovery() (*oidcDiscovery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.discovery != nil {
+		return s.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := s.getJSON(s.config.DiscoveryURL, &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if discovery.Issuer == "" || discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document is incomplete")
+	}
+	s.discovery = &discovery
+	return s.discovery, nil
+}
+
+// lookupKey finds the signing key for a token, refetching the JWKS once when
+// the key ID is unknown so provider key rotation is picked up automatically
+func (s *OIDCService) lookupKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	s.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := s.refreshKeys(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	// Providers with a single key sometimes omit kid
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (s *OIDCService) refreshKeys() error {
+	discovery, err := s.getDiscovery()
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := s.getJSON(discovery.JWKSURI, &set); err != nil {
+		return fmt.Errorf("error fetching jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			log.Printf("Skipping jwk %s: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *OIDCService) getJSON(url string, target interface{}) error {
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// stringsClaim reads a claim such as groups or amr, which providers send as an
+// array or a single string
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case []interface{}:
+		groups := make([]string, 0, len(value))
+		for _, g := range value {
+			if group, ok := g.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	case string:
+		return []string{value}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"bac/internal/models"
+	"bac/internal/testutil"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// stubIdP serves discovery, a JWKS and a token endpoint that answers with an
+// ID token signed for the claims set by the test
+type stubIdP struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+	// challenge is the PKCE code challenge the token request must match
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &stubIdP{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]jsonWebKey{"keys": {{
+			Kty: "RSA",
+			Kid: "test",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "authorization_code" || r.Form.Get("code") != "provider-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		if pkceChallenge(r.Form.Get("code_verifier")) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(idp.claims)})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *stubIdP) sign(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	return signed
+}
+
+// idClaims returns valid ID token claims for the stub with the given changes
+func (idp *stubIdP) idClaims(changes jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":            idp.URL,
+		"aud":            "bac",
+		"sub":            "subject-1",
+		"email":          "sso@example.com",
+		"email_verified": true,
+		"nonce":          "nonce-1",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+func (idp *stubIdP) service(db *gorm.DB, authService *AuthService) *OIDCService {
+	return NewOIDCService(db, authService, OIDCConfig{
+		DiscoveryURL: idp.URL + "/.well-known/openid-configuration",
+		ClientID:     "bac",
+		RedirectURL:  "https://bac.example.org/api/oidc/callback",
+	})
+}
+
+func TestPKCEChallengeMatchesRFC7636(t *testing.T) {
+	// RFC 7636 appendix B
+	if got := pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("pkceChallenge = %s", got)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newStubIdP(t)
+	service := idp.service(nil, nil)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.idClaims(nil))
+	foreign.Header["kid"] = "test"
+	foreignToken, _ := foreign.SignedString(other)
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.idClaims(nil)).SignedString([]byte("bac"))
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", idp.sign(idp.idClaims(nil)), true},
+		{"nonce mismatch", idp.sign(idp.idClaims(jwt.MapClaims{"nonce": "nonce-2"})), false},
+		{"missing nonce", idp.sign(idp.idClaims(jwt.MapClaims{"nonce": nil})), false},
+		{"other audience", idp.sign(idp.idClaims(jwt.MapClaims{"aud": "another-client"})), false},
+		{"other issuer", idp.sign(idp.idClaims(jwt.MapClaims{"iss": "https://evil.example.org"})), false},
+		{"expired", idp.sign(idp.idClaims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})), false},
+		{"no expiry", idp.sign(idp.idClaims(jwt.MapClaims{"exp": nil})), false},
+		{"signed by another key", foreignToken, false},
+		{"HMAC signed with the client ID", hmacToken, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := service.verifyIDToken(tt.token, "nonce-1")
+			if tt.valid && (err != nil || claims["sub"] != "subject-1") {
+				t.Errorf("rejected a valid token: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("accepted an invalid token")
+			}
+		})
+	}
+}
+
+func TestExchangeCodeSendsPKCEVerifier(t *testing.T) {
+	idp := newStubIdP(t)
+	service := idp.service(nil, nil)
+	idp.challenge = pkceChallenge("verifier-1")
+	idp.claims = idp.idClaims(nil)
+
+	if _, err := service.exchangeCode("provider-code", "verifier-1"); err != nil {
+		t.Errorf("exchange with the right verifier: %v", err)
+	}
+	if _, err := service.exchangeCode("provider-code", "verifier-2"); err == nil {
+		t.Error("exchange with the wrong verifier succeeded")
+	}
+}
+
+func TestAssertsMFA(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		values []string
+		want   bool
+	}{
+		{"no claims", jwt.MapClaims{}, []string{"mfa"}, false},
+		{"password only", jwt.MapClaims{"amr": []interface{}{"pwd"}}, []string{"mfa"}, false},
+		{"amr list", jwt.MapClaims{"amr": []interface{}{"pwd", "mfa"}}, []string{"mfa"}, true},
+		{"amr string", jwt.MapClaims{"amr": "otp"}, []string{"mfa", "otp"}, true},
+		{"acr", jwt.MapClaims{"acr": "urn:example:loa:2"}, []string{"urn:example:loa:2"}, true},
+		{"unlisted acr", jwt.MapClaims{"acr": "urn:example:loa:1"}, []string{"urn:example:loa:2"}, false},
+	}
+	for _, tt := range tests {
+		if got := assertsMFA(tt.claims, tt.values); got != tt.want {
+			t.Errorf("%s: assertsMFA = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// newTestAuthService returns an AuthService on tx with test secrets
+func newTestAuthService(t *testing.T, tx *gorm.DB) *AuthService {
+	tokens := TokenConfig{Secret: []byte("test"), Expiry: time.Minute}
+	mfa, err := NewMFAService(tx, tokens, MFAConfig{Issuer: "test", EncryptionKey: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthService(tx, tokens, NewSessionService(tx, time.Hour), mfa, false)
+}
+
+// requireMFA gives the user a role that requires MFA
+func requireMFA(t *testing.T, tx *gorm.DB, userID int) {
+	role := models.Role{Name: "mfa-test-" + time.Now().Format("150405.000000000"), RequireMFA: true}
+	if err := tx.Create(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?)", userID, role.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// login runs the browser side of a login against the stub and returns the
+// one-time code from the callback
+func login(t *testing.T, idp *stubIdP, service *OIDCService, claims jwt.MapClaims) (string, error) {
+	t.Helper()
+	authURL, state, err := service.AuthorizationURL()
+	if err != nil {
+		t.Fatalf("authorization url: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("state") != state || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization url %s", authURL)
+	}
+	idp.challenge = query.Get("code_challenge")
+	claims["nonce"] = query.Get("nonce")
+	idp.claims = claims
+	return service.Callback(state, state, "provider-code")
+}
+
+func TestOIDCLoginOfMFAAccountGetsChallenge(t *testing.T) {
+	tx := testutil.Tx(t)
+	user := testUser(t, tx)
+	requireMFA(t, tx, user.ID)
+	idp := newStubIdP(t)
+	service := idp.service(tx, newTestAuthService(t, tx))
+
+	// Linking by email needs the provider to have checked a second factor
+	if _, err := login(t, idp, service, idp.idClaims(jwt.MapClaims{"email": user.Email})); !errors.Is(err, ErrOIDCMFARequired) {
+		t.Fatalf("link without amr: got %v, want ErrOIDCMFARequired", err)
+	}
+	code, err := login(t, idp, service, idp.idClaims(jwt.MapClaims{"email": user.Email, "amr": []interface{}{"pwd", "mfa"}}))
+	if err != nil {
+		t.Fatalf("link with amr: %v", err)
+	}
+
+	// The code leads to the same challenge as a password login, and only once
+	result, err := service.Exchange(code, ClientInfo{})
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if result.Tokens != nil || result.Challenge == nil || !result.Challenge.EnrollmentRequired {
+		t.Errorf("exchange returned %+v, want an enrollment challenge", result)
+	}
+	if _, err := service.Exchange(code, ClientInfo{}); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("second exchange: got %v, want ErrInvalidUserToken", err)
+	}
+
+	// Once linked, later logins come back by subject and still get the challenge
+	code, err = login(t, idp, service, idp.idClaims(jwt.MapClaims{"email": user.Email}))
+	if err != nil {
+		t.Fatalf("linked login: %v", err)
+	}
+	if result, err := service.Exchange(code, ClientInfo{}); err != nil || result.Challenge == nil {
+		t.Errorf("linked login exchange = %+v, %v; want a challenge", result, err)
+	}
+}
+
+func TestOIDCLoginWithoutMFAGetsTokens(t *testing.T) {
+	tx := testutil.Tx(t)
+	idp := newStubIdP(t)
+	service := idp.service(tx, newTestAuthService(t, tx))
+
+	email := "sso-" + time.Now().Format("150405.000000000") + "@example.com"
+	code, err := login(t, idp, service, idp.idClaims(jwt.MapClaims{"email": email, "sub": email}))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	result, err := service.Exchange(code, ClientInfo{})
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if result.Challenge != nil || result.Tokens == nil || result.Tokens.RefreshToken == "" {
+		t.Errorf("exchange returned %+v, want tokens", result)
+	}
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is an in-memory token bucket per key. Each bucket refills to
+// its per-minute limit over one minute.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[int]*bucket
+	now     func() time.Time // replaced in tests
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter instance
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: make(map[int]*bucket), now: time.Now}
+}
+
+// Allow takes a token from the key's bucket. When the bucket is empty it
+// returns false and how long until the next token is available.
+func (l *RateLimiter) Allow(key int, perMinute int) (bool, time.Duration) {
+	if perMinute <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	capacity := float64(perMinute)
+	refillPerSecond := capacity / 60
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * refillPerSecond
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / refillPerSecond * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
@@ -0,0 +1,375 @@
+package auth
+
+import (
+	"errors"
+	"log"
+
+	"bac/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrRoleExists         = errors.New("role already exists")
+	ErrPermissionExists   = errors.New("permission already exists")
+)
+
+// DefaultPermissions is the catalogue of permissions checked by the API
+var DefaultPermissions = map[string]string{
+	"read:users":              "List user accounts",
+	"write:users":             "Manage user accounts and sessions",
+	"manage:roles":            "Create roles and permissions and assign them to users",
+	"manage:api-keys":         "Issue and revoke partner API keys",
+	"write:resources":         "Create and update resources",
+	"delete:resources":        "Delete resources",
+	"write:aba-centers":       "Create and update ABA centers",
+	"delete:aba-centers":      "Delete ABA centers",
+	"write:resource-centers":  "Create and update resource centers",
+	"delete:resource-centers": "Delete resource centers",
+	"manage:catchments":       "Import regional center service areas",
+	"manage:insurance":        "Review unmatched insurance and Medi-Cal plan names",
+	"manage:verification":     "Work the queue of directory records due for re-verification",
+	"read:audit":              "View the change history of directory records",
+	"manage:trash":            "List, restore and purge deleted directory records",
+}
+
+// DefaultRoles maps each seeded role to the permissions it is granted
+var DefaultRoles = map[string][]string{
+	"admin": {
+		"read:users", "write:users", "manage:roles", "manage:api-keys",
+		"write:resources", "delete:resources",
+		"write:aba-centers", "delete:aba-centers",
+		"write:resource-centers", "delete:resource-centers",
+		"manage:catchments", "manage:insurance", "manage:verification",
+		"read:audit", "manage:trash",
+	},
+	"editor": {
+		"write:resources",
+		"write:aba-centers",
+		"write:resource-centers",
+		"manage:insurance",
+		"manage:verification",
+		"read:audit",
+	},
+	// Self-registered and single sign-on accounts start here, so it grants
+	// nothing beyond the public directory
+	"viewer": {},
+}
+
+// RoleRequest structure
+type RoleRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	RequireMFA  bool   `json:"require_mfa"`
+}
+
+// PermissionRequest structure
+type PermissionRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// RoleService manages roles, permissions and their assignment to users
+type RoleService struct {
+	db *gorm.DB
+}
+
+// NewRoleService creates a new RoleService instance
+func NewRoleService(db *gorm.DB) *RoleService {
+	return &RoleService{db: db}
+}
+
+// SeedDefaults creates the default permissions and roles if they are missing and
+// makes sure each default role holds at least its default permissions
+func (s *RoleService) SeedDefaults() error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		permissions := make(map[string]*models.Permission, len(DefaultPermissions))
+		for name, description := range DefaultPermissions {
+			permission := models.Permission{Name: name, Description: description}
+			if err := tx.Where(models.Permission{Name: name}).FirstOrCreate(&permission).Error; err != nil {
+				return err
+			}
+			permissions[name] = &permission
+		}
+
+		for name, granted := range DefaultRoles {
+			role := models.Role{Name: name}
+			if err := tx.Where(models.Role{Name: name}).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+
+			if len(granted) == 0 {
+				continue
+			}
+			toAttach := make([]*models.Permission, 0, len(granted))
+			for _, p := range granted {
+				toAttach = append(toAttach, permissions[p])
+			}
+			if err := tx.Model(&role).Association("Permissions").Append(toAttach); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ListRoles returns every role together with its permissions
+func (s *RoleService) ListRoles() ([]models.Role, error) {
+	var roles []models.Role
+	if err := s.db.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		log.Println("Database error while listing roles:", err)
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetRole fetches a single role with its permissions
+func (s *RoleService) GetRole(id int) (models.Role, error) {
+	var role models.Role
+	if err := s.db.Preload("Permissions").First(&role, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Role{}, ErrRoleNotFound
+		}
+		return models.Role{}, err
+	}
+	return role, nil
+}
+
+// CreateRole adds a new role
+func (s *RoleService) CreateRole(req RoleRequest) (models.Role, error) {
+	var count int64
+	if err := s.db.Model(&models.Role{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+		return models.Role{}, err
+	}
+	if count > 0 {
+		return models.Role{}, ErrRoleExists
+	}
+
+	role := models.Role{Name: req.Name, Description: req.Description, RequireMFA: req.RequireMFA}
+	if err := s.db.Create(&role).Error; err != nil {
+		log.Println("Database error while creating role:", err)
+		return models.Role{}, err
+	}
+	return role, nil
+}
+
+// UpdateRole renames a role or changes its description
+func (s *RoleService) UpdateRole(id int, req RoleRequest) (models.Role, error) {
+	role, err := s.GetRole(id)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Role{}).Where("name = ? AND id <> ?", req.Name, id).Count(&count).Error; err != nil {
+		return models.Role{}, err
+	}
+	if count > 0 {
+		return models.Role{}, ErrRoleExists
+	}
+
+	if err := s.db.Model(&role).Updates(map[string]interface{}{
+		"name":        req.Name,
+		"description": req.Description,
+		"require_mfa": req.RequireMFA,
+	}).Error; err != nil {
+		log.Println("Database error while updating role:", err)
+		return models.Role{}, err
+	}
+	role.Name = req.Name
+	role.Description = req.Description
+	role.RequireMFA = req.RequireMFA
+	return role, nil
+}
+
+// DeleteRole removes a role along with its user and permission assignments
+func (s *RoleService) DeleteRole(id int) error {
+	role, err := s.GetRole(id)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Users").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+}
+
+// ListPermissions returns every permission
+func (s *RoleService) ListPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := s.db.Order("name").Find(&permissions).Error; err != nil {
+		log.Println("Database error while listing permissions:", err)
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// CreatePermission adds a new permission
+func (s *RoleService) CreatePermission(req PermissionRequest) (models.Permission, error) {
+	var count int64
+	if err := s.db.Model(&models.Permission{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+		return models.Permission{}, err
+	}
+	if count > 0 {
+		return models.Permission{}, ErrPermissionExists
+	}
+
+	permission := models.Permission{Name: req.Name, Description: req.Description}
+	if err := s.db.Create(&permission).Error; err != nil {
+		log.Println("Database error while creating permission:", err)
+		return models.Permission{}, err
+	}
+	return permission, nil
+}
+
+// DeletePermission removes a permission and detaches it from every role
+func (s *RoleService) DeletePermission(id int) error {
+	permission, err := s.getPermission(id)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&permission).Association("Roles").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&permission).Error
+	})
+}
+
+// AttachPermission grants a permission to a role
+func (s *RoleService) AttachPermission(roleID, permissionID int) error {
+	role, err := s.GetRole(roleID)
+	if err != nil {
+		return err
+	}
+	permission, err := s.getPermission(permissionID)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&role).Association("Permissions").Append(&permission)
+}
+
+// DetachPermission removes a permission from a role
+func (s *RoleService) DetachPermission(roleID, permissionID int) error {
+	role, err := s.GetRole(roleID)
+	if err != nil {
+		return err
+	}
+	permission, err := s.getPermission(permissionID)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&role).Association("Permissions").Delete(&permission)
+}
+
+// GetUserRoles lists the roles held by a user
+func (s *RoleService) GetUserRoles(userID int) ([]models.Role, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []models.Role
+	if err := s.db.Model(&user).Association("Roles").Find(&roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetUserRoleNames lists the names of the roles held by a user
+func (s *RoleService) GetUserRoleNames(userID int) ([]string, error) {
+	names := []string{}
+	err := s.db.Raw(`
+		SELECT r.name
+		FROM roles r
+		JOIN user_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = ?
+		ORDER BY r.name
+	`, userID).Scan(&names).Error
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// GrantRole assigns a role to a user. The new permissions are picked up the
+// next time the user logs in or refreshes their access token.
+func (s *RoleService) GrantRole(userID, roleID int) error {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+	role, err := s.GetRole(roleID)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&user).Association("Roles").Append(&role)
+}
+
+// BootstrapAdmin grants the admin role to an existing account so the first
+// administrator can manage everyone else's roles
+func (s *RoleService) BootstrapAdmin(email string) error {
+	var user models.User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Bootstrap admin account does not exist yet:", email)
+			return nil
+		}
+		return err
+	}
+
+	var role models.Role
+	if err := s.db.Where("name = ?", "admin").First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+	return s.db.Model(&user).Association("Roles").Append(&role)
+}
+
+// RevokeRole removes a role from a user
+func (s *RoleService) RevokeRole(userID, roleID int) error {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+	role, err := s.GetRole(roleID)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&user).Association("Roles").Delete(&role)
+}
+
+func (s *RoleService) getPermission(id int) (models.Permission, error) {
+	var permission models.Permission
+	if err := s.db.First(&permission, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Permission{}, ErrPermissionNotFound
+		}
+		return models.Permission{}, err
+	}
+	return permission, nil
+}
+
+func (s *RoleService) getUser(id int) (models.User, error) {
+	var user models.User
+	if err := s.db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
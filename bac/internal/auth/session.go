@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"bac/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated token is presented again.
+	// The whole token family is revoked before this error is returned.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// ClientInfo describes the client a session was started from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// SessionValidator reports whether the session behind an access token is still live
+type SessionValidator interface {
+	IsSessionActive(sessionID string) (bool, error)
+}
+
+// SessionService manages refresh token families. A family is created at login,
+// rotated on every refresh and revoked on logout or when reuse is detected.
+type SessionService struct {
+	db     *gorm.DB
+	expiry time.Duration
+}
+
+// NewSessionService creates a new SessionService instance
+func NewSessionService(db *gorm.DB, expiry time.Duration) *SessionService {
+	return &SessionService{
+		db:     db,
+		expiry: expiry,
+	}
+}
+
+// Session is the result of starting or rotating a session
+type Session struct {
+	UserID       int
+	FamilyID     uuid.UUID
+	RefreshToken string
+	ExpiresAt    time.Time
+
+	tokenID uuid.UUID
+}
+
+// Start opens a new token family for the user and returns its first refresh token
+func (s *SessionService) Start(userID int, client ClientInfo) (*Session, error) {
+	return s.issue(s.db, userID, uuid.New(), client)
+}
+
+// Rotate exchanges a refresh token for a new one in the same family.
+// Presenting a token that was already rotated revokes the whole family.
+func (s *SessionService) Rotate(refreshToken string, client ClientInfo) (*Session, error) {
+	var session *Session
+	var reused *models.RefreshToken
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(refreshToken)).
+			First(&current)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return result.Error
+		}
+
+		if current.UsedAt != nil {
+			reused = &current
+			return ErrRefreshTokenReused
+		}
+		if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		next, err := s.issue(tx, current.UserID, current.FamilyID, client)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"used_at":        time.Now(),
+			"replaced_by_id": next.tokenID,
+		}).Error; err != nil {
+			return err
+		}
+
+		session = next
+		return nil
+	})
+
+	// Revoke outside the rolled-back transaction so the revocation sticks
+	if reused != nil {
+		log.Printf("Refresh token reuse detected for user %d, revoking family %s", reused.UserID, reused.FamilyID)
+		if err := s.RevokeFamily(reused.FamilyID); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Revoke ends the session that the given refresh token belongs to
+func (s *SessionService) Revoke(refreshToken string) error {
+	var token models.RefreshToken
+	result := s.db.Where("token_hash = ?", hashToken(refreshToken)).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return result.Error
+	}
+
+	return s.RevokeFamily(token.FamilyID)
+}
+
+// RevokeFamily revokes every refresh token in a family
+func (s *SessionService) RevokeFamily(familyID uuid.UUID) error {
+	return s.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUser revokes every session of a user, signing them out everywhere
+func (s *SessionService) RevokeUser(userID int) error {
+	return s.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// IsSessionActive reports whether the family still holds an unused, unrevoked token
+func (s *SessionService) IsSessionActive(sessionID string) (bool, error) {
+	familyID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return false, nil
+	}
+
+	var active bool
+	err = s.db.Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM refresh_tokens
+			WHERE family_id = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		)
+	`, familyID).Scan(&active).Error
+	return active, err
+}
+
+func (s *SessionService) issue(db *gorm.DB, userID int, familyID uuid.UUID, client ClientInfo) (*Session, error) {
+	plain, err := generateSecureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token := models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(plain),
+		ExpiresAt: time.Now().Add(s.expiry),
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+	}
+	if err := db.Create(&token).Error; err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		UserID:       userID,
+		FamilyID:     familyID,
+		RefreshToken: plain,
+		ExpiresAt:    token.ExpiresAt,
+		tokenID:      token.ID,
+	}, nil
+}
+
+func generateSecureToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a secret token; only hashes are persisted
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
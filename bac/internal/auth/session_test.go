@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bac/internal/models"
+	"bac/internal/testutil"
+)
+
+func TestRotateRetiresTheOldToken(t *testing.T) {
+	tx := testutil.Tx(t)
+	user := testUser(t, tx)
+	sessions := NewSessionService(tx, time.Hour)
+	client := ClientInfo{UserAgent: "test", IPAddress: "127.0.0.1"}
+
+	first, err := sessions.Start(user.ID, client)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	second, err := sessions.Rotate(first.RefreshToken, client)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("rotation returned the same refresh token")
+	}
+	if second.FamilyID != first.FamilyID {
+		t.Errorf("rotation moved the session to family %s, want %s", second.FamilyID, first.FamilyID)
+	}
+
+	var old models.RefreshToken
+	if err := tx.Where("token_hash = ?", hashToken(first.RefreshToken)).First(&old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if old.UsedAt == nil || old.ReplacedByID == nil || *old.ReplacedByID != second.tokenID {
+		t.Errorf("old token not marked used and replaced: used_at %v, replaced_by_id %v", old.UsedAt, old.ReplacedByID)
+	}
+
+	// The new token keeps the session alive and can itself be rotated
+	if active, err := sessions.IsSessionActive(first.FamilyID.String()); err != nil || !active {
+		t.Fatalf("session active = %v, %v after rotation", active, err)
+	}
+	if _, err := sessions.Rotate(second.RefreshToken, client); err != nil {
+		t.Errorf("rotating the new token: %v", err)
+	}
+}
+
+func TestReusedRefreshTokenRevokesFamily(t *testing.T) {
+	tx := testutil.Tx(t)
+	user := testUser(t, tx)
+	sessions := NewSessionService(tx, time.Hour)
+	client := ClientInfo{UserAgent: "test", IPAddress: "127.0.0.1"}
+
+	first, err := sessions.Start(user.ID, client)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	second, err := sessions.Rotate(first.RefreshToken, client)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	// A second session of the same user is a different family and survives
+	other, err := sessions.Start(user.ID, client)
+	if err != nil {
+		t.Fatalf("start other: %v", err)
+	}
+
+	if _, err := sessions.Rotate(first.RefreshToken, client); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reusing a rotated token: got %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := sessions.Rotate(second.RefreshToken, client); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("the current token after reuse: got %v, want ErrInvalidRefreshToken", err)
+	}
+
+	var live int64
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", first.FamilyID).Count(&live).Error; err != nil {
+		t.Fatal(err)
+	}
+	if live != 0 {
+		t.Errorf("%d tokens in the reused family are still unrevoked", live)
+	}
+	if active, _ := sessions.IsSessionActive(first.FamilyID.String()); active {
+		t.Error("the reused family is still active")
+	}
+	if active, _ := sessions.IsSessionActive(other.FamilyID.String()); !active {
+		t.Error("reuse in one family revoked another session")
+	}
+}
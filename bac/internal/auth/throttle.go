@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"bac/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockoutResetAfter is how long an account must stay clean before its
+// lockout backoff starts again from the base duration
+const lockoutResetAfter = 24 * time.Hour
+
+// LockedError is returned while an email address or client IP is locked out
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// ThrottleConfig holds the login brute-force protection settings
+type ThrottleConfig struct {
+	MaxAttemptsPerEmail int
+	MaxAttemptsPerIP    int
+	Window              time.Duration
+	LockoutBase         time.Duration
+	LockoutMax          time.Duration
+	// Scope keeps this throttler's counters apart from other throttlers'; it
+	// is empty for logins
+	Scope string
+}
+
+// LoginThrottler tracks failed logins per email and per client IP and locks
+// either out with exponential backoff once the threshold is reached
+type LoginThrottler struct {
+	db     *gorm.DB
+	config ThrottleConfig
+	now    func() time.Time // replaced in tests
+}
+
+// NewLoginThrottler creates a new LoginThrottler instance
+func NewLoginThrottler(db *gorm.DB, config ThrottleConfig) *LoginThrottler {
+	return &LoginThrottler{db: db, config: config, now: time.Now}
+}
+
+// Check returns a *LockedError if the email address or the client IP is locked out
+func (t *LoginThrottler) Check(email, ip string) error {
+	var throttles []models.LoginThrottle
+	err := t.db.Where(
+		"(scope = ? AND key = ?) OR (scope = ? AND key = ?)",
+		t.scope(models.ThrottleScopeEmail), normalizeEmail(email),
+		t.scope(models.ThrottleScopeIP), ip,
+	).Find(&throttles).Error
+	if err != nil {
+		return err
+	}
+
+	var retryAfter time.Duration
+	now := t.now()
+	for _, throttle := range throttles {
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			if wait := throttle.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against both the email address and the client IP
+func (t *LoginThrottler) RecordFailure(email, ip string) error {
+	if err := t.recordFailure(t.scope(models.ThrottleScopeEmail), normalizeEmail(email), t.config.MaxAttemptsPerEmail, email, ip); err != nil {
+		return err
+	}
+	return t.recordFailure(t.scope(models.ThrottleScopeIP), ip, t.config.MaxAttemptsPerIP, email, ip)
+}
+
+// RecordSuccess clears the failed-attempt counter for the email address
+func (t *LoginThrottler) RecordSuccess(email string) error {
+	return t.db.Model(&models.LoginThrottle{}).
+		Where("scope = ? AND key = ?", t.scope(models.ThrottleScopeEmail), normalizeEmail(email)).
+		Updates(map[string]interface{}{
+			"failed_count":    0,
+			"first_failed_at": nil,
+		}).Error
+}
+
+// Unlock lifts the lockout on a user's account and records who did it
+func (t *LoginThrottler) Unlock(userID int, actorID *int) error {
+	user, err := NewUserRepository(t.db).FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.LoginThrottle{}).
+			Where("scope = ? AND key = ?", t.scope(models.ThrottleScopeEmail), normalizeEmail(user.Email)).
+			Updates(map[string]interface{}{
+				"failed_count":    0,
+				"lockout_count":   0,
+				"first_failed_at": nil,
+				"locked_until":    nil,
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.SecurityEvent{
+			Type:    models.SecurityEventAccountUnlocked,
+			UserID:  &user.ID,
+			ActorID: actorID,
+			Email:   user.Email,
+		}).Error
+	})
+}
+
+func (t *LoginThrottler) recordFailure(scope, key string, maxAttempts int, email, ip string) error {
+	if key == "" || maxAttempts <= 0 {
+		return nil
+	}
+
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Scope: scope, Key: key}).Error; err != nil {
+			return err
+		}
+
+		var throttle models.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND key = ?", scope, key).
+			First(&throttle).Error; err != nil {
+			return err
+		}
+
+		now := t.now()
+		if throttle.LockedUntil != nil && now.Sub(*throttle.LockedUntil) > lockoutResetAfter {
+			throttle.LockoutCount = 0
+		}
+		if throttle.FirstFailedAt == nil || now.Sub(*throttle.FirstFailedAt) > t.config.Window {
+			throttle.FailedCount = 0
+			throttle.FirstFailedAt = &now
+		}
+		throttle.FailedCount++
+		throttle.LastFailedAt = &now
+
+		locked := false
+		if throttle.FailedCount >= maxAttempts {
+			lockedUntil := now.Add(t.lockoutDuration(throttle.LockoutCount))
+			throttle.LockedUntil = &lockedUntil
+			throttle.LockoutCount++
+			throttle.FailedCount = 0
+			throttle.FirstFailedAt = nil
+			locked = true
+		}
+
+		if err := tx.Save(&throttle).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		log.Printf("Login locked for %s %s until %s", scope, key, throttle.LockedUntil.Format(time.RFC3339))
+		event := models.SecurityEvent{
+			Type:      models.SecurityEventLoginLocked,
+			Email:     email,
+			IPAddress: ip,
+			Details: fmt.Sprintf("%s %s locked until %s after %d failed attempts (lockout #%d)",
+				scope, key, throttle.LockedUntil.Format(time.RFC3339), maxAttempts, throttle.LockoutCount),
+		}
+		if scope == t.scope(models.ThrottleScopeEmail) {
+			if user, err := NewUserRepository(tx).FindByEmail(email); err == nil {
+				event.UserID = &user.ID
+			}
+		}
+		return tx.Create(&event).Error
+	})
+}
+
+// scope names a counter scope within this throttler's Scope
+func (t *LoginThrottler) scope(scope string) string {
+	if t.config.Scope == "" {
+		return scope
+	}
+	return t.config.Scope + ":" + scope
+}
+
+// lockoutDuration doubles the base lockout for every previous lockout, up to the maximum
+func (t *LoginThrottler) lockoutDuration(previousLockouts int) time.Duration {
+	d := t.config.LockoutBase
+	for i := 0; i < previousLockouts && d < t.config.LockoutMax; i++ {
+		d *= 2
+	}
+	if d > t.config.LockoutMax {
+		d = t.config.LockoutMax
+	}
+	return d
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"bac/internal/testutil"
+)
+
+// testClock is a settable clock for the throttler
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+var testThrottleConfig = ThrottleConfig{
+	MaxAttemptsPerEmail: 3,
+	MaxAttemptsPerIP:    100,
+	Window:              15 * time.Minute,
+	LockoutBase:         time.Minute,
+	LockoutMax:          10 * time.Minute,
+}
+
+func TestLockoutDurationBackoff(t *testing.T) {
+	throttler := NewLoginThrottler(nil, testThrottleConfig)
+	tests := []struct {
+		previousLockouts int
+		want             time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 4 * time.Minute},
+		{3, 8 * time.Minute},
+		{4, 10 * time.Minute}, // 16 minutes is over the cap
+		{50, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := throttler.lockoutDuration(tt.previousLockouts); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %s, want %s", tt.previousLockouts, got, tt.want)
+		}
+	}
+}
+
+// newTestThrottler returns a throttler on a rolled back transaction with a
+// fake clock, and a unique email and IP to fail logins against
+func newTestThrottler(t *testing.T) (*LoginThrottler, *testClock, string, string) {
+	tx := testutil.Tx(t)
+	clock := &testClock{now: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	throttler := NewLoginThrottler(tx, testThrottleConfig)
+	throttler.now = clock.Now
+	n := time.Now().UnixNano()
+	return throttler, clock, fmt.Sprintf("Throttle-%d@Example.com", n), fmt.Sprintf("ip-%d", n)
+}
+
+// lockedFor returns how long Check says the email is locked, or zero
+func lockedFor(t *testing.T, throttler *LoginThrottler, email, ip string) time.Duration {
+	t.Helper()
+	err := throttler.Check(email, ip)
+	var locked *LockedError
+	if errors.As(err, &locked) {
+		return locked.RetryAfter
+	}
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	return 0
+}
+
+func fail(t *testing.T, throttler *LoginThrottler, email, ip string, times int) {
+	t.Helper()
+	for i := 0; i < times; i++ {
+		if err := throttler.RecordFailure(email, ip); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+	}
+}
+
+func TestThrottleLocksAtThreshold(t *testing.T) {
+	throttler, _, email, ip := newTestThrottler(t)
+
+	fail(t, throttler, email, ip, testThrottleConfig.MaxAttemptsPerEmail-1)
+	if d := lockedFor(t, throttler, email, ip); d != 0 {
+		t.Fatalf("locked for %s before reaching the threshold", d)
+	}
+
+	fail(t, throttler, email, ip, 1)
+	if d := lockedFor(t, throttler, email, ip); d != testThrottleConfig.LockoutBase {
+		t.Fatalf("locked for %s at the threshold, want %s", d, testThrottleConfig.LockoutBase)
+	}
+	// The counter is per address, whatever its case
+	if d := lockedFor(t, throttler, " "+email+" ", "another-ip"); d == 0 {
+		t.Error("the same address in another case and IP was not locked")
+	}
+}
+
+func TestThrottleBackoffGrowsToCap(t *testing.T) {
+	throttler, clock, email, ip := newTestThrottler(t)
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute} {
+		fail(t, throttler, email, ip, testThrottleConfig.MaxAttemptsPerEmail)
+		if d := lockedFor(t, throttler, email, ip); d != want {
+			t.Fatalf("locked for %s, want %s", d, want)
+		}
+		clock.Advance(want)
+		if d := lockedFor(t, throttler, email, ip); d != 0 {
+			t.Fatalf("still locked for %s after the lockout ended", d)
+		}
+	}
+
+	// A clean day starts the backoff again from the base
+	clock.Advance(lockoutResetAfter + time.Minute)
+	fail(t, throttler, email, ip, testThrottleConfig.MaxAttemptsPerEmail)
+	if d := lockedFor(t, throttler, email, ip); d != testThrottleConfig.LockoutBase {
+		t.Errorf("locked for %s after a clean day, want %s", d, testThrottleConfig.LockoutBase)
+	}
+}
+
+func TestThrottleSuccessResetsCounter(t *testing.T) {
+	tests := []struct {
+		name    string
+		success bool
+		wait    time.Duration
+		locked  bool
+	}{
+		{"failures accumulate", false, 0, true},
+		{"successful login resets", true, 0, false},
+		{"failures outside the window reset", false, testThrottleConfig.Window + time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttler, clock, email, ip := newTestThrottler(t)
+
+			fail(t, throttler, email, ip, testThrottleConfig.MaxAttemptsPerEmail-1)
+			if tt.success {
+				if err := throttler.RecordSuccess(email); err != nil {
+					t.Fatalf("record success: %v", err)
+				}
+			}
+			clock.Advance(tt.wait)
+			fail(t, throttler, email, ip, 1)
+
+			if locked := lockedFor(t, throttler, email, ip) > 0; locked != tt.locked {
+				t.Errorf("locked = %v, want %v", locked, tt.locked)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenConfig holds the settings used to sign and validate access tokens
+type TokenConfig struct {
+	Secret   []byte
+	Issuer   string
+	Audience string
+	Expiry   time.Duration
+}
+
+// Claims is the payload carried by every access token issued by this service
+type Claims struct {
+	Email       string   `json:"email"`
+	Permissions []string `json:"permissions"`
+	SessionID   string   `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the numeric user ID stored in the subject claim
+func (c *Claims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// TokenResponse is returned to clients after a successful login
+type TokenResponse struct {
+	Token            string `json:"token"`
+	TokenType        string `json:"token_type"`
+	ExpiresAt        int64  `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at,omitempty"`
+}
+
+// IssueAccessToken signs an HS256 access token for the given user and session
+func IssueAccessToken(cfg TokenConfig, userID int, email string, permissions []string, sessionID string) (TokenResponse, error) {
+	if len(cfg.Secret) == 0 {
+		return TokenResponse{}, errors.New("jwt secret is not configured")
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(cfg.Expiry)
+	claims := Claims{
+		Email:       email,
+		Permissions: permissions,
+		SessionID:   sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(userID),
+			Issuer:    cfg.Issuer,
+			Audience:  jwt.ClaimStrings{cfg.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cfg.Secret)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{
+		Token:     signed,
+		TokenType: "Bearer",
+		ExpiresAt: expiresAt.Unix(),
+	}, nil
+}
+
+// ParseAccessToken verifies the signature, issuer, audience and expiry of a token
+func ParseAccessToken(cfg TokenConfig, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return cfg.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"bac/internal/models"
+	"bac/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// testUser creates a user inside tx
+func testUser(t *testing.T, tx *gorm.DB) models.User {
+	t.Helper()
+	user := models.User{
+		Email:        fmt.Sprintf("test-%d@example.com", time.Now().UnixNano()),
+		PasswordHash: "not a real hash",
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// rfc6238Secret is the SHA1 key from RFC 6238 appendix B, base32 encoded
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8 digit codes; a 6 digit code is the same value mod 10^6
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got := totpCode([]byte("12345678901234567890"), tt.unix/totpPeriod)
+		if got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+		if _, ok := validateTOTP(rfc6238Secret, tt.want, time.Unix(tt.unix, 0), 0); !ok {
+			t.Errorf("validateTOTP rejected the vector at %d", tt.unix)
+		}
+	}
+}
+
+func TestValidateTOTPSkewWindow(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / totpPeriod
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		name   string
+		offset int64
+		want   bool
+	}{
+		{"two steps behind", -2, false},
+		{"one step behind", -1, true},
+		{"current step", 0, true},
+		{"one step ahead", 1, true},
+		{"two steps ahead", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := totpCode(key, step+tt.offset)
+			matched, ok := validateTOTP(rfc6238Secret, code, now, 0)
+			if ok != tt.want {
+				t.Fatalf("validateTOTP ok = %v, want %v", ok, tt.want)
+			}
+			if ok && matched != step+tt.offset {
+				t.Errorf("matched step %d, want %d", matched, step+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejectsReplayAndWrongCodes(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / totpPeriod
+	code := totpCode([]byte("12345678901234567890"), step)
+
+	if _, ok := validateTOTP(rfc6238Secret, code, now, step); ok {
+		t.Error("a code for an already used step was accepted")
+	}
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if _, ok := validateTOTP(rfc6238Secret, wrong, now, 0); ok {
+		t.Error("a wrong code was accepted")
+	}
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	tx := testutil.Tx(t)
+	user := testUser(t, tx)
+
+	service, err := NewMFAService(tx, TokenConfig{Secret: []byte("test")}, MFAConfig{Issuer: "test", EncryptionKey: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup, err := service.Setup(user.ID)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(setup.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := service.Enable(user.ID, totpCode(key, time.Now().Unix()/totpPeriod))
+	if err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	if err := service.Verify(user.ID, codes[0]); err != nil {
+		t.Fatalf("first use of a recovery code: %v", err)
+	}
+	if err := service.Verify(user.ID, codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("second use of a recovery code: got %v, want ErrInvalidMFACode", err)
+	}
+	// Codes are case and dash insensitive, and the others are still good
+	if err := service.Verify(user.ID, strings.ToUpper(codes[1][:5]+codes[1][6:])); err != nil {
+		t.Errorf("unused recovery code without its dash: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"bac/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrEmailExists is returned when registering an address that already has an account
+var ErrEmailExists = errors.New("email already exists")
+
+// UserRepository is the single place the auth package reads and writes the users table
+type UserRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository creates a new UserRepository instance
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// WithTx returns a repository that runs its queries inside the given transaction
+func (r *UserRepository) WithTx(tx *gorm.DB) *UserRepository {
+	return &UserRepository{db: tx}
+}
+
+// FindByID loads a user, returning ErrUserNotFound when there is no such account
+func (r *UserRepository) FindByID(id int) (models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// FindByEmail loads a user by email address, returning ErrUserNotFound when there is no such account
+func (r *UserRepository) FindByEmail(email string) (models.User, error) {
+	var user models.User
+	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// List returns every user ordered by ID
+func (r *UserRepository) List() ([]models.User, error) {
+	users := []models.User{}
+	if err := r.db.Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Create inserts a user and grants them the named role in a single transaction
+func (r *UserRepository) Create(user *models.User, role string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ?", user.Email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrEmailExists
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(
+			"INSERT INTO user_roles (user_id, role_id) SELECT ?, id FROM roles WHERE name = ? ON CONFLICT DO NOTHING",
+			user.ID, role,
+		).Error
+	})
+}
+
+// MarkEmailVerified records that the user proved ownership of their address
+func (r *UserRepository) MarkEmailVerified(id int) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", time.Now()).Error
+}
+
+// SetPasswordHash replaces the stored password hash
+func (r *UserRepository) SetPasswordHash(id int, hash string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", id).
+		Update("password_hash", hash).Error
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"bac/internal/mail"
+	"bac/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidUserToken is returned for unknown, expired or already used tokens
+	ErrInvalidUserToken = errors.New("invalid or expired token")
+	// ErrEmailNotVerified is returned by Login when verification is required
+	ErrEmailNotVerified = errors.New("email not verified")
+)
+
+// VerificationConfig holds the settings for verification and reset emails
+type VerificationConfig struct {
+	FrontendURL             string
+	EmailVerificationExpiry time.Duration
+	PasswordResetExpiry     time.Duration
+}
+
+// EmailRequest structure
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// TokenRequest structure
+type TokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// PasswordResetRequest structure
+type PasswordResetRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// VerificationService issues and redeems email verification and password reset tokens
+type VerificationService struct {
+	db       *gorm.DB
+	mailer   mail.Mailer
+	sessions *SessionService
+	config   VerificationConfig
+}
+
+// NewVerificationService creates a new VerificationService instance
+func NewVerificationService(db *gorm.DB, mailer mail.Mailer, sessions *SessionService, config VerificationConfig) *VerificationService {
+	return &VerificationService{
+		db:       db,
+		mailer:   mailer,
+		sessions: sessions,
+		config:   config,
+	}
+}
+
+// RequestEmailVerification mails a verification link to an unverified account.
+// Unknown or already verified addresses are ignored so callers cannot probe for accounts.
+func (s *VerificationService) RequestEmailVerification(email string) error {
+	user, err := NewUserRepository(s.db).FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, err := issueUserToken(s.db, user.ID, models.TokenPurposeEmailVerification, s.config.EmailVerificationExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.FirstName, s.link("/verify-email", token), s.config.EmailVerificationExpiry,
+		),
+	})
+}
+
+// ConfirmEmailVerification marks the token owner's email address as verified
+func (s *VerificationService) ConfirmEmailVerification(token string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := redeemUserToken(tx, token, models.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+		return NewUserRepository(tx).MarkEmailVerified(userToken.UserID)
+	})
+}
+
+// RequestPasswordReset mails a password reset link. Unknown addresses are ignored.
+func (s *VerificationService) RequestPasswordReset(email string) error {
+	user, err := NewUserRepository(s.db).FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := issueUserToken(s.db, user.ID, models.TokenPurposePasswordReset, s.config.PasswordResetExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password for your account. If that was you, open the link below:\n\n%s\n\nThe link expires in %s. If you did not ask for a reset you can ignore this email.\n",
+			user.FirstName, s.link("/reset-password", token), s.config.PasswordResetExpiry,
+		),
+	})
+}
+
+// ResetPassword sets a new password and signs the user out of every session
+func (s *VerificationService) ResetPassword(req PasswordResetRequest) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("Error hashing password:", err)
+		return err
+	}
+
+	var userID int
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := redeemUserToken(tx, req.Token, models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = userToken.UserID
+
+		users := NewUserRepository(tx)
+		if err := users.SetPasswordHash(userID, string(hashedPassword)); err != nil {
+			return err
+		}
+		// Following the emailed link also proves ownership of the address
+		if err := users.MarkEmailVerified(userID); err != nil {
+			return err
+		}
+
+		// Any other outstanding reset links are no longer valid
+		return tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, models.TokenPurposePasswordReset).
+			Update("used_at", time.Now()).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.sessions.RevokeUser(userID)
+}
+
+// issueUserToken stores a hashed single-use token for the user and returns the plain value
+func issueUserToken(db *gorm.DB, userID int, purpose string, expiry time.Duration) (string, error) {
+	plain, err := generateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(plain),
+		ExpiresAt: time.Now().Add(expiry),
+	}
+	if err := db.Create(&token).Error; err != nil {
+		log.Println("Database error while creating user token:", err)
+		return "", err
+	}
+	return plain, nil
+}
+
+// redeemUserToken marks a token as used, failing if it is unknown, expired,
+// already used or was issued for another purpose
+func redeemUserToken(tx *gorm.DB, token, purpose string) (*models.UserToken, error) {
+	var userToken models.UserToken
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).
+		First(&userToken)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidUserToken
+		}
+		return nil, result.Error
+	}
+	if userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+
+	if err := tx.Model(&userToken).Update("used_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return &userToken, nil
+}
+
+func (s *VerificationService) link(path, token string) string {
+	return s.config.FrontendURL + path + "?token=" + url.QueryEscape(token)
+}
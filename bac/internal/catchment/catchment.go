@@ -0,0 +1,379 @@
+// Package catchment stores regional center service areas and answers which
+// center is responsible for a location
+package catchment
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"bac/internal/models"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoCatchment = errors.New("no regional center serves this location")
+	ErrUnknownZip  = errors.New("zip code not found")
+)
+
+// ImportOptions controls how GeoJSON feature properties map onto catchments
+type ImportOptions struct {
+	// CenterProperty holds either a regional_centers.id or a regional center name
+	CenterProperty string
+	NameProperty   string
+	ZipProperty    string
+	Source         string
+	// Replace deletes the existing areas of every center present in the file first
+	Replace bool
+	DryRun  bool
+}
+
+// ZipImportOptions controls how ZIP code area features are read
+type ZipImportOptions struct {
+	// ZipProperty defaults to the Census ZCTA field names
+	ZipProperty string
+	DryRun      bool
+}
+
+// ImportIssue explains why a feature was not imported
+type ImportIssue struct {
+	Feature int    `json:"feature"`
+	Reason  string `json:"reason"`
+}
+
+// ImportReport summarises an import
+type ImportReport struct {
+	Features int           `json:"features"`
+	Imported int           `json:"imported"`
+	Replaced int64         `json:"replaced"`
+	DryRun   bool          `json:"dry_run"`
+	Skipped  []ImportIssue `json:"skipped"`
+}
+
+// Service imports catchment polygons and resolves locations to regional centers
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a new Service instance
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// geometrySQL turns a GeoJSON geometry in the given SRID into a valid WGS84 multipolygon
+const geometrySQL = `ST_Multi(ST_CollectionExtract(ST_MakeValid(
+	ST_Transform(ST_SetSRID(ST_GeomFromGeoJSON(?), ?), 4326)), 3))`
+
+// ImportCatchments loads service area polygons from GeoJSON in a single transaction
+func (s *Service) ImportCatchments(r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	if opts.CenterProperty == "" {
+		opts.CenterProperty = "regional_center"
+	}
+	if opts.NameProperty == "" {
+		opts.NameProperty = "name"
+	}
+	if opts.ZipProperty == "" {
+		opts.ZipProperty = "zip_codes"
+	}
+
+	features, srid, err := readFeatures(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{Features: len(features), DryRun: opts.DryRun, Skipped: []ImportIssue{}}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		type pending struct {
+			index    int
+			centerID int
+			feature  feature
+		}
+		rows := []pending{}
+		centers := map[int]bool{}
+		for i, f := range features {
+			ref := stringProperty(f, opts.CenterProperty)
+			if ref == "" {
+				report.Skipped = append(report.Skipped, ImportIssue{i, "missing " + opts.CenterProperty + " property"})
+				continue
+			}
+			if len(f.Geometry) == 0 || string(f.Geometry) == "null" {
+				report.Skipped = append(report.Skipped, ImportIssue{i, "missing geometry"})
+				continue
+			}
+			centerID, err := resolveCenter(tx, ref)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					report.Skipped = append(report.Skipped, ImportIssue{i, "unknown regional center " + ref})
+					continue
+				}
+				return err
+			}
+			rows = append(rows, pending{i, centerID, f})
+			centers[centerID] = true
+		}
+
+		if opts.Replace && len(centers) > 0 {
+			ids := make([]int, 0, len(centers))
+			for id := range centers {
+				ids = append(ids, id)
+			}
+			result := tx.Where("regional_center_id IN ?", ids).Delete(&models.RegionalCenterCatchment{})
+			if result.Error != nil {
+				return result.Error
+			}
+			report.Replaced = result.RowsAffected
+		}
+
+		for _, row := range rows {
+			// A savepoint keeps one bad polygon from aborting the whole transaction
+			err := tx.Transaction(func(sp *gorm.DB) error {
+				return sp.Exec(`
+					INSERT INTO regional_center_catchments (regional_center_id, name, zip_codes, source, geom)
+					VALUES (?, ?, ?, ?, `+geometrySQL+`)
+				`, row.centerID, stringProperty(row.feature, opts.NameProperty),
+					pq.StringArray(listProperty(row.feature, opts.ZipProperty)), opts.Source,
+					string(row.feature.Geometry), srid).Error
+			})
+			if err != nil {
+				report.Skipped = append(report.Skipped, ImportIssue{row.index, err.Error()})
+				continue
+			}
+			report.Imported++
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	log.Printf("Catchment import: %d features, %d imported, %d skipped, dry run %t",
+		report.Features, report.Imported, len(report.Skipped), opts.DryRun)
+	return report, nil
+}
+
+// ImportZipAreas loads ZIP code tabulation area polygons, replacing existing rows for the same ZIP
+func (s *Service) ImportZipAreas(r io.Reader, opts ZipImportOptions) (*ImportReport, error) {
+	features, srid, err := readFeatures(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{Features: len(features), DryRun: opts.DryRun, Skipped: []ImportIssue{}}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i, f := range features {
+			zip := zipProperty(f, opts.ZipProperty)
+			if zip == "" {
+				report.Skipped = append(report.Skipped, ImportIssue{i, "missing zip code property"})
+				continue
+			}
+
+			err := tx.Transaction(func(sp *gorm.DB) error {
+				return sp.Exec(`
+					INSERT INTO zip_code_areas (zip_code, geom)
+					VALUES (?, `+geometrySQL+`)
+					ON CONFLICT (zip_code) DO UPDATE SET geom = EXCLUDED.geom
+				`, zip, string(f.Geometry), srid).Error
+			})
+			if err != nil {
+				report.Skipped = append(report.Skipped, ImportIssue{i, err.Error()})
+				continue
+			}
+			report.Imported++
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// errDryRun rolls back a dry-run import after every row has been validated
+var errDryRun = errors.New("dry run")
+
+// LookupPoint returns the regional center whose catchment contains the point
+func (s *Service) LookupPoint(lat, lng float64) (*models.CatchmentLookup, error) {
+	var catchment models.RegionalCenterCatchment
+	// Where areas overlap the smaller, more specific one wins
+	err := s.db.Raw(`
+		SELECT id, regional_center_id, name, zip_codes, source, created_at, updated_at
+		FROM regional_center_catchments
+		WHERE ST_Covers(geom, ST_SetSRID(ST_MakePoint(?, ?), 4326))
+		  AND regional_center_id IN (SELECT id FROM regional_centers WHERE deleted_at IS NULL)
+		ORDER BY ST_Area(geom)
+		LIMIT 1
+	`, lng, lat).Scan(&catchment).Error
+	if err != nil {
+		return nil, err
+	}
+	if catchment.ID == 0 {
+		return nil, ErrNoCatchment
+	}
+
+	return s.buildLookup("point", catchment, &point{Lat: lat, Lng: lng})
+}
+
+// LookupZip resolves a ZIP code, first through catchments that list it and then
+// by placing the ZIP area inside a catchment polygon
+func (s *Service) LookupZip(zip string) (*models.CatchmentLookup, error) {
+	zip = normalizeZip(zip)
+	if zip == "" {
+		return nil, ErrUnknownZip
+	}
+
+	// A point inside the ZIP area stands in for the family's address
+	var areas []point
+	if err := s.db.Raw(`
+		SELECT ST_Y(ST_PointOnSurface(geom)) AS lat, ST_X(ST_PointOnSurface(geom)) AS lng
+		FROM zip_code_areas
+		WHERE zip_code = ?
+	`, zip).Scan(&areas).Error; err != nil {
+		return nil, err
+	}
+	var inside *point
+	if len(areas) > 0 {
+		inside = &areas[0]
+	}
+
+	var catchment models.RegionalCenterCatchment
+	err := s.db.Where("? = ANY(zip_codes)", zip).
+		Where("regional_center_id IN (SELECT id FROM regional_centers WHERE deleted_at IS NULL)").
+		Order("id").Limit(1).Find(&catchment).Error
+	if err != nil {
+		return nil, err
+	}
+	if catchment.ID != 0 {
+		return s.buildLookup("zip", catchment, inside)
+	}
+
+	if inside == nil {
+		return nil, ErrUnknownZip
+	}
+	result, err := s.LookupPoint(inside.Lat, inside.Lng)
+	if err != nil {
+		return nil, err
+	}
+	result.MatchedBy = "zip"
+	return result, nil
+}
+
+type point struct {
+	Lat float64
+	Lng float64
+}
+
+// buildLookup loads the center and the office nearest to from. Without a
+// reference point the center's main office is returned instead.
+func (s *Service) buildLookup(matchedBy string, catchment models.RegionalCenterCatchment, from *point) (*models.CatchmentLookup, error) {
+	var center models.RegionalCenter
+	if err := s.db.Select(models.RegionalCenterColumns).First(&center, catchment.RegionalCenterID).Error; err != nil {
+		return nil, err
+	}
+
+	result := &models.CatchmentLookup{
+		MatchedBy:      matchedBy,
+		Catchment:      catchment,
+		RegionalCenter: center,
+	}
+
+	offices := []models.NearbyRegionalCenter{}
+	query := `
+		SELECT ` + models.NearbyRegionalCenterColumns + `
+		FROM regional_centers,
+		     (SELECT ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography AS point) AS search
+		WHERE regional_center = ? AND geog IS NOT NULL AND deleted_at IS NULL
+		ORDER BY geog <-> search.point
+		LIMIT 1
+	`
+	if from == nil {
+		query = `
+			SELECT ` + models.NearbyRegionalCenterColumns + `
+			FROM regional_centers,
+			     (SELECT geog AS point FROM regional_centers WHERE id = ?) AS search
+			WHERE regional_center = ? AND geog IS NOT NULL AND deleted_at IS NULL
+			ORDER BY id = ? DESC, id
+			LIMIT 1
+		`
+		if err := s.db.Raw(query, center.ID, center.RegionalCenter, center.ID).Scan(&offices).Error; err != nil {
+			return nil, err
+		}
+	} else if err := s.db.Raw(query, from.Lng, from.Lat, center.RegionalCenter).Scan(&offices).Error; err != nil {
+		return nil, err
+	}
+
+	if len(offices) > 0 {
+		offices[0].SetDistanceMiles()
+		result.NearestOffice = &offices[0]
+	}
+	return result, nil
+}
+
+// resolveCenter accepts a regional_centers.id or a center name. Names match the
+// center's main office, falling back to its lowest ID.
+func resolveCenter(tx *gorm.DB, ref string) (int, error) {
+	var center models.RegionalCenter
+	if id, err := strconv.Atoi(ref); err == nil {
+		if err := tx.Select("id").First(&center, id).Error; err != nil {
+			return 0, err
+		}
+		return int(center.ID), nil
+	}
+
+	err := tx.Select("id").
+		Where("regional_center ILIKE ?", ref).
+		Order("office_type ILIKE 'main%' DESC, id").
+		First(&center).Error
+	if err != nil {
+		return 0, err
+	}
+	return int(center.ID), nil
+}
+
+func zipProperty(f feature, name string) string {
+	if name != "" {
+		return normalizeZip(stringProperty(f, name))
+	}
+	for _, candidate := range []string{"ZCTA5CE20", "ZCTA5CE10", "GEOID20", "GEOID10", "zip_code", "zip", "ZIP"} {
+		if zip := normalizeZip(stringProperty(f, candidate)); zip != "" {
+			return zip
+		}
+	}
+	return ""
+}
+
+// normalizeZip reduces a ZIP or ZIP+4 to its five digits
+func normalizeZip(zip string) string {
+	zip = strings.TrimSpace(zip)
+	if i := strings.Index(zip, "-"); i >= 0 {
+		zip = zip[:i]
+	}
+	// ZIP+4 is often written without the hyphen
+	if len(zip) == 9 {
+		zip = zip[:5]
+	}
+	// Numeric properties lose leading zeros, e.g. 1234 for 01234
+	if len(zip) >= 3 && len(zip) < 5 {
+		zip = strings.Repeat("0", 5-len(zip)) + zip
+	}
+	if len(zip) != 5 {
+		return ""
+	}
+	for _, r := range zip {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	return zip
+}
@@ -0,0 +1,112 @@
+package catchment
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeZip(t *testing.T) {
+	tests := map[string]string{
+		"93701":      "93701",
+		" 93701 ":    "93701",
+		"93701-1234": "93701",
+		"937011234":  "93701",
+		"1234":       "01234",
+		"501":        "00501",
+		"12":         "",
+		"":           "",
+		"9370A":      "",
+		"93701-":     "93701",
+		"937012":     "",
+		"ZIP 93701":  "",
+		"9370112345": "",
+	}
+	for zip, want := range tests {
+		if got := normalizeZip(zip); got != want {
+			t.Errorf("normalizeZip(%q) = %q, want %q", zip, got, want)
+		}
+	}
+}
+
+func TestZipProperty(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]interface{}
+		property   string
+		want       string
+	}{
+		{"census 2020 column", map[string]interface{}{"ZCTA5CE20": "93701"}, "", "93701"},
+		{"numeric loses leading zero", map[string]interface{}{"GEOID10": float64(1234)}, "", "01234"},
+		{"first valid candidate", map[string]interface{}{"ZCTA5CE20": "", "zip": "93702"}, "", "93702"},
+		{"named property", map[string]interface{}{"ZCTA5CE20": "93701", "postal": "93703-0001"}, "postal", "93703"},
+		{"named property missing", map[string]interface{}{"ZCTA5CE20": "93701"}, "postal", ""},
+		{"no ZIP", map[string]interface{}{"name": "Fresno"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := zipProperty(feature{Properties: tt.properties}, tt.property); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListProperty(t *testing.T) {
+	f := feature{Properties: map[string]interface{}{
+		"array":  []interface{}{" 93701", float64(93702), true},
+		"string": "93701, 93702,,",
+		"number": float64(93701),
+	}}
+	tests := map[string][]string{
+		"array":   {"93701", "93702"},
+		"string":  {"93701", "93702"},
+		"number":  {},
+		"missing": {},
+	}
+	for name, want := range tests {
+		if got := listProperty(f, name); !reflect.DeepEqual(got, want) {
+			t.Errorf("listProperty(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestReadFeatures(t *testing.T) {
+	const polygon = `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}`
+	tests := []struct {
+		name     string
+		data     string
+		features int
+		srid     int
+		err      string
+	}{
+		{"collection", `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": ` + polygon + `}, {"type": "Feature"}]}`, 2, 4326, ""},
+		{"single feature", `{"type": "Feature", "properties": {"name": "A"}, "geometry": ` + polygon + `}`, 1, 4326, ""},
+		{"CRS84", `{"type": "FeatureCollection", "crs": {"properties": {"name": "urn:ogc:def:crs:OGC:1.3:CRS84"}}, "features": []}`, 0, 4326, ""},
+		{"EPSG code", `{"type": "FeatureCollection", "crs": {"properties": {"name": "urn:ogc:def:crs:EPSG::3310"}}, "features": []}`, 0, 3310, ""},
+		{"unsupported crs", `{"type": "FeatureCollection", "crs": {"properties": {"name": "local"}}, "features": []}`, 0, 0, "unsupported crs"},
+		{"geometry only", polygon, 0, 0, "expected a GeoJSON FeatureCollection or Feature"},
+		{"not JSON", `{"type":`, 0, 0, "invalid GeoJSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features, srid, err := readFeatures(strings.NewReader(tt.data))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(features) != tt.features || srid != tt.srid {
+				t.Errorf("got %d features in SRID %d, want %d in %d", len(features), srid, tt.features, tt.srid)
+			}
+		})
+	}
+	features, _, err := readFeatures(strings.NewReader(`{"type": "Feature", "properties": {"name": "A"}}`))
+	if err != nil || stringProperty(features[0], "name") != "A" {
+		t.Errorf("single feature properties lost: %+v, %v", features, err)
+	}
+}
@@ -0,0 +1,109 @@
+package catchment
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// featureCollection is the subset of GeoJSON the importers read. A bare Feature
+// is accepted too, which is what some shapefile converters emit for one polygon.
+type featureCollection struct {
+	Type     string    `json:"type"`
+	CRS      *crs      `json:"crs"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   json.RawMessage        `json:"geometry"`
+}
+
+// crs is the legacy GeoJSON 2008 member that ogr2ogr still writes for
+// projections other than WGS84
+type crs struct {
+	Properties struct {
+		Name string `json:"name"`
+	} `json:"properties"`
+}
+
+var epsgPattern = regexp.MustCompile(`EPSG:+(\d+)$`)
+
+// readFeatures decodes a FeatureCollection or single Feature and returns its
+// features with the SRID the coordinates are in
+func readFeatures(r io.Reader) ([]feature, int, error) {
+	var fc featureCollection
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := json.Unmarshal(raw, &fc); err != nil {
+		return nil, 0, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+
+	switch fc.Type {
+	case "FeatureCollection":
+	case "Feature":
+		var f feature
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return nil, 0, fmt.Errorf("invalid GeoJSON feature: %w", err)
+		}
+		fc.Features = []feature{f}
+	default:
+		return nil, 0, fmt.Errorf("expected a GeoJSON FeatureCollection or Feature, got %q", fc.Type)
+	}
+
+	srid := 4326
+	if fc.CRS != nil {
+		name := fc.CRS.Properties.Name
+		switch m := epsgPattern.FindStringSubmatch(name); {
+		case name == "", strings.HasSuffix(name, "CRS84"):
+			// OGC CRS84 is WGS84 in lng/lat order, which is what PostGIS expects anyway
+		case m != nil:
+			srid, _ = strconv.Atoi(m[1])
+		default:
+			return nil, 0, fmt.Errorf("unsupported crs %q", name)
+		}
+	}
+
+	return fc.Features, srid, nil
+}
+
+// stringProperty reads a property as text, accepting numbers for ID-like fields
+func stringProperty(f feature, name string) string {
+	switch v := f.Properties[name].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// listProperty reads a property holding either an array or a comma separated string
+func listProperty(f feature, name string) []string {
+	values := []string{}
+	switch v := f.Properties[name].(type) {
+	case []interface{}:
+		for _, item := range v {
+			switch s := item.(type) {
+			case string:
+				values = append(values, strings.TrimSpace(s))
+			case float64:
+				values = append(values, strconv.FormatFloat(s, 'f', -1, 64))
+			}
+		}
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
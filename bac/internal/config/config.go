@@ -1,8 +1,14 @@
 package config
 
 import (
+	"bac/internal/geocode"
+	"bac/internal/verification"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,8 +19,79 @@ type Config struct {
 	Environment string
 	ServerPort  int
 	JWTSecret   string
+	JWTIssuer   string
+	JWTAudience string
+	JWTExpiry   time.Duration
 	FrontendURL string
-	
+
+	// TrustedProxies are the addresses or CIDR ranges whose X-Forwarded-For
+	// headers are believed; with none the client IP is the connection's address
+	TrustedProxies []string
+
+	RefreshTokenExpiry time.Duration
+
+	// AdminEmail is granted the admin role at startup if the account exists
+	AdminEmail string
+
+	RequireEmailVerification bool
+	EmailVerificationExpiry  time.Duration
+	PasswordResetExpiry      time.Duration
+
+	MailDriver   string
+	MailFrom     string
+	MailLogDir   string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	LoginMaxAttemptsPerEmail int
+	LoginMaxAttemptsPerIP    int
+	LoginAttemptWindow       time.Duration
+	LoginLockoutBase         time.Duration
+	LoginLockoutMax          time.Duration
+
+	// Verification and reset emails per address and per client IP in MailRequestWindow
+	MailRequestsPerEmail int
+	MailRequestsPerIP    int
+	MailRequestWindow    time.Duration
+
+	MFAIssuer        string
+	MFAEncryptionKey string
+
+	APIKeyDefaultRateLimit int
+
+	// OIDC single sign-on is enabled when OIDCDiscoveryURL is set
+	OIDCDiscoveryURL string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	OIDCGroupsClaim  string
+	OIDCGroupRoles   map[string]string
+	// OIDCMFAValues are the amr or acr values that count as a second factor
+	OIDCMFAValues []string
+
+	Geocoder           string
+	GoogleMapsAPIKey   string
+	NominatimURL       string
+	GeocoderUserAgent  string
+	GeocoderCSVPath    string
+	GeocoderRPS        float64
+	GeocoderMaxRetries int
+	GeocodeCacheTTL    time.Duration
+
+	// Records not verified within VerificationStaleAfter are queued for staff;
+	// the scan runs every VerificationInterval, or never when it is zero
+	VerificationStaleAfter    time.Duration
+	VerificationInterval      time.Duration
+	VerificationCheckWebsites bool
+	VerificationHTTPTimeout   time.Duration
+
+	// Deleted directory records stay in the trash for TrashRetention; the
+	// purge runs every TrashPurgeInterval, or never when it is zero
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
 }
 
 func Load() (*Config, error) {
@@ -34,10 +111,207 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("DATABASE_URL is required but not set")
 	}
 
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil, fmt.Errorf("JWT_SECRET is required but not set")
+	}
+
+	jwtExpiry, err := getDurationWithDefault("JWT_EXPIRY", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// Long enough to cover a full shift without logging in again
+	refreshExpiry, err := getDurationWithDefault("REFRESH_TOKEN_EXPIRY", 12*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationExpiry, err := getDurationWithDefault("EMAIL_VERIFICATION_EXPIRY", 48*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	resetExpiry, err := getDurationWithDefault("PASSWORD_RESET_EXPIRY", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttemptsPerEmail, err := getIntWithDefault("LOGIN_MAX_ATTEMPTS_PER_EMAIL", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttemptsPerIP, err := getIntWithDefault("LOGIN_MAX_ATTEMPTS_PER_IP", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	attemptWindow, err := getDurationWithDefault("LOGIN_ATTEMPT_WINDOW", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	lockoutBase, err := getDurationWithDefault("LOGIN_LOCKOUT_BASE", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	lockoutMax, err := getDurationWithDefault("LOGIN_LOCKOUT_MAX", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies := strings.Fields(strings.ReplaceAll(os.Getenv("TRUSTED_PROXIES"), ",", " "))
+	for _, proxy := range trustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", proxy)
+			}
+		}
+	}
+
+	mailRequestsPerEmail, err := getIntWithDefault("MAIL_REQUESTS_PER_EMAIL", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	mailRequestsPerIP, err := getIntWithDefault("MAIL_REQUESTS_PER_IP", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	mailRequestWindow, err := getDurationWithDefault("MAIL_REQUEST_WINDOW", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// The log driver writes reset and verification links to disk
+	environment := getEnvWithDefault("ENV", "development")
+	mailDriver := getEnvWithDefault("MAIL_DRIVER", "log")
+	if mailDriver == "log" && (environment != "development" || os.Getenv("APP_ENV") == "production") {
+		return nil, fmt.Errorf("MAIL_DRIVER=log is only allowed in development; set MAIL_DRIVER=smtp")
+	}
+
+	apiKeyRateLimit, err := getIntWithDefault("API_KEY_DEFAULT_RATE_LIMIT", 60)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcGroupRoles, err := getMapFromEnv("OIDC_GROUP_ROLES")
+	if err != nil {
+		return nil, err
+	}
+
+	geocoderRPS, err := getFloatWithDefault("GEOCODER_RPS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	geocoderMaxRetries, err := getIntWithDefault("GEOCODER_MAX_RETRIES", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	geocodeCacheTTL, err := getDurationWithDefault("GEOCODE_CACHE_TTL", 90*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationStaleAfter, err := getDurationWithDefault("VERIFICATION_STALE_AFTER", 180*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationInterval, err := getDurationWithDefault("VERIFICATION_INTERVAL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationHTTPTimeout, err := getDurationWithDefault("VERIFICATION_HTTP_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	trashRetention, err := getDurationWithDefault("TRASH_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	trashPurgeInterval, err := getDurationWithDefault("TRASH_PURGE_INTERVAL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
 		DatabaseURL: dbURL,
 		Port:        getEnvWithDefault("PORT", "3000"),
-		Environment: getEnvWithDefault("ENV", "development"),
+		Environment: environment,
+		JWTSecret:   jwtSecret,
+		JWTIssuer:   getEnvWithDefault("JWT_ISSUER", "bac"),
+		JWTAudience: getEnvWithDefault("JWT_AUDIENCE", "bac-api"),
+		JWTExpiry:   jwtExpiry,
+		FrontendURL: getEnvWithDefault("FRONTEND_URL", "http://localhost:8080"),
+
+		TrustedProxies: trustedProxies,
+
+		RefreshTokenExpiry: refreshExpiry,
+
+		AdminEmail: os.Getenv("ADMIN_EMAIL"),
+
+		RequireEmailVerification: getEnvWithDefault("REQUIRE_EMAIL_VERIFICATION", "true") == "true",
+		EmailVerificationExpiry:  verificationExpiry,
+		PasswordResetExpiry:      resetExpiry,
+
+		MailDriver:   mailDriver,
+		MailFrom:     getEnvWithDefault("MAIL_FROM", "no-reply@localhost"),
+		MailLogDir:   os.Getenv("MAIL_LOG_DIR"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvWithDefault("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+
+		LoginMaxAttemptsPerEmail: maxAttemptsPerEmail,
+		LoginMaxAttemptsPerIP:    maxAttemptsPerIP,
+		LoginAttemptWindow:       attemptWindow,
+		LoginLockoutBase:         lockoutBase,
+		LoginLockoutMax:          lockoutMax,
+
+		MailRequestsPerEmail: mailRequestsPerEmail,
+		MailRequestsPerIP:    mailRequestsPerIP,
+		MailRequestWindow:    mailRequestWindow,
+
+		MFAIssuer: getEnvWithDefault("MFA_ISSUER", "BAC Directory"),
+		// Falls back to the JWT secret; set a dedicated key so JWT secrets can rotate
+		MFAEncryptionKey: getEnvWithDefault("MFA_ENCRYPTION_KEY", jwtSecret),
+
+		APIKeyDefaultRateLimit: apiKeyRateLimit,
+
+		OIDCDiscoveryURL: os.Getenv("OIDC_DISCOVERY_URL"),
+		OIDCClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:  getEnvWithDefault("OIDC_REDIRECT_URL", "http://localhost:3000/api/oidc/callback"),
+		OIDCScopes:       strings.Fields(getEnvWithDefault("OIDC_SCOPES", "openid email profile")),
+		OIDCGroupsClaim:  getEnvWithDefault("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCGroupRoles:   oidcGroupRoles,
+		OIDCMFAValues:    strings.Fields(getEnvWithDefault("OIDC_MFA_VALUES", "mfa")),
+
+		Geocoder:           getEnvWithDefault("GEOCODER", "none"),
+		GoogleMapsAPIKey:   os.Getenv("GOOGLE_MAPS_API_KEY"),
+		NominatimURL:       os.Getenv("NOMINATIM_URL"),
+		GeocoderUserAgent:  getEnvWithDefault("GEOCODER_USER_AGENT", "bac-directory-geocoder"),
+		GeocoderCSVPath:    os.Getenv("GEOCODER_CSV_PATH"),
+		GeocoderRPS:        geocoderRPS,
+		GeocoderMaxRetries: geocoderMaxRetries,
+		GeocodeCacheTTL:    geocodeCacheTTL,
+
+		VerificationStaleAfter:    verificationStaleAfter,
+		VerificationInterval:      verificationInterval,
+		VerificationCheckWebsites: getEnvWithDefault("VERIFICATION_CHECK_WEBSITES", "true") == "true",
+		VerificationHTTPTimeout:   verificationHTTPTimeout,
+
+		TrashRetention:     trashRetention,
+		TrashPurgeInterval: trashPurgeInterval,
 	}, nil
 }
 
@@ -47,3 +321,84 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getIntWithDefault parses an integer environment variable
+func getIntWithDefault(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer for %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// getFloatWithDefault parses a floating point environment variable
+func getFloatWithDefault(key string, defaultValue float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number for %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// getDurationWithDefault parses a Go duration string such as "15m" or "24h"
+func getDurationWithDefault(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration for %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// getMapFromEnv parses a "key=value,key2=value2" environment variable
+func getMapFromEnv(key string) (map[string]string, error) {
+	result := map[string]string{}
+	value := os.Getenv(key)
+	if value == "" {
+		return result, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("invalid entry %q in %s, expected key=value", pair, key)
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result, nil
+}
+
+// GeocoderConfig builds the geocoder settings shared by the API server and the geocode tool
+func (c *Config) GeocoderConfig() geocode.Config {
+	return geocode.Config{
+		Provider:          c.Geocoder,
+		GoogleAPIKey:      c.GoogleMapsAPIKey,
+		NominatimURL:      c.NominatimURL,
+		UserAgent:         c.GeocoderUserAgent,
+		CSVPath:           c.GeocoderCSVPath,
+		RequestsPerSecond: c.GeocoderRPS,
+		MaxRetries:        c.GeocoderMaxRetries,
+		CacheTTL:          c.GeocodeCacheTTL,
+	}
+}
+
+// VerificationConfig builds the stale-record scan settings
+func (c *Config) VerificationConfig() verification.Config {
+	return verification.Config{
+		StaleAfter:    c.VerificationStaleAfter,
+		Interval:      c.VerificationInterval,
+		CheckWebsites: c.VerificationCheckWebsites,
+		HTTPTimeout:   c.VerificationHTTPTimeout,
+		UserAgent:     "bac-directory-verifier",
+	}
+}
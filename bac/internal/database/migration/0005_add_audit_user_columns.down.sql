@@ -0,0 +1,11 @@
+ALTER TABLE IF EXISTS resource_centers
+    DROP COLUMN IF EXISTS updated_by,
+    DROP COLUMN IF EXISTS created_by;
+
+ALTER TABLE IF EXISTS aba_centers
+    DROP COLUMN IF EXISTS updated_by,
+    DROP COLUMN IF EXISTS created_by;
+
+ALTER TABLE IF EXISTS resources
+    DROP COLUMN IF EXISTS updated_by,
+    DROP COLUMN IF EXISTS created_by;
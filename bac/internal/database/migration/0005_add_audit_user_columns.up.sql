@@ -0,0 +1,13 @@
+-- Up migration
+-- Track which user created and last updated each directory row
+ALTER TABLE IF EXISTS resources
+    ADD COLUMN IF NOT EXISTS created_by INTEGER,
+    ADD COLUMN IF NOT EXISTS updated_by INTEGER;
+
+ALTER TABLE IF EXISTS aba_centers
+    ADD COLUMN IF NOT EXISTS created_by INTEGER,
+    ADD COLUMN IF NOT EXISTS updated_by INTEGER;
+
+ALTER TABLE IF EXISTS resource_centers
+    ADD COLUMN IF NOT EXISTS created_by INTEGER,
+    ADD COLUMN IF NOT EXISTS updated_by INTEGER;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_regional_centers_geog;
+DROP TRIGGER IF EXISTS trg_regional_centers_sync_geog ON regional_centers;
+DROP FUNCTION IF EXISTS regional_centers_sync_geog();
+DROP FUNCTION IF EXISTS regional_center_point(TEXT);
+
+ALTER TABLE IF EXISTS regional_centers
+    DROP COLUMN IF EXISTS geog;
@@ -0,0 +1,61 @@
+-- Up migration
+-- Nearest-center search ranks offices by distance, so each office gets a
+-- geography point parsed from its "(lat, lng)" text. The table normally comes
+-- from the state directory export; its columns are declared here for a
+-- database that has not loaded it yet.
+CREATE TABLE IF NOT EXISTS regional_centers (
+    id SERIAL PRIMARY KEY,
+    regional_center TEXT,
+    office_type TEXT,
+    address TEXT,
+    suite TEXT,
+    city TEXT,
+    state TEXT,
+    zip_code TEXT,
+    telephone TEXT,
+    website TEXT,
+    county_served TEXT,
+    los_angeles_health_district TEXT,
+    location_coordinates TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Geography so distances and radii are in meters on the spheroid
+ALTER TABLE regional_centers
+    ADD COLUMN IF NOT EXISTS geog geography(Point, 4326);
+
+-- location_coordinates is stored as "(lat, lng)"
+CREATE OR REPLACE FUNCTION regional_center_point(coordinates TEXT)
+RETURNS geography AS $$
+DECLARE
+    parts TEXT[];
+BEGIN
+    parts := regexp_match(coordinates, '\((-?\d+\.?\d*),\s*(-?\d+\.?\d*)\)');
+    IF parts IS NULL THEN
+        RETURN NULL;
+    END IF;
+    RETURN ST_SetSRID(ST_MakePoint(parts[2]::DOUBLE PRECISION, parts[1]::DOUBLE PRECISION), 4326)::geography;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+UPDATE regional_centers
+SET geog = regional_center_point(location_coordinates)
+WHERE geog IS NULL;
+
+-- Keep the point in step with the text until every writer uses the spatial column
+CREATE OR REPLACE FUNCTION regional_centers_sync_geog()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.geog := regional_center_point(NEW.location_coordinates);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_regional_centers_sync_geog ON regional_centers;
+CREATE TRIGGER trg_regional_centers_sync_geog
+    BEFORE INSERT OR UPDATE OF location_coordinates ON regional_centers
+    FOR EACH ROW EXECUTE FUNCTION regional_centers_sync_geog();
+
+-- GiST index backs both ST_DWithin and the <-> nearest-neighbour ordering
+CREATE INDEX IF NOT EXISTS idx_regional_centers_geog ON regional_centers USING gist (geog);
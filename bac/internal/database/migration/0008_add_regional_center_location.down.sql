@@ -0,0 +1,20 @@
+DROP INDEX IF EXISTS idx_regional_centers_location;
+
+-- Restore the text-driven trigger from 0007
+CREATE OR REPLACE FUNCTION regional_centers_sync_geog()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.geog := regional_center_point(NEW.location_coordinates);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_regional_centers_sync_geog ON regional_centers;
+CREATE TRIGGER trg_regional_centers_sync_geog
+    BEFORE INSERT OR UPDATE OF location_coordinates ON regional_centers
+    FOR EACH ROW EXECUTE FUNCTION regional_centers_sync_geog();
+
+DROP TABLE IF EXISTS regional_center_coordinate_errors;
+
+ALTER TABLE IF EXISTS regional_centers
+    DROP COLUMN IF EXISTS location;
@@ -0,0 +1,64 @@
+-- Up migration
+-- Store regional center coordinates in a real geometry column instead of the
+-- "(lat, lng)" text that was re-parsed on every request
+ALTER TABLE regional_centers
+    ADD COLUMN IF NOT EXISTS location geometry(Point, 4326);
+
+UPDATE regional_centers
+SET location = regional_center_point(location_coordinates)::geometry
+WHERE location IS NULL;
+
+-- Rows whose text could not be parsed are kept here for someone to fix by hand
+CREATE TABLE IF NOT EXISTS regional_center_coordinate_errors (
+    regional_center_id INTEGER PRIMARY KEY,
+    regional_center TEXT,
+    location_coordinates TEXT,
+    recorded_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO regional_center_coordinate_errors (regional_center_id, regional_center, location_coordinates)
+SELECT id, regional_center, location_coordinates
+FROM regional_centers
+WHERE location IS NULL
+ON CONFLICT (regional_center_id) DO UPDATE
+SET location_coordinates = EXCLUDED.location_coordinates,
+    recorded_at = CURRENT_TIMESTAMP;
+
+DO $$
+DECLARE
+    bad RECORD;
+BEGIN
+    FOR bad IN SELECT regional_center_id, regional_center, location_coordinates FROM regional_center_coordinate_errors LOOP
+        RAISE WARNING 'regional center % (%) has unparsable coordinates: %',
+            bad.regional_center_id, bad.regional_center, COALESCE(bad.location_coordinates, '<empty>');
+    END LOOP;
+END $$;
+
+-- location is now the source of truth: it is filled from the text only when a
+-- writer changes the text without setting the point, and geog follows location
+CREATE OR REPLACE FUNCTION regional_centers_sync_geog()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.location IS NULL THEN
+            NEW.location := regional_center_point(NEW.location_coordinates)::geometry;
+        END IF;
+    ELSIF NEW.location_coordinates IS DISTINCT FROM OLD.location_coordinates
+        AND NEW.location IS NOT DISTINCT FROM OLD.location THEN
+        NEW.location := regional_center_point(NEW.location_coordinates)::geometry;
+    END IF;
+    NEW.geog := NEW.location::geography;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_regional_centers_sync_geog ON regional_centers;
+CREATE TRIGGER trg_regional_centers_sync_geog
+    BEFORE INSERT OR UPDATE ON regional_centers
+    FOR EACH ROW EXECUTE FUNCTION regional_centers_sync_geog();
+
+UPDATE regional_centers
+SET geog = location::geography
+WHERE location IS NOT NULL AND geog IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_regional_centers_location ON regional_centers USING gist (location);
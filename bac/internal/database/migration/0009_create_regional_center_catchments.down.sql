@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS zip_code_areas;
+DROP TABLE IF EXISTS regional_center_catchments;
@@ -0,0 +1,26 @@
+-- Up migration
+-- Service areas: families are assigned to a regional center by where they live
+CREATE TABLE IF NOT EXISTS regional_center_catchments (
+    id SERIAL PRIMARY KEY,
+    regional_center_id INTEGER NOT NULL REFERENCES regional_centers (id) ON DELETE CASCADE,
+    name TEXT,
+    -- ZIP codes the source data assigns wholly to this area, when it lists them
+    zip_codes TEXT[] NOT NULL DEFAULT '{}',
+    source TEXT,
+    geom geometry(MultiPolygon, 4326) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_regional_center_catchments_geom ON regional_center_catchments USING gist (geom);
+CREATE INDEX IF NOT EXISTS idx_regional_center_catchments_zip_codes ON regional_center_catchments USING gin (zip_codes);
+CREATE INDEX IF NOT EXISTS idx_regional_center_catchments_center ON regional_center_catchments (regional_center_id);
+
+-- ZIP code tabulation areas, used to place a ZIP inside a catchment
+CREATE TABLE IF NOT EXISTS zip_code_areas (
+    zip_code TEXT PRIMARY KEY,
+    geom geometry(MultiPolygon, 4326) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_zip_code_areas_geom ON zip_code_areas USING gist (geom);
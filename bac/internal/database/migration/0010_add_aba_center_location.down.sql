@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_aba_centers_location;
+
+ALTER TABLE IF EXISTS aba_centers
+    DROP COLUMN IF EXISTS location,
+    DROP COLUMN IF EXISTS geocoded_at,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
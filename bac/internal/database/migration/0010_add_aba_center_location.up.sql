@@ -0,0 +1,40 @@
+-- Up migration
+-- Radius search needs coordinates for every ABA center. The server's
+-- AutoMigrate of models.ABACenter runs after the migrations, so the table is
+-- declared with the model's columns and types in case this runs first.
+CREATE TABLE IF NOT EXISTS aba_centers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    street TEXT NOT NULL,
+    city TEXT NOT NULL,
+    zip TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    service_type TEXT NOT NULL,
+    waitlist_availability TEXT,
+    waitlist_notes TEXT,
+    dx_verification TEXT,
+    insurance_accepted TEXT,
+    medi_cal_plans TEXT,
+    notes TEXT,
+    created_by INTEGER,
+    updated_by INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE
+);
+
+ALTER TABLE aba_centers
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS geocoded_at TIMESTAMP WITH TIME ZONE;
+
+-- The point always follows latitude/longitude so writers only set those
+ALTER TABLE aba_centers
+    ADD COLUMN IF NOT EXISTS location geometry(Point, 4326)
+    GENERATED ALWAYS AS (
+        CASE WHEN latitude IS NOT NULL AND longitude IS NOT NULL
+            THEN ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)
+        END
+    ) STORED;
+
+-- Distance searches run on geography so radii are in meters
+CREATE INDEX IF NOT EXISTS idx_aba_centers_location ON aba_centers USING gist ((location::geography));
@@ -0,0 +1,14 @@
+-- Up migration
+-- Geocoding results keyed by provider and normalized address, so bulk runs
+-- and repeated saves do not pay for the same lookup twice
+CREATE TABLE IF NOT EXISTS geocode_cache (
+    provider TEXT NOT NULL,
+    address_key TEXT NOT NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    formatted_address TEXT,
+    confidence DOUBLE PRECISION,
+    not_found BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, address_key)
+);
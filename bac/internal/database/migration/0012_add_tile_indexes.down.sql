@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_aba_centers_location_geom;
+DROP INDEX IF EXISTS idx_providers_location;
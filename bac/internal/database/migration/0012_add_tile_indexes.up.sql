@@ -0,0 +1,24 @@
+-- Up migration
+-- Vector tiles filter each layer by the tile envelope with &&, which needs a
+-- planar GiST index on the exact geometry expression the tile query uses
+
+-- Columns as the provider spreadsheet import writes them, for a database
+-- that has no providers yet
+CREATE TABLE IF NOT EXISTS providers (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    phone TEXT,
+    address TEXT,
+    coverage_areas TEXT,
+    center_based_services TEXT,
+    areas TEXT,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION
+);
+
+CREATE INDEX IF NOT EXISTS idx_providers_location ON providers USING gist (
+    ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)
+);
+
+-- The existing aba_centers index is on geography for radius searches
+CREATE INDEX IF NOT EXISTS idx_aba_centers_location_geom ON aba_centers USING gist (location);
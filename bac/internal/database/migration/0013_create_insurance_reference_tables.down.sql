@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS insurance_mapping_reviews;
+DROP TABLE IF EXISTS aba_center_medi_cal_plans;
+DROP TABLE IF EXISTS aba_center_insurance_carriers;
+DROP TABLE IF EXISTS medi_cal_plans;
+DROP TABLE IF EXISTS insurance_carriers;
+DROP FUNCTION IF EXISTS insurance_name_tokens(TEXT);
+DROP FUNCTION IF EXISTS insurance_name_key(TEXT);
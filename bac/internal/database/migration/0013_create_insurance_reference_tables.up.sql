@@ -0,0 +1,135 @@
+-- Up migration
+-- Insurance carriers and Medi-Cal managed care plans become reference data
+-- linked to ABA centers, replacing ILIKE matching on the free-text columns.
+-- The text columns are kept as entered; the links are what search uses.
+
+-- insurance_name_key reduces a name to lower case words so "Blue-Shield" and
+-- "blue shield" compare equal. The Go side (insurance.Key) must match it.
+CREATE OR REPLACE FUNCTION insurance_name_key(name TEXT) RETURNS TEXT AS $$
+    SELECT btrim(regexp_replace(lower(coalesce(name, '')), '[^a-z0-9]+', ' ', 'g'))
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE TABLE IF NOT EXISTS insurance_carriers (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    -- Other spellings, stored as insurance_name_key values
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS medi_cal_plans (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Join tables use the column and constraint names GORM gives many2many links
+CREATE TABLE IF NOT EXISTS aba_center_insurance_carriers (
+    aba_center_id UUID NOT NULL,
+    insurance_carrier_id INTEGER NOT NULL,
+    PRIMARY KEY (aba_center_id, insurance_carrier_id),
+    CONSTRAINT fk_aba_center_insurance_carriers_aba_center
+        FOREIGN KEY (aba_center_id) REFERENCES aba_centers (id) ON DELETE CASCADE,
+    CONSTRAINT fk_aba_center_insurance_carriers_insurance_carrier
+        FOREIGN KEY (insurance_carrier_id) REFERENCES insurance_carriers (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_aba_center_insurance_carriers_carrier
+    ON aba_center_insurance_carriers (insurance_carrier_id);
+
+CREATE TABLE IF NOT EXISTS aba_center_medi_cal_plans (
+    aba_center_id UUID NOT NULL,
+    medi_cal_plan_id INTEGER NOT NULL,
+    PRIMARY KEY (aba_center_id, medi_cal_plan_id),
+    CONSTRAINT fk_aba_center_medi_cal_plans_aba_center
+        FOREIGN KEY (aba_center_id) REFERENCES aba_centers (id) ON DELETE CASCADE,
+    CONSTRAINT fk_aba_center_medi_cal_plans_medi_cal_plan
+        FOREIGN KEY (medi_cal_plan_id) REFERENCES medi_cal_plans (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_aba_center_medi_cal_plans_plan
+    ON aba_center_medi_cal_plans (medi_cal_plan_id);
+
+-- Values that matched no carrier or plan wait here for a person to map or ignore
+CREATE TABLE IF NOT EXISTS insurance_mapping_reviews (
+    id SERIAL PRIMARY KEY,
+    aba_center_id UUID NOT NULL REFERENCES aba_centers (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('insurance', 'medi_cal')),
+    raw_value TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'mapped', 'ignored')),
+    resolved_id INTEGER,
+    resolved_by BIGINT,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (aba_center_id, kind, raw_value)
+);
+CREATE INDEX IF NOT EXISTS idx_insurance_mapping_reviews_status ON insurance_mapping_reviews (status);
+
+INSERT INTO insurance_carriers (name, aliases) VALUES
+    ('Aetna', '{aetna,aetna better health}'),
+    ('Anthem Blue Cross', '{anthem,anthem blue cross,anthem bc,blue cross,blue cross of california}'),
+    ('Blue Shield of California', '{blue shield,blue shield of california,blue shield ca,bsc}'),
+    ('Cigna', '{cigna,cigna healthcare,evernorth}'),
+    ('Health Net', '{health net,healthnet}'),
+    ('Kaiser Permanente', '{kaiser,kaiser permanente}'),
+    ('UnitedHealthcare', '{united,united healthcare,unitedhealthcare,uhc,optum,united behavioral health,ubh}'),
+    ('Magellan Health', '{magellan,magellan health}'),
+    ('TRICARE', '{tricare}'),
+    ('Carelon Behavioral Health', '{carelon,carelon behavioral health,beacon,beacon health options}'),
+    ('MHN', '{mhn,managed health network}')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO medi_cal_plans (name, aliases) VALUES
+    ('L.A. Care Health Plan', '{la care,l a care,la care health plan}'),
+    ('Health Net Medi-Cal', '{health net,healthnet,health net medi cal}'),
+    ('Blue Shield Promise', '{blue shield promise,bs promise,care1st,care 1st}'),
+    ('Anthem Blue Cross Medi-Cal', '{anthem,anthem blue cross,anthem medi cal,anthem blue cross medi cal}'),
+    ('Molina Healthcare', '{molina,molina healthcare}'),
+    ('Kaiser Permanente Medi-Cal', '{kaiser,kaiser permanente,kaiser medi cal}'),
+    ('Inland Empire Health Plan', '{iehp,inland empire health plan}'),
+    ('CalOptima Health', '{caloptima,caloptima health}'),
+    ('Medi-Cal Fee-for-Service', '{fee for service,ffs,medi cal ffs,straight medi cal,regular medi cal}')
+ON CONFLICT (name) DO NOTHING;
+
+-- Split the free text into one value per carrier or plan
+CREATE OR REPLACE FUNCTION insurance_name_tokens(list TEXT) RETURNS TABLE (raw_value TEXT, key TEXT) AS $$
+    SELECT btrim(token), insurance_name_key(token)
+    FROM regexp_split_to_table(coalesce(list, ''), '[,;/|\n]+|\s+and\s+|\s+&\s+', 'i') AS token
+    WHERE insurance_name_key(token) NOT IN
+        ('', 'none', 'n a', 'na', 'no', 'unknown', 'tbd', 'yes', 'all', 'medi cal', 'medical', 'private pay', 'regional center')
+$$ LANGUAGE SQL IMMUTABLE;
+
+INSERT INTO aba_center_insurance_carriers (aba_center_id, insurance_carrier_id)
+SELECT DISTINCT a.id, c.id
+FROM aba_centers a
+CROSS JOIN LATERAL insurance_name_tokens(a.insurance_accepted) t
+JOIN insurance_carriers c ON t.key = insurance_name_key(c.name) OR t.key = ANY (c.aliases)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO aba_center_medi_cal_plans (aba_center_id, medi_cal_plan_id)
+SELECT DISTINCT a.id, p.id
+FROM aba_centers a
+CROSS JOIN LATERAL insurance_name_tokens(a.medi_cal_plans) t
+JOIN medi_cal_plans p ON t.key = insurance_name_key(p.name) OR t.key = ANY (p.aliases)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO insurance_mapping_reviews (aba_center_id, kind, raw_value)
+SELECT DISTINCT a.id, 'insurance', t.raw_value
+FROM aba_centers a
+CROSS JOIN LATERAL insurance_name_tokens(a.insurance_accepted) t
+WHERE NOT EXISTS (
+    SELECT 1 FROM insurance_carriers c
+    WHERE t.key = insurance_name_key(c.name) OR t.key = ANY (c.aliases)
+)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO insurance_mapping_reviews (aba_center_id, kind, raw_value)
+SELECT DISTINCT a.id, 'medi_cal', t.raw_value
+FROM aba_centers a
+CROSS JOIN LATERAL insurance_name_tokens(a.medi_cal_plans) t
+WHERE NOT EXISTS (
+    SELECT 1 FROM medi_cal_plans p
+    WHERE t.key = insurance_name_key(p.name) OR t.key = ANY (p.aliases)
+)
+ON CONFLICT DO NOTHING;
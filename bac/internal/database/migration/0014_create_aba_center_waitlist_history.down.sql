@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_aba_centers_last_verified_at;
+DROP INDEX IF EXISTS idx_aba_centers_waitlist_status;
+
+ALTER TABLE IF EXISTS aba_centers
+    DROP COLUMN IF EXISTS last_verified_at,
+    DROP COLUMN IF EXISTS estimated_wait_weeks,
+    DROP COLUMN IF EXISTS waitlist_status;
+
+DROP TABLE IF EXISTS aba_center_waitlist_history;
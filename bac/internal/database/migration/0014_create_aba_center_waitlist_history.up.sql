@@ -0,0 +1,36 @@
+-- Up migration
+-- Every waitlist check is recorded so a status can be traced to when and
+-- from whom it was confirmed; aba_centers keeps the latest one for search
+CREATE TABLE IF NOT EXISTS aba_center_waitlist_history (
+    id SERIAL PRIMARY KEY,
+    aba_center_id UUID NOT NULL REFERENCES aba_centers(id) ON DELETE CASCADE,
+    status TEXT NOT NULL CHECK (status IN ('open', 'short', 'long', 'closed')),
+    estimated_wait_weeks INTEGER CHECK (estimated_wait_weeks >= 0),
+    notes TEXT,
+    source TEXT NOT NULL,
+    verified_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    verified_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_aba_center_waitlist_history_center
+    ON aba_center_waitlist_history (aba_center_id, verified_at DESC);
+
+ALTER TABLE aba_centers
+    ADD COLUMN IF NOT EXISTS waitlist_status TEXT CHECK (waitlist_status IN ('open', 'short', 'long', 'closed')),
+    ADD COLUMN IF NOT EXISTS estimated_wait_weeks INTEGER,
+    ADD COLUMN IF NOT EXISTS last_verified_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_aba_centers_waitlist_status ON aba_centers (waitlist_status);
+CREATE INDEX IF NOT EXISTS idx_aba_centers_last_verified_at ON aba_centers (last_verified_at);
+
+-- Best-effort status from the old free text. last_verified_at stays empty
+-- because nobody knows when that text was last confirmed. Negations such as
+-- "Not available" are matched first so they don't read as open.
+UPDATE aba_centers SET waitlist_status = CASE
+        WHEN waitlist_availability ~* '(not (currently )?(accepting|available|open)|unavailable|no (openings?|availability|spots?)|none available|closed|full)' THEN 'closed'
+        WHEN waitlist_availability ~* '(no wait|none|immediate|available|open)' THEN 'open'
+        WHEN waitlist_availability ~* '(long|[6-9]\s*(\+|-)?\s*month|1[0-9]\s*month|year)' THEN 'long'
+        WHEN waitlist_availability ~* '(short|week|[1-5]\s*(\+|-)?\s*month)' THEN 'short'
+    END
+WHERE waitlist_status IS NULL AND waitlist_availability IS NOT NULL AND waitlist_availability <> '';
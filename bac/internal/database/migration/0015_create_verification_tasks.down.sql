@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS verification_tasks;
+
+ALTER TABLE IF EXISTS regional_centers DROP COLUMN IF EXISTS record_verified_at;
+ALTER TABLE IF EXISTS resources DROP COLUMN IF EXISTS record_verified_at;
+ALTER TABLE IF EXISTS providers DROP COLUMN IF EXISTS record_verified_at;
+ALTER TABLE IF EXISTS aba_centers DROP COLUMN IF EXISTS record_verified_at;
@@ -0,0 +1,33 @@
+-- Up migration
+-- record_verified_at is when staff last confirmed a directory record as a
+-- whole; the stale-record job flags records that have gone too long without it
+ALTER TABLE aba_centers ADD COLUMN IF NOT EXISTS record_verified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE providers ADD COLUMN IF NOT EXISTS record_verified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS record_verified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE regional_centers ADD COLUMN IF NOT EXISTS record_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Records flagged for re-verification. entity_id is text because the
+-- directory tables mix UUID and integer keys.
+CREATE TABLE IF NOT EXISTS verification_tasks (
+    id BIGSERIAL PRIMARY KEY,
+    entity_type TEXT NOT NULL CHECK (entity_type IN ('aba_centers', 'providers', 'resources', 'regional_centers')),
+    entity_id TEXT NOT NULL,
+    entity_name TEXT,
+    reasons TEXT[] NOT NULL DEFAULT '{}',
+    details TEXT[] NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'assigned', 'completed')),
+    assigned_to BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    assigned_at TIMESTAMP WITH TIME ZONE,
+    outcome TEXT CHECK (outcome IN ('verified', 'updated', 'dismissed')),
+    notes TEXT,
+    completed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    last_flagged_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- A record has at most one task in progress; re-runs refresh it in place
+CREATE UNIQUE INDEX IF NOT EXISTS idx_verification_tasks_active
+    ON verification_tasks (entity_type, entity_id) WHERE status <> 'completed';
+CREATE INDEX IF NOT EXISTS idx_verification_tasks_status ON verification_tasks (status, assigned_to);
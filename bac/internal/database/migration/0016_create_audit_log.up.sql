@@ -0,0 +1,19 @@
+-- Up migration
+-- One row per change to a directory record. before_state and after_state are
+-- full JSON snapshots so any version can be viewed or restored; changes holds
+-- the field-level diff between them.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    operation TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete', 'revert')),
+    actor_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    changes JSONB NOT NULL DEFAULT '[]',
+    before_state JSONB,
+    after_state JSONB,
+    reverted_from BIGINT REFERENCES audit_log(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity_type, entity_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor_id, id DESC);
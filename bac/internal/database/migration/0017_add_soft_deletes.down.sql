@@ -0,0 +1,17 @@
+DELETE FROM audit_log WHERE operation IN ('restore', 'purge');
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_operation_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_operation_check
+    CHECK (operation IN ('create', 'update', 'delete', 'revert'));
+
+DROP INDEX IF EXISTS idx_resource_centers_deleted_at;
+DROP INDEX IF EXISTS idx_providers_deleted_at;
+DROP INDEX IF EXISTS idx_regional_centers_deleted_at;
+DROP INDEX IF EXISTS idx_resources_deleted_at;
+DROP INDEX IF EXISTS idx_aba_centers_deleted_at;
+
+-- Rows still in the trash reappear as live records
+ALTER TABLE IF EXISTS providers DROP COLUMN IF EXISTS deleted_by, DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE IF EXISTS resource_centers DROP COLUMN IF EXISTS deleted_by, DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE IF EXISTS regional_centers DROP COLUMN IF EXISTS deleted_by, DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE IF EXISTS resources DROP COLUMN IF EXISTS deleted_by, DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE IF EXISTS aba_centers DROP COLUMN IF EXISTS deleted_by, DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,39 @@
+-- Up migration
+-- Deleting a directory row now only stamps deleted_at; the row stays in the
+-- admin trash until it is restored or purged after the retention period
+ALTER TABLE IF EXISTS aba_centers
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS deleted_by INTEGER;
+
+ALTER TABLE IF EXISTS resources
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS deleted_by INTEGER;
+
+ALTER TABLE IF EXISTS regional_centers
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS deleted_by INTEGER;
+
+ALTER TABLE IF EXISTS resource_centers
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS deleted_by INTEGER;
+
+ALTER TABLE IF EXISTS providers
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS deleted_by INTEGER;
+
+-- Only the trash listing and the purge look for deleted rows
+CREATE INDEX IF NOT EXISTS idx_aba_centers_deleted_at ON aba_centers (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_resources_deleted_at ON resources (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_regional_centers_deleted_at ON regional_centers (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_providers_deleted_at ON providers (deleted_at) WHERE deleted_at IS NOT NULL;
+DO $$
+BEGIN
+    IF to_regclass('resource_centers') IS NOT NULL THEN
+        CREATE INDEX IF NOT EXISTS idx_resource_centers_deleted_at ON resource_centers (deleted_at) WHERE deleted_at IS NOT NULL;
+    END IF;
+END $$;
+
+-- Restores and purges are recorded in the audit log too
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_operation_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_operation_check
+    CHECK (operation IN ('create', 'update', 'delete', 'revert', 'restore', 'purge'));
@@ -0,0 +1,14 @@
+DROP VIEW IF EXISTS regional_center_coordinate_errors;
+
+CREATE TABLE IF NOT EXISTS regional_center_coordinate_errors (
+    regional_center_id INTEGER PRIMARY KEY,
+    regional_center TEXT,
+    location_coordinates TEXT,
+    recorded_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO regional_center_coordinate_errors (regional_center_id, regional_center, location_coordinates)
+SELECT id, regional_center, location_coordinates
+FROM regional_centers
+WHERE location IS NULL AND deleted_at IS NULL
+ON CONFLICT (regional_center_id) DO NOTHING;
@@ -0,0 +1,10 @@
+-- Up migration
+-- regional_center_coordinate_errors was a snapshot taken by 0008, so rows
+-- stayed listed after their coordinates were fixed. Derive it from the live
+-- regional centers instead.
+DROP TABLE IF EXISTS regional_center_coordinate_errors;
+
+CREATE OR REPLACE VIEW regional_center_coordinate_errors AS
+SELECT id AS regional_center_id, regional_center, location_coordinates
+FROM regional_centers
+WHERE location IS NULL AND deleted_at IS NULL;
@@ -0,0 +1,6 @@
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name IN ('viewer', 'editor')
+  AND p.name = 'read:users'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,10 @@
+-- Up migration
+-- Every self-registered account gets the viewer role, so read:users let anyone
+-- list staff accounts. Only admins keep it; SeedDefaults no longer grants it to
+-- viewer or editor, and this takes it back from databases seeded earlier.
+DELETE FROM role_permissions rp
+USING roles r, permissions p
+WHERE rp.role_id = r.id
+  AND rp.permission_id = p.id
+  AND r.name IN ('viewer', 'editor')
+  AND p.name = 'read:users';
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS oidc_states;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
+DROP TABLE IF EXISTS security_events;
+DROP TABLE IF EXISTS login_throttles;
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,3 @@
+-- The backfilled timestamps can't be told apart from real verifications, so
+-- they are left in place
+SELECT 1;
@@ -0,0 +1,11 @@
+-- Up migration
+-- Accounts that predate email verification were never sent a link, so
+-- REQUIRE_EMAIL_VERIFICATION would lock them out. Every account registered since
+-- has a verification token on record; the ones without are treated as verified.
+UPDATE users u
+SET email_verified_at = COALESCE(u.created_at, NOW())
+WHERE u.email_verified_at IS NULL
+  AND NOT EXISTS (
+      SELECT 1 FROM user_tokens t
+      WHERE t.user_id = u.id AND t.purpose = 'email_verification'
+  );
@@ -0,0 +1,2 @@
+-- The corrected statuses were wrong as open, so they are left in place
+SELECT 1;
@@ -0,0 +1,8 @@
+-- Up migration
+-- The first run of 0014's backfill read text such as "Not available" or
+-- "Not open" as open. Statuses nobody has confirmed since go through the
+-- corrected closed pattern again.
+UPDATE aba_centers SET waitlist_status = 'closed'
+WHERE waitlist_status = 'open'
+    AND last_verified_at IS NULL
+    AND waitlist_availability ~* '(not (currently )?(accepting|available|open)|unavailable|no (openings?|availability|spots?)|none available|closed|full)';
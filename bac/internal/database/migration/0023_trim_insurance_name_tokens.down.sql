@@ -0,0 +1,7 @@
+-- Back to trimming spaces only
+CREATE OR REPLACE FUNCTION insurance_name_tokens(list TEXT) RETURNS TABLE (raw_value TEXT, key TEXT) AS $$
+    SELECT btrim(token), insurance_name_key(token)
+    FROM regexp_split_to_table(coalesce(list, ''), '[,;/|\n]+|\s+and\s+|\s+&\s+', 'i') AS token
+    WHERE insurance_name_key(token) NOT IN
+        ('', 'none', 'n a', 'na', 'no', 'unknown', 'tbd', 'yes', 'all', 'medi cal', 'medical', 'private pay', 'regional center')
+$$ LANGUAGE SQL IMMUTABLE;
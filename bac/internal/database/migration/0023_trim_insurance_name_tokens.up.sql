@@ -0,0 +1,13 @@
+-- Up migration
+-- Matches insurance.Split: lists pasted from spreadsheets end lines with
+-- "\r\n", so tabs and carriage returns are trimmed as well as spaces, and
+-- "N/A" is kept whole instead of splitting into "N" and "A" for review
+CREATE OR REPLACE FUNCTION insurance_name_tokens(list TEXT) RETURNS TABLE (raw_value TEXT, key TEXT) AS $$
+    SELECT btrim(token, E' \t\n\x0b\f\r'), insurance_name_key(token)
+    FROM regexp_split_to_table(regexp_replace(coalesce(list, ''), '\yn/a\y', 'n a', 'gi'), '[,;/|\n]+|\s+and\s+|\s+&\s+', 'i') AS token
+    WHERE insurance_name_key(token) NOT IN
+        ('', 'none', 'n a', 'na', 'no', 'unknown', 'tbd', 'yes', 'all', 'medi cal', 'medical', 'private pay', 'regional center')
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Drop the halves of "N/A" that 0013 queued
+DELETE FROM insurance_mapping_reviews WHERE status = 'pending' AND lower(raw_value) IN ('n', 'a');
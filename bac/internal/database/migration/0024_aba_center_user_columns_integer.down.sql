@@ -0,0 +1,2 @@
+-- Both types held the same values, so there is nothing to undo
+SELECT 1;
@@ -0,0 +1,6 @@
+-- Up migration
+-- 0010 used to declare created_by and updated_by as BIGINT; every other
+-- user reference column and models.ABACenter use INTEGER
+ALTER TABLE aba_centers
+    ALTER COLUMN created_by TYPE INTEGER,
+    ALTER COLUMN updated_by TYPE INTEGER;
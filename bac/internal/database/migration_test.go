@@ -0,0 +1,130 @@
+package database
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"bac/internal/models"
+	"bac/internal/testutil"
+
+	"gorm.io/gorm"
+)
+
+// The auth tables come only from the SQL migrations, so every model column
+// must exist in a migrated database
+func TestMigrationsCoverAuthModels(t *testing.T) {
+	tx := testutil.Tx(t)
+	for _, model := range []interface{}{
+		&models.User{}, &models.Role{}, &models.Permission{},
+		&models.RefreshToken{}, &models.UserToken{}, &models.LoginThrottle{},
+		&models.SecurityEvent{}, &models.UserMFA{}, &models.MFARecoveryCode{},
+		&models.APIKey{}, &models.UserIdentity{}, &models.OIDCState{},
+	} {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		table := stmt.Schema.Table
+		if !tx.Migrator().HasTable(table) {
+			t.Errorf("no %s table", table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			if !tx.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("%s has no %s column", table, field.DBName)
+			}
+		}
+	}
+}
+
+// waitlistCases maps old free text onto the status the backfill gives it
+var waitlistCases = map[string]string{
+	"Open":                         "open",
+	"No wait":                      "open",
+	"Immediate availability":       "open",
+	"Currently accepting, no wait": "open",
+	"Not available":                "closed",
+	"Not open":                     "closed",
+	"NOT CURRENTLY ACCEPTING":      "closed",
+	"Unavailable":                  "closed",
+	"No openings":                  "closed",
+	"None available":               "closed",
+	"Closed":                       "closed",
+	"Waitlist full":                "closed",
+	"Long waitlist":                "long",
+	"6-9 months":                   "long",
+	"Over a year":                  "long",
+	"2 weeks":                      "short",
+	"3 months":                     "short",
+	"Call for details":             "",
+}
+
+func TestWaitlistBackfillMapping(t *testing.T) {
+	tx := testutil.Tx(t)
+	up, err := os.ReadFile("migration/0014_create_aba_center_waitlist_history.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := regexp.MustCompile(`(?s)SET waitlist_status = (CASE.*?END)\s+WHERE`).FindSubmatch(up)
+	if match == nil {
+		t.Fatal("no waitlist_status CASE in 0014")
+	}
+
+	for text, want := range waitlistCases {
+		var got *string
+		err := tx.Raw("SELECT "+string(match[1])+" FROM (SELECT ?::text AS waitlist_availability) t", text).Scan(&got).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status := deref(got); status != want {
+			t.Errorf("%q maps to %q, want %q", text, status, want)
+		}
+	}
+}
+
+// 0022 fixes centers the first version of the backfill marked open
+func TestReclassifyNegatedWaitlistStatus(t *testing.T) {
+	tx := testutil.Tx(t)
+	up, err := os.ReadFile("migration/0022_reclassify_negated_waitlist_status.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open := "open"
+	centers := map[string]*models.ABACenter{}
+	for text, want := range waitlistCases {
+		if want != "open" && want != "closed" {
+			continue
+		}
+		center := &models.ABACenter{Name: "Backfill " + text, Street: "1 Main St", City: "Fresno", Zip: "93701",
+			Phone: "5595550100", ServiceType: "Clinic", WaitlistAvailability: text, WaitlistStatus: &open}
+		if err := tx.Create(center).Error; err != nil {
+			t.Fatal(err)
+		}
+		centers[text] = center
+	}
+	if err := tx.Exec(string(up)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for text, center := range centers {
+		var got models.ABACenter
+		if err := tx.First(&got, "id = ?", center.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if status := deref(got.WaitlistStatus); status != waitlistCases[text] {
+			t.Errorf("%q is %q after 0022, want %q", text, status, waitlistCases[text])
+		}
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
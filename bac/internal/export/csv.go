@@ -0,0 +1,47 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// csvFlushEvery bounds how many rows are buffered before they reach the client
+const csvFlushEvery = 100
+
+type csvWriter struct {
+	w    *csv.Writer
+	rows int
+}
+
+func newCSVWriter(w io.Writer, columns []string) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	if err := cw.w.Write(columns); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(row Row) error {
+	// A leading apostrophe makes spreadsheets read the cell as text
+	values := make([]string, len(row.Values))
+	for i, v := range row.Values {
+		if isFormula(v) {
+			v = "'" + v
+		}
+		values[i] = v
+	}
+	if err := cw.w.Write(values); err != nil {
+		return err
+	}
+	cw.rows++
+	if cw.rows%csvFlushEvery == 0 {
+		cw.w.Flush()
+		return cw.w.Error()
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
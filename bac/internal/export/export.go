@@ -0,0 +1,64 @@
+// Package export streams directory records as CSV, XLSX or KML files
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format describes one export file type
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+}
+
+// Formats lists the supported export formats by name
+var Formats = map[string]Format{
+	"csv":  {"csv", "text/csv; charset=utf-8", "csv"},
+	"xlsx": {"xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"},
+	"kml":  {"kml", "application/vnd.google-earth.kml+xml", "kml"},
+}
+
+// Row is one exported record
+type Row struct {
+	// Name titles the record in KML; spreadsheets only use Values
+	Name      string
+	Values    []string
+	Latitude  *float64
+	Longitude *float64
+}
+
+// Writer writes rows one at a time so exports never hold a whole table in memory
+type Writer interface {
+	Write(row Row) error
+	// Close finishes the file; the underlying io.Writer is left open
+	Close() error
+}
+
+// isFormula reports whether a spreadsheet would evaluate v as a formula. Directory
+// text comes from the public and from imports, so it must never run in the
+// files case managers open. Plain numbers such as negative longitudes are safe.
+func isFormula(v string) bool {
+	if v == "" || !strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return false
+	}
+	_, err := strconv.ParseFloat(v, 64)
+	return err != nil
+}
+
+// NewWriter starts a file of the named format with the given column headers
+func NewWriter(format string, w io.Writer, title string, columns []string) (Writer, error) {
+	switch format {
+	case "csv":
+		return newCSVWriter(w, columns)
+	case "xlsx":
+		return newXLSXWriter(w, title, columns)
+	case "kml":
+		return newKMLWriter(w, title, columns)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
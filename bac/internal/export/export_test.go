@@ -0,0 +1,260 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testColumns = []string{"Name", "Phone", "Longitude"}
+
+// testRows holds ordinary values, numbers that start with a sign and text a
+// spreadsheet would otherwise evaluate
+var testRows = [][]string{
+	{"Bright Steps", "(559) 555-0100", "-119.7871"},
+	{`=HYPERLINK("http://example.com","Click")`, "+1 559 555 0100", "+119"},
+	{"@SUM(A1:A2)", "-2+3", "\tTabbed"},
+}
+
+// export writes testRows in format and returns the file
+func export(t *testing.T, format, title string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf, title, testColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range testRows {
+		if err := w.Write(Row{Name: values[0], Values: values}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIsFormula(t *testing.T) {
+	tests := map[string]bool{
+		"":                 false,
+		"Bright Steps":     false,
+		"-119.7871":        false,
+		"+119":             false,
+		"1e3":              false,
+		"=1+1":             true,
+		"+1 559 555 0100":  true,
+		"-2+3":             true,
+		"@SUM(A1:A2)":      true,
+		"\tTabbed":         true,
+		"\r=cmd|' /C calc": true,
+	}
+	for v, want := range tests {
+		if got := isFormula(v); got != want {
+			t.Errorf("isFormula(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	got, err := csv.NewReader(bytes.NewReader(export(t, "csv", "Centers"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		testColumns,
+		{"Bright Steps", "(559) 555-0100", "-119.7871"},
+		{`'=HYPERLINK("http://example.com","Click")`, "'+1 559 555 0100", "+119"},
+		{"'@SUM(A1:A2)", "'-2+3", "'\tTabbed"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// xlsxCell is a cell read back from the generated worksheet
+type xlsxCell struct {
+	Ref     string `xml:"r,attr"`
+	Type    string `xml:"t,attr"`
+	Style   int    `xml:"s,attr"`
+	Formula string `xml:"f"`
+	Text    string `xml:"is>t"`
+}
+
+// readPart returns one part of a zip file
+func readPart(t *testing.T, data []byte, name string) []byte {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := archive.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	body, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestXLSXWritesFormulasAsText(t *testing.T) {
+	data := export(t, "xlsx", "Centers")
+
+	var sheet struct {
+		Rows []struct {
+			Cells []xlsxCell `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(readPart(t, data, "xl/worksheets/sheet1.xml"), &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != len(testRows)+1 {
+		t.Fatalf("got %d rows, want %d", len(sheet.Rows), len(testRows)+1)
+	}
+	for r, row := range sheet.Rows {
+		want := testColumns
+		if r > 0 {
+			want = testRows[r-1]
+		}
+		for i, cell := range row.Cells {
+			// Values are kept as they are; only the style changes
+			if cell.Text != want[i] || cell.Type != "inlineStr" || cell.Formula != "" {
+				t.Errorf("cell %s = %+v, want inline string %q", cell.Ref, cell, want[i])
+			}
+			wantStyle := 0
+			switch {
+			case r == 0:
+				wantStyle = xlsxStyleHeader
+			case isFormula(want[i]):
+				wantStyle = xlsxStyleText
+			}
+			if cell.Style != wantStyle {
+				t.Errorf("cell %s has style %d, want %d", cell.Ref, cell.Style, wantStyle)
+			}
+		}
+	}
+	if got := sheet.Rows[2].Cells[0].Ref; got != "A3" {
+		t.Errorf("first cell of the second record is %s, want A3", got)
+	}
+
+	var styles struct {
+		Xfs []struct {
+			QuotePrefix bool `xml:"quotePrefix,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := xml.Unmarshal(readPart(t, data, "xl/styles.xml"), &styles); err != nil {
+		t.Fatal(err)
+	}
+	if len(styles.Xfs) <= xlsxStyleText || !styles.Xfs[xlsxStyleText].QuotePrefix {
+		t.Errorf("text style missing quotePrefix: %+v", styles.Xfs)
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	data := export(t, "xlsx", "Centers [Fresno/Madera] & more")
+	if err := xml.Unmarshal(readPart(t, data, "xl/workbook.xml"), &workbook); err != nil {
+		t.Fatal(err)
+	}
+	if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != "Centers  Fresno Madera  & more" {
+		t.Errorf("sheets %+v", workbook.Sheets)
+	}
+
+	tests := []struct{ title, want string }{
+		{"ABA Centers", "ABA Centers"},
+		{"Q1: Centers?", "Q1  Centers "},
+		{`a*b\c`, "a b c"},
+		{strings.Repeat("x", 40), strings.Repeat("x", 31)},
+		{strings.Repeat("é", 40), strings.Repeat("é", 31)},
+		{"'Quoted'", "Quoted"},
+		{"", "Sheet1"},
+		{"///", "Sheet1"},
+		{"history", "Sheet1"},
+	}
+	for _, tt := range tests {
+		if got := sheetName(tt.title); got != tt.want {
+			t.Errorf("sheetName(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 2: "C", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestKMLPlacemarks(t *testing.T) {
+	lat, lng, zero := 36.7378, -119.7871, 0.0
+	rows := []Row{
+		{Name: "Bright Steps & Co", Values: []string{"Bright Steps & Co", "(559) 555-0100", ""}, Latitude: &lat, Longitude: &lng},
+		{Name: "No location", Values: []string{"No location"}},
+		{Name: "Latitude only", Values: []string{"Latitude only"}, Latitude: &lat},
+		{Name: "Null island", Values: []string{"Null island"}, Latitude: &zero, Longitude: &zero},
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter("kml", &buf, "Centers", testColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Name       string `xml:"Document>name"`
+		Placemarks []struct {
+			Name string `xml:"name"`
+			Data []struct {
+				Name  string `xml:"name,attr"`
+				Value string `xml:"value"`
+			} `xml:"ExtendedData>Data"`
+			Coordinates string `xml:"Point>coordinates"`
+		} `xml:"Document>Placemark"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid KML: %v\n%s", err, buf.String())
+	}
+	if doc.Name != "Centers" || len(doc.Placemarks) != 1 {
+		t.Fatalf("document %q with %d placemarks, want 1", doc.Name, len(doc.Placemarks))
+	}
+	p := doc.Placemarks[0]
+	// KML puts longitude first
+	if p.Name != "Bright Steps & Co" || p.Coordinates != "-119.7871,36.7378" {
+		t.Errorf("placemark %q at %q", p.Name, p.Coordinates)
+	}
+	// Empty values are left out
+	if len(p.Data) != 2 || p.Data[1].Name != "Phone" || p.Data[1].Value != "(559) 555-0100" {
+		t.Errorf("extended data %+v", p.Data)
+	}
+}
+
+func TestNewWriterRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("pdf", io.Discard, "Centers", testColumns); err == nil {
+		t.Error("pdf was accepted")
+	}
+	for name := range Formats {
+		if _, err := NewWriter(name, io.Discard, "Centers", testColumns); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
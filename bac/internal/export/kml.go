@@ -0,0 +1,51 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type kmlWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func newKMLWriter(w io.Writer, title string, columns []string) (*kmlWriter, error) {
+	kw := &kmlWriter{w: bufio.NewWriter(w), columns: columns}
+	fmt.Fprintf(kw.w, `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+<name>%s</name>
+`, xmlEscape(title))
+	return kw, nil
+}
+
+// Write adds a placemark. Records without coordinates are left out because
+// Google Earth and My Maps reject placemarks that have no geometry.
+func (kw *kmlWriter) Write(row Row) error {
+	if row.Latitude == nil || row.Longitude == nil || (*row.Latitude == 0 && *row.Longitude == 0) {
+		return nil
+	}
+
+	kw.w.WriteString("<Placemark>\n<name>" + xmlEscape(row.Name) + "</name>\n<ExtendedData>\n")
+	for i, v := range row.Values {
+		if i >= len(kw.columns) || v == "" {
+			continue
+		}
+		fmt.Fprintf(kw.w, "<Data name=\"%s\"><value>%s</value></Data>\n", xmlEscape(kw.columns[i]), xmlEscape(v))
+	}
+	fmt.Fprintf(kw.w, "</ExtendedData>\n<Point><coordinates>%s,%s</coordinates></Point>\n</Placemark>\n",
+		strconv.FormatFloat(*row.Longitude, 'f', -1, 64), strconv.FormatFloat(*row.Latitude, 'f', -1, 64))
+
+	if kw.w.Buffered() > 32*1024 {
+		return kw.w.Flush()
+	}
+	return nil
+}
+
+func (kw *kmlWriter) Close() error {
+	kw.w.WriteString("</Document>\n</kml>\n")
+	return kw.w.Flush()
+}
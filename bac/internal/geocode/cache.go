@@ -0,0 +1,93 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultCacheTTL = 90 * 24 * time.Hour
+
+// cacheEntry is a row in geocode_cache. Misses are cached too so unresolvable
+// addresses are not looked up again on every run.
+type cacheEntry struct {
+	Provider         string `gorm:"primaryKey"`
+	AddressKey       string `gorm:"primaryKey"`
+	Latitude         float64
+	Longitude        float64
+	FormattedAddress string
+	Confidence       float64
+	NotFound         bool
+	CreatedAt        time.Time
+}
+
+func (cacheEntry) TableName() string {
+	return "geocode_cache"
+}
+
+// Cached stores provider results in the database, keyed by the normalized address
+type Cached struct {
+	next     Geocoder
+	db       *gorm.DB
+	provider string
+	ttl      time.Duration
+}
+
+// NewCached wraps a Geocoder with a persistent cache
+func NewCached(next Geocoder, db *gorm.DB, provider string, ttl time.Duration) *Cached {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &Cached{next: next, db: db, provider: provider, ttl: ttl}
+}
+
+// Geocode implements Geocoder
+func (c *Cached) Geocode(ctx context.Context, address string) (*Result, error) {
+	key := NormalizeAddress(address)
+
+	var entry cacheEntry
+	err := c.db.WithContext(ctx).
+		Where("provider = ? AND address_key = ? AND created_at > ?", c.provider, key, time.Now().Add(-c.ttl)).
+		First(&entry).Error
+	switch {
+	case err == nil:
+		if entry.NotFound {
+			return nil, ErrNotFound
+		}
+		return &Result{
+			Latitude:         entry.Latitude,
+			Longitude:        entry.Longitude,
+			FormattedAddress: entry.FormattedAddress,
+			Confidence:       entry.Confidence,
+			Provider:         c.provider,
+		}, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		// A broken cache should not stop geocoding
+		log.Println("Geocode cache read error:", err)
+	}
+
+	result, err := c.next.Geocode(ctx, address)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	entry = cacheEntry{Provider: c.provider, AddressKey: key, NotFound: result == nil, CreatedAt: time.Now()}
+	if result != nil {
+		entry.Latitude = result.Latitude
+		entry.Longitude = result.Longitude
+		entry.FormattedAddress = result.FormattedAddress
+		entry.Confidence = result.Confidence
+	}
+	if err := c.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error; err != nil {
+		log.Println("Geocode cache write error:", err)
+	}
+
+	if result == nil {
+		return nil, ErrNotFound
+	}
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const censusURL = "https://geocoding.geo.census.gov/geocoder/locations/onelineaddress"
+
+// Census geocodes through the free US Census Bureau geocoder. Matches are
+// interpolated along TIGER address ranges, so they are never rooftop accurate.
+type Census struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewCensus creates a new Census geocoder
+func NewCensus(client *http.Client) *Census {
+	return &Census{baseURL: censusURL, client: client}
+}
+
+type censusResponse struct {
+	Result struct {
+		AddressMatches []struct {
+			MatchedAddress string `json:"matchedAddress"`
+			Coordinates    struct {
+				X float64 `json:"x"`
+				Y float64 `json:"y"`
+			} `json:"coordinates"`
+		} `json:"addressMatches"`
+	} `json:"result"`
+}
+
+// Geocode implements Geocoder
+func (g *Census) Geocode(ctx context.Context, address string) (*Result, error) {
+	params := url.Values{}
+	params.Set("address", address)
+	params.Set("benchmark", "Public_AR_Current")
+	params.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making geocoding request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusBadRequest:
+		return nil, permanent(fmt.Errorf("census geocoder rejected the address (%d)", resp.StatusCode))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("census geocoder returned %d", resp.StatusCode)
+	}
+
+	var result censusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding geocoding response: %w", err)
+	}
+
+	matches := result.Result.AddressMatches
+	if len(matches) == 0 {
+		return nil, ErrNotFound
+	}
+
+	// Several candidates means the input was ambiguous
+	confidence := 0.8
+	if len(matches) > 1 {
+		confidence = 0.5
+	}
+
+	return &Result{
+		Latitude:         matches[0].Coordinates.Y,
+		Longitude:        matches[0].Coordinates.X,
+		FormattedAddress: matches[0].MatchedAddress,
+		Confidence:       confidence,
+		Provider:         "census",
+	}, nil
+}
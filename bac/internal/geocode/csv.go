@@ -0,0 +1,115 @@
+package geocode
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LocalCSV looks addresses up in an address point file for offline use, such as
+// a county address point export. The file needs a header row with either an
+// address column or number/street/city/state/zip columns, plus lat and lng columns.
+type LocalCSV struct {
+	points map[string]Result
+}
+
+// NewLocalCSV loads an address point file into memory
+func NewLocalCSV(path string) (*LocalCSV, error) {
+	if path == "" {
+		return nil, errors.New("GEOCODER_CSV_PATH is required for the csv geocoder")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return loadCSV(f)
+}
+
+func loadCSV(r io.Reader) (*LocalCSV, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading address point header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	find := func(names ...string) int {
+		for _, name := range names {
+			if i, ok := columns[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	latCol := find("lat", "latitude", "y")
+	lngCol := find("lng", "lon", "longitude", "x")
+	addressCol := find("address", "full_address")
+	partCols := []int{find("number", "house_number"), find("street"), find("city"), find("state"), find("zip", "zip_code")}
+	if latCol < 0 || lngCol < 0 {
+		return nil, errors.New("address point file needs lat and lng columns")
+	}
+	if addressCol < 0 && (partCols[1] < 0 || partCols[2] < 0) {
+		return nil, errors.New("address point file needs an address column or street and city columns")
+	}
+
+	field := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	points := map[string]Result{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		lat, latErr := strconv.ParseFloat(field(record, latCol), 64)
+		lng, lngErr := strconv.ParseFloat(field(record, lngCol), 64)
+		if latErr != nil || lngErr != nil {
+			continue
+		}
+
+		address := field(record, addressCol)
+		if addressCol < 0 {
+			address = fmt.Sprintf("%s %s, %s, %s %s",
+				field(record, partCols[0]), field(record, partCols[1]), field(record, partCols[2]),
+				field(record, partCols[3]), field(record, partCols[4]))
+		}
+
+		points[NormalizeAddress(address)] = Result{
+			Latitude:         lat,
+			Longitude:        lng,
+			FormattedAddress: address,
+			Confidence:       1,
+			Provider:         "csv",
+		}
+	}
+
+	return &LocalCSV{points: points}, nil
+}
+
+// Geocode implements Geocoder
+func (l *LocalCSV) Geocode(ctx context.Context, address string) (*Result, error) {
+	result, ok := l.points[NormalizeAddress(address)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &result, nil
+}
@@ -0,0 +1,147 @@
+// Package geocode turns postal addresses into coordinates. Providers are wrapped
+// with a persistent cache, retries and a rate limiter by New.
+package geocode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when the provider has no match for the address
+var ErrNotFound = errors.New("address not found")
+
+// Result is a single geocoded location
+type Result struct {
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	FormattedAddress string  `json:"formatted_address"`
+	// Confidence runs from 0 to 1; 1 is a rooftop or exact address point match
+	Confidence float64 `json:"confidence"`
+	Provider   string  `json:"provider"`
+}
+
+// Geocoder resolves an address to a location
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (*Result, error)
+}
+
+// Config selects and configures a geocoding provider
+type Config struct {
+	// Provider is "google", "nominatim", "census", "csv" or "none"
+	Provider string
+
+	GoogleAPIKey string
+	// NominatimURL defaults to the public OpenStreetMap instance
+	NominatimURL string
+	// UserAgent identifies this application, which Nominatim's usage policy requires
+	UserAgent string
+	// CSVPath is the address point file used by the csv provider
+	CSVPath string
+
+	// RequestsPerSecond caps calls to the provider; 0 uses the provider's default
+	RequestsPerSecond float64
+	MaxRetries        int
+	// CacheDB stores results in geocode_cache when set
+	CacheDB  *gorm.DB
+	CacheTTL time.Duration
+}
+
+// New creates the configured Geocoder. It returns nil for the "none" provider,
+// in which case callers skip geocoding.
+func New(cfg Config) (Geocoder, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var (
+		provider Geocoder
+		rps      float64
+	)
+	switch cfg.Provider {
+	case "", "none":
+		return nil, nil
+	case "google":
+		if cfg.GoogleAPIKey == "" {
+			return nil, errors.New("GOOGLE_MAPS_API_KEY is required for the google geocoder")
+		}
+		provider, rps = NewGoogle(cfg.GoogleAPIKey, client), 10
+	case "nominatim":
+		provider, rps = NewNominatim(cfg.NominatimURL, cfg.UserAgent, client), 1
+	case "census":
+		provider, rps = NewCensus(client), 5
+	case "csv":
+		local, err := NewLocalCSV(cfg.CSVPath)
+		if err != nil {
+			return nil, err
+		}
+		// Local lookups need neither a rate limit nor retries
+		return withCache(local, cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q", cfg.Provider)
+	}
+
+	if cfg.RequestsPerSecond > 0 {
+		rps = cfg.RequestsPerSecond
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = 3
+	}
+
+	var g Geocoder = NewRateLimited(provider, rps)
+	g = NewRetrying(g, maxRetries, 500*time.Millisecond)
+	return withCache(g, cfg), nil
+}
+
+func withCache(g Geocoder, cfg Config) Geocoder {
+	if cfg.CacheDB == nil {
+		return g
+	}
+	return NewCached(g, cfg.CacheDB, cfg.Provider, cfg.CacheTTL)
+}
+
+// permanentError marks a failure that retrying will not fix, such as a bad API key
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+var (
+	spaces      = regexp.MustCompile(`\s+`)
+	punctuation = regexp.MustCompile(`[.,#]+`)
+)
+
+// NormalizeAddress reduces an address to a comparable key: upper case, no
+// punctuation, single spaces and common USPS street suffix abbreviations
+func NormalizeAddress(address string) string {
+	a := strings.ToUpper(address)
+	a = punctuation.ReplaceAllString(a, " ")
+	a = strings.TrimSpace(spaces.ReplaceAllString(a, " "))
+
+	words := strings.Split(a, " ")
+	for i, w := range words {
+		if abbr, ok := suffixes[w]; ok {
+			words[i] = abbr
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+var suffixes = map[string]string{
+	"STREET": "ST", "AVENUE": "AVE", "BOULEVARD": "BLVD", "ROAD": "RD",
+	"DRIVE": "DR", "LANE": "LN", "COURT": "CT", "PLACE": "PL",
+	"PARKWAY": "PKWY", "HIGHWAY": "HWY", "SUITE": "STE", "CIRCLE": "CIR",
+	"NORTH": "N", "SOUTH": "S", "EAST": "E", "WEST": "W",
+	"CALIFORNIA": "CA",
+}
@@ -0,0 +1,165 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubGeocoder returns errs in order, then a result, and counts its calls
+type stubGeocoder struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (s *stubGeocoder) Geocode(ctx context.Context, address string) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	return &Result{Latitude: 36.7378, Longitude: -119.7871, Provider: "stub"}, nil
+}
+
+func TestRetrying(t *testing.T) {
+	transient := errors.New("503 from provider")
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", nil, 1, nil},
+		{"transient then success", []error{transient, transient}, 3, nil},
+		{"retries exhausted", []error{transient, transient, transient, transient}, 3, transient},
+		{"not found is final", []error{ErrNotFound}, 1, ErrNotFound},
+		{"permanent is final", []error{permanent(transient)}, 1, transient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubGeocoder{errs: tt.errs}
+			result, err := NewRetrying(stub, 2, time.Millisecond).Geocode(context.Background(), "1 Main St")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (result == nil || result.Provider != "stub") {
+				t.Errorf("got result %+v", result)
+			}
+			if stub.calls != tt.wantCalls {
+				t.Errorf("provider called %d times, want %d", stub.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryingStopsWhenCancelled(t *testing.T) {
+	stub := &stubGeocoder{errs: []error{errors.New("timeout"), errors.New("timeout")}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewRetrying(stub, 5, time.Hour).Geocode(ctx, "1 Main St")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context's error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("backoff ignored cancellation for %v", elapsed)
+	}
+	if stub.calls != 1 {
+		t.Errorf("provider called %d times, want 1", stub.calls)
+	}
+}
+
+func TestRateLimitedSpacesCalls(t *testing.T) {
+	stub := &stubGeocoder{}
+	limited := NewRateLimited(stub, 100)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := limited.Geocode(context.Background(), "1 Main St"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first call goes straight through and the rest are 10ms apart
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 calls at 100 a second took %v, want at least 40ms", elapsed)
+	}
+	if stub.calls != 5 {
+		t.Errorf("provider called %d times, want 5", stub.calls)
+	}
+}
+
+func TestRateLimitedStopsWhenCancelled(t *testing.T) {
+	stub := &stubGeocoder{}
+	limited := NewRateLimited(stub, 0.1)
+	if _, err := limited.Geocode(context.Background(), "1 Main St"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next slot is ten seconds away
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limited.Geocode(ctx, "2 Main St"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context's error", err)
+	}
+	if stub.calls != 1 {
+		t.Errorf("provider called %d times, want 1", stub.calls)
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := map[string]string{
+		"1 Main Street, Fresno, California 93701": "1 MAIN ST FRESNO CA 93701",
+		"1 main st.,  fresno ,CA 93701":           "1 MAIN ST FRESNO CA 93701",
+		"200 North Blackstone Avenue Suite #4":    "200 N BLACKSTONE AVE STE 4",
+		"  5 Shaw\tBoulevard\n":                   "5 SHAW BLVD",
+		"Eastside Court":                          "EASTSIDE CT",
+		"":                                        "",
+	}
+	for address, want := range tests {
+		if got := NormalizeAddress(address); got != want {
+			t.Errorf("NormalizeAddress(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
+
+func TestLocalCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"address column", "Address,Lat,Lng\n\"1 Main Street, Fresno, CA 93701\",36.7378,-119.7871\nBad Row,north,west\n"},
+		{"address parts", "number,street,city,state,zip,latitude,longitude\n1,Main St,Fresno,CA,93701,36.7378,-119.7871\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, err := loadCSV(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := local.Geocode(context.Background(), "1 main st., Fresno, California 93701")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Latitude != 36.7378 || result.Longitude != -119.7871 || result.Provider != "csv" {
+				t.Errorf("got %+v", result)
+			}
+			if _, err := local.Geocode(context.Background(), "2 Main St, Fresno, CA 93701"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("unknown address: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+
+	for _, data := range []string{"address,city\n", "lat,lng,notes\n"} {
+		if _, err := loadCSV(strings.NewReader(data)); err == nil {
+			t.Errorf("header %q was accepted", strings.TrimSpace(data))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const googleGeocodeURL = "https://maps.googleapis.com/maps/api/geocode/json"
+
+// Google geocodes through the Google Maps Geocoding API
+type Google struct {
+	apiKey  string
+	client  *http.Client
+	baseURL string
+}
+
+// NewGoogle creates a new Google geocoder
+func NewGoogle(apiKey string, client *http.Client) *Google {
+	return &Google{apiKey: apiKey, client: client, baseURL: googleGeocodeURL}
+}
+
+type googleResponse struct {
+	Results []struct {
+		FormattedAddress string `json:"formatted_address"`
+		PartialMatch     bool   `json:"partial_match"`
+		Geometry         struct {
+			LocationType string `json:"location_type"`
+			Location     struct {
+				Lat float64 `json:"lat"`
+				Lng float64 `json:"lng"`
+			} `json:"location"`
+		} `json:"geometry"`
+	} `json:"results"`
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// Geocode implements Geocoder
+func (g *Google) Geocode(ctx context.Context, address string) (*Result, error) {
+	params := url.Values{}
+	params.Set("address", address)
+	params.Set("components", "country:US")
+	params.Set("key", g.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making geocoding request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result googleResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding geocoding response: %w", err)
+	}
+
+	switch result.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return nil, ErrNotFound
+	case "REQUEST_DENIED", "INVALID_REQUEST":
+		return nil, permanent(fmt.Errorf("geocoding API returned status %s: %s", result.Status, result.ErrorMessage))
+	default:
+		// OVER_QUERY_LIMIT and UNKNOWN_ERROR are worth retrying
+		return nil, fmt.Errorf("geocoding API returned status %s: %s", result.Status, result.ErrorMessage)
+	}
+	if len(result.Results) == 0 {
+		return nil, ErrNotFound
+	}
+
+	top := result.Results[0]
+	confidence := googleConfidence[top.Geometry.LocationType]
+	if top.PartialMatch {
+		confidence *= 0.8
+	}
+	return &Result{
+		Latitude:         top.Geometry.Location.Lat,
+		Longitude:        top.Geometry.Location.Lng,
+		FormattedAddress: top.FormattedAddress,
+		Confidence:       confidence,
+		Provider:         "google",
+	}, nil
+}
+
+// googleConfidence scores Google's location_type precision levels
+var googleConfidence = map[string]float64{
+	"ROOFTOP":            1,
+	"RANGE_INTERPOLATED": 0.8,
+	"GEOMETRIC_CENTER":   0.6,
+	"APPROXIMATE":        0.4,
+}
@@ -0,0 +1,101 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const nominatimURL = "https://nominatim.openstreetmap.org"
+
+// Nominatim geocodes through an OpenStreetMap Nominatim server. The public
+// instance allows one request per second and requires an identifying User-Agent.
+type Nominatim struct {
+	baseURL   string
+	userAgent string
+	client    *http.Client
+}
+
+// NewNominatim creates a new Nominatim geocoder
+func NewNominatim(baseURL, userAgent string, client *http.Client) *Nominatim {
+	if baseURL == "" {
+		baseURL = nominatimURL
+	}
+	if userAgent == "" {
+		userAgent = "bac-directory-geocoder"
+	}
+	return &Nominatim{baseURL: strings.TrimRight(baseURL, "/"), userAgent: userAgent, client: client}
+}
+
+type nominatimPlace struct {
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	DisplayName string `json:"display_name"`
+	PlaceRank   int    `json:"place_rank"`
+}
+
+// Geocode implements Geocoder
+func (n *Nominatim) Geocode(ctx context.Context, address string) (*Result, error) {
+	params := url.Values{}
+	params.Set("q", address)
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+	params.Set("countrycodes", "us")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", n.userAgent)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making geocoding request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, permanent(fmt.Errorf("nominatim refused the request (%d)", resp.StatusCode))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("nominatim returned %d", resp.StatusCode)
+	}
+
+	var places []nominatimPlace
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return nil, fmt.Errorf("error decoding geocoding response: %w", err)
+	}
+	if len(places) == 0 {
+		return nil, ErrNotFound
+	}
+
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude %q: %w", places[0].Lat, err)
+	}
+	lng, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude %q: %w", places[0].Lon, err)
+	}
+
+	// place_rank 30 is a building or address point, 26-27 a street
+	confidence := 0.4
+	switch {
+	case places[0].PlaceRank >= 30:
+		confidence = 1
+	case places[0].PlaceRank >= 26:
+		confidence = 0.7
+	}
+
+	return &Result{
+		Latitude:         lat,
+		Longitude:        lng,
+		FormattedAddress: places[0].DisplayName,
+		Confidence:       confidence,
+		Provider:         "nominatim",
+	}, nil
+}
@@ -0,0 +1,54 @@
+package geocode
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimited spaces out calls to a provider so a bulk run stays inside its usage policy
+type RateLimited struct {
+	next     Geocoder
+	interval time.Duration
+
+	mu     sync.Mutex
+	nextAt time.Time
+}
+
+// NewRateLimited wraps a Geocoder so it is called at most perSecond times a second
+func NewRateLimited(next Geocoder, perSecond float64) *RateLimited {
+	return &RateLimited{next: next, interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Geocode implements Geocoder
+func (r *RateLimited) Geocode(ctx context.Context, address string) (*Result, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.next.Geocode(ctx, address)
+}
+
+// wait reserves the next free slot and sleeps until it arrives
+func (r *RateLimited) wait(ctx context.Context) error {
+	r.mu.Lock()
+	now := time.Now()
+	slot := r.nextAt
+	if slot.Before(now) {
+		slot = now
+	}
+	r.nextAt = slot.Add(r.interval)
+	r.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
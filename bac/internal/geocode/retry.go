@@ -0,0 +1,40 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Retrying retries transient provider failures with exponential backoff and jitter
+type Retrying struct {
+	next       Geocoder
+	maxRetries int
+	baseDelay  time.Duration
+}
+
+// NewRetrying wraps a Geocoder so transient errors are retried up to maxRetries times
+func NewRetrying(next Geocoder, maxRetries int, baseDelay time.Duration) *Retrying {
+	return &Retrying{next: next, maxRetries: maxRetries, baseDelay: baseDelay}
+}
+
+// Geocode implements Geocoder
+func (r *Retrying) Geocode(ctx context.Context, address string) (*Result, error) {
+	var perm *permanentError
+	for attempt := 0; ; attempt++ {
+		result, err := r.next.Geocode(ctx, address)
+		if err == nil || errors.Is(err, ErrNotFound) || errors.As(err, &perm) || attempt >= r.maxRetries {
+			return result, err
+		}
+
+		// base, 2x base, 4x base ... plus up to 50% jitter
+		delay := r.baseDelay << attempt
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
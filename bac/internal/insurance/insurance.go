@@ -0,0 +1,338 @@
+// Package insurance maps ABA centers' free-text insurance and Medi-Cal plan
+// lists onto the insurance_carriers and medi_cal_plans reference tables
+package insurance
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"bac/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Review kinds, matching insurance_mapping_reviews.kind
+const (
+	KindInsurance = "insurance"
+	KindMediCal   = "medi_cal"
+)
+
+// Review statuses
+const (
+	StatusPending = "pending"
+	StatusMapped  = "mapped"
+	StatusIgnored = "ignored"
+)
+
+var (
+	ErrUnknownCarrier  = errors.New("unknown insurance carrier")
+	ErrUnknownPlan     = errors.New("unknown Medi-Cal plan")
+	ErrReviewNotFound  = errors.New("review item not found")
+	ErrReviewResolved  = errors.New("review item already resolved")
+	ErrInvalidKind     = errors.New("kind must be insurance or medi_cal")
+	ErrInvalidResolved = errors.New("a carrier or plan ID is required to map a review item")
+)
+
+var (
+	nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+	separators      = regexp.MustCompile(`(?i)[,;/|\n]+|\s+and\s+|\s+&\s+`)
+	// notApplicable is "N/A", which would otherwise split at the slash
+	notApplicable = regexp.MustCompile(`(?i)\bn/a\b`)
+)
+
+// spaces are trimmed from each value, as btrim does in insurance_name_tokens
+const spaces = " \t\n\v\f\r"
+
+// ignoredKeys are values that name no carrier or plan, such as "N/A".
+// Keep in step with insurance_name_tokens, last defined in migration 0023.
+var ignoredKeys = map[string]bool{
+	"": true, "none": true, "n a": true, "na": true, "no": true, "unknown": true, "tbd": true,
+	"yes": true, "all": true, "medi cal": true, "medical": true, "private pay": true, "regional center": true,
+}
+
+// Key reduces a name to lower case words, like the insurance_name_key SQL function
+func Key(name string) string {
+	return strings.TrimSpace(nonAlphanumeric.ReplaceAllString(strings.ToLower(name), " "))
+}
+
+// Token is one value from a free-text list
+type Token struct {
+	Raw string
+	Key string
+}
+
+// Split breaks a free-text list into its values, dropping placeholders like "N/A"
+func Split(list string) []Token {
+	tokens := []Token{}
+	for _, raw := range separators.Split(notApplicable.ReplaceAllString(list, "n a"), -1) {
+		raw = strings.Trim(raw, spaces)
+		key := Key(raw)
+		if ignoredKeys[key] {
+			continue
+		}
+		tokens = append(tokens, Token{Raw: raw, Key: key})
+	}
+	return tokens
+}
+
+// Service links ABA centers to carriers and plans and manages the review queue
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a new Service instance
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// reference describes one of the two reference tables and its join table
+type reference struct {
+	kind      string
+	table     string
+	joinTable string
+	joinKey   string
+	unknown   error
+}
+
+var references = map[string]reference{
+	KindInsurance: {KindInsurance, "insurance_carriers", "aba_center_insurance_carriers", "insurance_carrier_id", ErrUnknownCarrier},
+	KindMediCal:   {KindMediCal, "medi_cal_plans", "aba_center_medi_cal_plans", "medi_cal_plan_id", ErrUnknownPlan},
+}
+
+// Carriers lists every insurance carrier by name
+func (s *Service) Carriers() ([]models.InsuranceCarrier, error) {
+	carriers := []models.InsuranceCarrier{}
+	err := s.db.Order("name").Find(&carriers).Error
+	return carriers, err
+}
+
+// Plans lists every Medi-Cal plan by name
+func (s *Service) Plans() ([]models.MediCalPlan, error) {
+	plans := []models.MediCalPlan{}
+	err := s.db.Order("name").Find(&plans).Error
+	return plans, err
+}
+
+// Sync updates a center's links after a write. Explicit IDs replace the links;
+// otherwise, when the text changed, the text is parsed again and anything that
+// matches nothing is queued for review.
+func (s *Service) Sync(tx *gorm.DB, centerID uuid.UUID, req models.ABACenterRequest, textChanged bool) error {
+	if req.InsuranceCarrierIDs != nil {
+		if err := s.setLinks(tx, references[KindInsurance], centerID, *req.InsuranceCarrierIDs); err != nil {
+			return err
+		}
+	} else if textChanged {
+		if err := s.parseLinks(tx, references[KindInsurance], centerID, req.InsuranceAccepted); err != nil {
+			return err
+		}
+	}
+
+	if req.MediCalPlanIDs != nil {
+		return s.setLinks(tx, references[KindMediCal], centerID, *req.MediCalPlanIDs)
+	} else if textChanged {
+		return s.parseLinks(tx, references[KindMediCal], centerID, req.MediCalPlans)
+	}
+	return nil
+}
+
+// Links returns the carrier and plan IDs a center is linked to, in ID order
+func Links(tx *gorm.DB, centerID uuid.UUID) ([]int, []int, error) {
+	carriers, err := linkIDs(tx, references[KindInsurance], centerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	plans, err := linkIDs(tx, references[KindMediCal], centerID)
+	return carriers, plans, err
+}
+
+func linkIDs(tx *gorm.DB, ref reference, centerID uuid.UUID) ([]int, error) {
+	ids := []int{}
+	err := tx.Table(ref.joinTable).Where("aba_center_id = ?", centerID).Order(ref.joinKey).Pluck(ref.joinKey, &ids).Error
+	return ids, err
+}
+
+// setLinks replaces a center's links with the given IDs
+func (s *Service) setLinks(tx *gorm.DB, ref reference, centerID uuid.UUID, ids []int) error {
+	ids = unique(ids)
+	if len(ids) > 0 {
+		var found int64
+		if err := tx.Table(ref.table).Where("id IN ?", ids).Count(&found).Error; err != nil {
+			return err
+		}
+		if int(found) != len(ids) {
+			return ref.unknown
+		}
+	}
+
+	if err := tx.Exec("DELETE FROM "+ref.joinTable+" WHERE aba_center_id = ?", centerID).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := tx.Exec("INSERT INTO "+ref.joinTable+" (aba_center_id, "+ref.joinKey+") VALUES (?, ?)", centerID, id).Error; err != nil {
+			return err
+		}
+	}
+	// Earlier pending items are stale now; parseLinks queues the current ones
+	return tx.Exec("DELETE FROM insurance_mapping_reviews WHERE aba_center_id = ? AND kind = ? AND status = ?",
+		centerID, ref.kind, StatusPending).Error
+}
+
+// parseLinks maps the free text onto the reference table and queues the rest
+func (s *Service) parseLinks(tx *gorm.DB, ref reference, centerID uuid.UUID, list string) error {
+	var entries []struct {
+		ID      int
+		Name    string
+		Aliases pq.StringArray
+	}
+	if err := tx.Table(ref.table).Select("id, name, aliases").Scan(&entries).Error; err != nil {
+		return err
+	}
+	byKey := map[string]int{}
+	for _, e := range entries {
+		byKey[Key(e.Name)] = e.ID
+		for _, alias := range e.Aliases {
+			byKey[alias] = e.ID
+		}
+	}
+
+	ids := []int{}
+	unmatched := []string{}
+	for _, token := range Split(list) {
+		if id, ok := byKey[token.Key]; ok {
+			ids = append(ids, id)
+		} else {
+			unmatched = append(unmatched, token.Raw)
+		}
+	}
+
+	if err := s.setLinks(tx, ref, centerID, ids); err != nil {
+		return err
+	}
+	for _, raw := range unmatched {
+		err := tx.Exec(`INSERT INTO insurance_mapping_reviews (aba_center_id, kind, raw_value)
+			VALUES (?, ?, ?) ON CONFLICT (aba_center_id, kind, raw_value) DO NOTHING`,
+			centerID, ref.kind, raw).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reviews lists review items with the given status, oldest first
+func (s *Service) Reviews(status string) ([]models.InsuranceMappingReview, error) {
+	reviews := []models.InsuranceMappingReview{}
+	query := s.db.Table("insurance_mapping_reviews AS r").
+		Select("r.*, a.name AS center_name").
+		Joins("JOIN aba_centers a ON a.id = r.aba_center_id AND a.deleted_at IS NULL").
+		Order("r.created_at, r.id")
+	if status != "" {
+		query = query.Where("r.status = ?", status)
+	}
+	err := query.Scan(&reviews).Error
+	return reviews, err
+}
+
+// Resolution maps a review item onto a carrier or plan, or ignores it
+type Resolution struct {
+	// TargetID is the carrier or plan the raw value means; required unless Ignore is set
+	TargetID *int `json:"targetId"`
+	// AddAlias teaches the target the raw spelling so future text maps itself
+	AddAlias bool `json:"addAlias"`
+	Ignore   bool `json:"ignore"`
+}
+
+// Resolve closes a pending review item
+func (s *Service) Resolve(id int, res Resolution, actorID *int) (*models.InsuranceMappingReview, error) {
+	var review models.InsuranceMappingReview
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&review, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrReviewNotFound
+			}
+			return err
+		}
+		if review.Status != StatusPending {
+			return ErrReviewResolved
+		}
+		ref, ok := references[review.Kind]
+		if !ok {
+			return ErrInvalidKind
+		}
+
+		now := time.Now()
+		review.ResolvedBy, review.ResolvedAt = actorID, &now
+		if res.Ignore {
+			review.Status = StatusIgnored
+			return tx.Save(&review).Error
+		}
+		if res.TargetID == nil {
+			return ErrInvalidResolved
+		}
+
+		var exists int64
+		if err := tx.Table(ref.table).Where("id = ?", *res.TargetID).Count(&exists).Error; err != nil {
+			return err
+		}
+		if exists == 0 {
+			return ref.unknown
+		}
+
+		err := tx.Exec("INSERT INTO "+ref.joinTable+" (aba_center_id, "+ref.joinKey+") VALUES (?, ?) ON CONFLICT DO NOTHING",
+			review.ABACenterID, *res.TargetID).Error
+		if err != nil {
+			return err
+		}
+		if res.AddAlias {
+			err := tx.Exec("UPDATE "+ref.table+" SET aliases = array_append(aliases, ?), updated_at = NOW() WHERE id = ? AND NOT (? = ANY (aliases))",
+				Key(review.RawValue), *res.TargetID, Key(review.RawValue)).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		review.Status, review.ResolvedID = StatusMapped, res.TargetID
+		return tx.Save(&review).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// FilterCenters restricts an aba_centers query to centers linked to the given
+// carriers or plans: any of them, or all of them when matchAll is set
+func FilterCenters(query *gorm.DB, kind string, ids []int, matchAll bool) (*gorm.DB, error) {
+	ref, ok := references[kind]
+	if !ok {
+		return nil, ErrInvalidKind
+	}
+	ids = unique(ids)
+	if len(ids) == 0 {
+		return query, nil
+	}
+
+	sub := fmt.Sprintf("SELECT aba_center_id FROM %s WHERE %s IN ?", ref.joinTable, ref.joinKey)
+	if matchAll {
+		sub += fmt.Sprintf(" GROUP BY aba_center_id HAVING COUNT(DISTINCT %s) = %d", ref.joinKey, len(ids))
+	}
+	return query.Where("aba_centers.id IN ("+sub+")", ids), nil
+}
+
+func unique(ids []int) []int {
+	seen := map[int]bool{}
+	out := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
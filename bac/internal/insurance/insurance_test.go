@@ -0,0 +1,96 @@
+package insurance
+
+import (
+	"reflect"
+	"testing"
+
+	"bac/internal/testutil"
+)
+
+func TestKey(t *testing.T) {
+	tests := map[string]string{
+		"Blue Shield":       "blue shield",
+		"  Blue-Shield  ":   "blue shield",
+		"BLUE_SHIELD":       "blue shield",
+		"Kaiser (HMO)":      "kaiser hmo",
+		"Medi-Cal":          "medi cal",
+		"Tricare/East":      "tricare east",
+		"United Healthcare": "united healthcare",
+		"Anthem\tBC":        "anthem bc",
+		"Médi-Cal":          "m di cal",
+		"N/A":               "n a",
+		"":                  "",
+		"---":               "",
+	}
+	for name, want := range tests {
+		if got := Key(name); got != want {
+			t.Errorf("Key(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// splitCases cover each separator, the ignored values and untrimmed spacing
+var splitCases = []struct {
+	name string
+	list string
+	want []Token
+}{
+	{"empty", "", []Token{}},
+	{"one value", "Aetna", []Token{{"Aetna", "aetna"}}},
+	{"commas and semicolons", "Aetna, Cigna; Blue Shield", []Token{{"Aetna", "aetna"}, {"Cigna", "cigna"}, {"Blue Shield", "blue shield"}}},
+	{"slash and pipe", "Aetna/Cigna | Kaiser", []Token{{"Aetna", "aetna"}, {"Cigna", "cigna"}, {"Kaiser", "kaiser"}}},
+	{"and and ampersand", "Aetna and Cigna & Kaiser AND Magellan", []Token{{"Aetna", "aetna"}, {"Cigna", "cigna"}, {"Kaiser", "kaiser"}, {"Magellan", "magellan"}}},
+	{"and inside a name", "Anderson Health, Brand Care", []Token{{"Anderson Health", "anderson health"}, {"Brand Care", "brand care"}}},
+	{"lines with carriage returns", "Aetna\r\nCigna\r\n", []Token{{"Aetna", "aetna"}, {"Cigna", "cigna"}}},
+	{"tabs", "\tAetna\t,Cigna", []Token{{"Aetna", "aetna"}, {"Cigna", "cigna"}}},
+	{"repeated separators", "Aetna,, ;Cigna", []Token{{"Aetna", "aetna"}, {"Cigna", "cigna"}}},
+	{"placeholders", "N/A", []Token{}},
+	{"placeholder among names", "Aetna, n/a / Cigna", []Token{{"Aetna", "aetna"}, {"Cigna", "cigna"}}},
+	{"ignored values", "None, Unknown, TBD, yes, all, Medi-Cal, Private Pay, Regional Center", []Token{}},
+	{"ignored among names", "Aetna, private pay, Cigna", []Token{{"Aetna", "aetna"}, {"Cigna", "cigna"}}},
+	{"punctuation kept in raw", "Blue-Shield (PPO)", []Token{{"Blue-Shield (PPO)", "blue shield ppo"}}},
+}
+
+func TestSplit(t *testing.T) {
+	for _, tt := range splitCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %q, want %q", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+// The migrations map existing centers with insurance_name_tokens, so it must
+// split and key lists exactly as Split does
+func TestSQLTokensMatchSplit(t *testing.T) {
+	tx := testutil.Tx(t)
+	for _, tt := range splitCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows []struct {
+				RawValue string
+				Key      string
+			}
+			if err := tx.Raw("SELECT raw_value, key FROM insurance_name_tokens(?)", tt.list).Scan(&rows).Error; err != nil {
+				t.Fatal(err)
+			}
+			got := []Token{}
+			for _, r := range rows {
+				got = append(got, Token{Raw: r.RawValue, Key: r.Key})
+			}
+			if want := Split(tt.list); !reflect.DeepEqual(got, want) {
+				t.Errorf("insurance_name_tokens(%q) = %q, Split gives %q", tt.list, got, want)
+			}
+
+			for _, token := range got {
+				var key string
+				if err := tx.Raw("SELECT insurance_name_key(?)", token.Raw).Scan(&key).Error; err != nil {
+					t.Fatal(err)
+				}
+				if key != Key(token.Raw) {
+					t.Errorf("insurance_name_key(%q) = %q, Key gives %q", token.Raw, key, Key(token.Raw))
+				}
+			}
+		})
+	}
+}
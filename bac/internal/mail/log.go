@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// LogMailer logs who each message is for and, when a directory is configured,
+// writes it to one .eml file per message. Meant for local development; the
+// log leaves out bodies because they carry live verification and reset tokens.
+type LogMailer struct {
+	from string
+	dir  string
+}
+
+// NewLogMailer creates a new LogMailer instance
+func NewLogMailer(from, dir string) *LogMailer {
+	return &LogMailer{from: from, dir: dir}
+}
+
+// unsafeFileChars are replaced in the recipient part of .eml file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9@._+-]`)
+
+// Send logs msg and saves it to disk if a directory is configured
+func (m *LogMailer) Send(msg Message) error {
+	log.Printf("Mail to %s: %s", msg.To, msg.Subject)
+
+	if m.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+	recipient := unsafeFileChars.ReplaceAllString(msg.To, "_")
+	if len(recipient) > 64 {
+		recipient = recipient[:64]
+	}
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102T150405.000000000"), recipient)
+	return os.WriteFile(filepath.Join(m.dir, name), render(m.from, msg), 0644)
+}
@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerKeepsTokensOutOfTheLog(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	dir := t.TempDir()
+	msg := Message{To: "user@example.com", Subject: "Reset your password", Body: "https://example.org/reset?token=secret-token"}
+	if err := NewLogMailer("no-reply@example.com", dir).Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logged.String(), "secret-token") {
+		t.Errorf("the log holds the message body: %s", logged.String())
+	}
+	if !strings.Contains(logged.String(), "user@example.com") || !strings.Contains(logged.String(), "Reset your password") {
+		t.Errorf("the log is missing the recipient or subject: %s", logged.String())
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 || !strings.HasSuffix(files[0], "_user@example.com.eml") {
+		t.Fatalf("wrote %v", files)
+	}
+	if body, _ := os.ReadFile(files[0]); !bytes.Contains(body, []byte("secret-token")) {
+		t.Error("the .eml file is missing the body")
+	}
+}
+
+func TestLogMailerFileNamesStayInTheDirectory(t *testing.T) {
+	log.SetOutput(&bytes.Buffer{})
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "mail")
+	for _, to := range []string{"../../escape@example.com", `a/b\c@example.com`, strings.Repeat("x", 300) + "@example.com"} {
+		if err := NewLogMailer("no-reply@example.com", dir).Send(Message{To: to, Subject: "Hi"}); err != nil {
+			t.Fatalf("%s: %v", to, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("wrote %d files in the mail directory, want 3", len(entries))
+	}
+	for _, e := range entries {
+		if e.IsDir() || strings.ContainsAny(e.Name(), `/\`) || len(e.Name()) > 100 {
+			t.Errorf("unsafe file name %q", e.Name())
+		}
+	}
+	if outside, _ := filepath.Glob(filepath.Join(parent, "*.eml")); len(outside) > 0 {
+		t.Errorf("wrote outside the mail directory: %v", outside)
+	}
+}
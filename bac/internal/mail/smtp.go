@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+)
+
+// SMTPMailer sends mail through an SMTP server. Leaving the username empty
+// skips authentication, which suits local catchers such as MailHog or Mailpit.
+type SMTPMailer struct {
+	addr     string
+	host     string
+	from     string
+	username string
+	password string
+}
+
+// NewSMTPMailer creates a new SMTPMailer instance
+func NewSMTPMailer(cfg Config) *SMTPMailer {
+	port := cfg.Port
+	if port == "" {
+		port = "587"
+	}
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(cfg.Host, port),
+		host:     cfg.Host,
+		from:     cfg.From,
+		username: cfg.Username,
+		password: cfg.Password,
+	}
+}
+
+// Send delivers msg through the configured SMTP server
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return smtp.SendMail(m.addr, auth, m.from, []string{msg.To}, render(m.from, msg))
+}
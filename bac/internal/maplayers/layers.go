@@ -0,0 +1,255 @@
+// Package maplayers describes the directory tables the map can draw and builds
+// the spatial queries that serve them
+package maplayers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnknownLayer  = errors.New("unknown layer")
+	ErrUnknownField  = errors.New("unknown field")
+	ErrUnknownFilter = errors.New("filter not supported")
+)
+
+// Attribute is a property exposed on a layer's features
+type Attribute struct {
+	Name string
+	// SQL is the column expression; MVT properties must be scalars, so arrays are joined
+	SQL string
+}
+
+// Filter turns a query parameter value into a WHERE clause with its arguments
+type Filter func(value string) (string, []interface{})
+
+// Layer is one directory table drawn on the map
+type Layer struct {
+	Name  string
+	Table string
+	// Geometry is a WGS84 point or polygon expression; it must match an index
+	// expression on Table so envelope filters stay indexed
+	Geometry   string
+	Where      string
+	Attributes []Attribute
+	Filters    map[string]Filter
+}
+
+func ilike(column string) Filter {
+	return func(value string) (string, []interface{}) {
+		return column + " ILIKE ?", []interface{}{"%" + value + "%"}
+	}
+}
+
+func anyILike(columns ...string) Filter {
+	return func(value string) (string, []interface{}) {
+		clauses := make([]string, len(columns))
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			clauses[i] = column + " ILIKE ?"
+			args[i] = "%" + value + "%"
+		}
+		return "(" + strings.Join(clauses, " OR ") + ")", args
+	}
+}
+
+// Layers lists every layer by name
+var Layers = map[string]*Layer{
+	"regional_centers": {
+		Name:     "regional_centers",
+		Table:    "regional_centers",
+		Geometry: "regional_centers.location",
+		Where:    "regional_centers.location IS NOT NULL AND regional_centers.deleted_at IS NULL",
+		Attributes: []Attribute{
+			{"id", "regional_centers.id"},
+			{"name", "regional_centers.regional_center"},
+			{"office_type", "regional_centers.office_type"},
+			{"address", "regional_centers.address"},
+			{"city", "regional_centers.city"},
+			{"zip_code", "regional_centers.zip_code"},
+			{"telephone", "regional_centers.telephone"},
+			{"website", "regional_centers.website"},
+			{"county_served", "regional_centers.county_served"},
+		},
+		Filters: map[string]Filter{
+			"county": ilike("regional_centers.county_served"),
+		},
+	},
+	"catchments": {
+		Name:     "catchments",
+		Table:    "regional_center_catchments",
+		Geometry: "regional_center_catchments.geom",
+		Where: "regional_center_catchments.regional_center_id IN " +
+			"(SELECT id FROM regional_centers WHERE deleted_at IS NULL)",
+		Attributes: []Attribute{
+			{"id", "regional_center_catchments.id"},
+			{"regional_center_id", "regional_center_catchments.regional_center_id"},
+			{"name", "regional_center_catchments.name"},
+		},
+		Filters: map[string]Filter{
+			"regional_center_id": func(value string) (string, []interface{}) {
+				return "regional_center_catchments.regional_center_id::text = ?", []interface{}{value}
+			},
+		},
+	},
+	"providers": {
+		Name:     "providers",
+		Table:    "providers",
+		Geometry: "ST_SetSRID(ST_MakePoint(providers.longitude, providers.latitude), 4326)",
+		// Spreadsheet rows without a geocode were loaded as 0, 0
+		Where: "providers.latitude <> 0 AND providers.longitude <> 0 AND providers.deleted_at IS NULL",
+		Attributes: []Attribute{
+			{"id", "providers.id"},
+			{"name", "providers.name"},
+			{"phone", "providers.phone"},
+			{"coverage_areas", "providers.coverage_areas"},
+			{"center_based_services", "providers.center_based_services"},
+			{"areas", "providers.areas"},
+		},
+		Filters: map[string]Filter{
+			"service_type": ilike("providers.center_based_services"),
+			"area":         anyILike("providers.areas", "providers.coverage_areas"),
+		},
+	},
+	"aba_centers": {
+		Name:     "aba_centers",
+		Table:    "aba_centers",
+		Geometry: "aba_centers.location",
+		Where:    "aba_centers.location IS NOT NULL AND aba_centers.deleted_at IS NULL",
+		Attributes: []Attribute{
+			{"id", "aba_centers.id::text"},
+			{"name", "aba_centers.name"},
+			{"street", "aba_centers.street"},
+			{"city", "aba_centers.city"},
+			{"zip", "aba_centers.zip"},
+			{"phone", "aba_centers.phone"},
+			{"service_type", "aba_centers.service_type"},
+			{"waitlist_availability", "aba_centers.waitlist_availability"},
+			{"insurance_accepted", "aba_centers.insurance_accepted"},
+			{"medi_cal_plans", "aba_centers.medi_cal_plans"},
+		},
+		Filters: map[string]Filter{
+			"service_type": func(value string) (string, []interface{}) {
+				return "aba_centers.service_type = ?", []interface{}{value}
+			},
+			"insurance": anyILike("aba_centers.insurance_accepted", "aba_centers.medi_cal_plans"),
+			"city":      ilike("aba_centers.city"),
+		},
+	},
+	"resources": {
+		Name:     "resources",
+		Table:    "resources",
+		Geometry: "ST_SetSRID(ST_MakePoint(resources.longitude, resources.latitude), 4326)",
+		Where:    "resources.latitude IS NOT NULL AND resources.longitude IS NOT NULL AND resources.deleted_at IS NULL",
+		Attributes: []Attribute{
+			{"id", "resources.id::text"},
+			{"name", "resources.name"},
+			{"description", "resources.description"},
+			{"address", "resources.address"},
+			{"diagnoses", "array_to_string(resources.diagnoses, ',')"},
+		},
+		Filters: map[string]Filter{
+			"diagnosis": func(value string) (string, []interface{}) {
+				return "EXISTS (SELECT 1 FROM unnest(resources.diagnoses) AS d WHERE d ILIKE ?)", []interface{}{value}
+			},
+		},
+	},
+}
+
+// Get returns the named layer
+func Get(name string) (*Layer, error) {
+	layer, ok := Layers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownLayer, name)
+	}
+	return layer, nil
+}
+
+// Names returns every layer name in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(Layers))
+	for name := range Layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FieldNames returns the names of the layer's attributes
+func (l *Layer) FieldNames() []string {
+	names := make([]string, len(l.Attributes))
+	for i, a := range l.Attributes {
+		names[i] = a.Name
+	}
+	return names
+}
+
+// FilterNames returns the query parameters the layer can be filtered by
+func (l *Layer) FilterNames() []string {
+	names := make([]string, 0, len(l.Filters))
+	for name := range l.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectAttributes returns the requested attributes, or all of them when none
+// are named. The id is always included so clients can fetch the full record.
+func (l *Layer) SelectAttributes(fields []string) ([]Attribute, error) {
+	if len(fields) == 0 {
+		return l.Attributes, nil
+	}
+
+	selected := []Attribute{l.Attributes[0]}
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" || field == l.Attributes[0].Name {
+			continue
+		}
+		found := false
+		for _, a := range l.Attributes {
+			if a.Name == field {
+				selected = append(selected, a)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w %q for layer %s", ErrUnknownField, field, l.Name)
+		}
+	}
+	return selected, nil
+}
+
+// Conditions returns the layer's base condition plus one clause per filter value
+func (l *Layer) Conditions(filters map[string]string) ([]string, []interface{}, error) {
+	clauses := []string{}
+	args := []interface{}{}
+	if l.Where != "" {
+		clauses = append(clauses, l.Where)
+	}
+
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := strings.TrimSpace(filters[name])
+		if value == "" {
+			continue
+		}
+		filter, ok := l.Filters[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s on layer %s", ErrUnknownFilter, name, l.Name)
+		}
+		clause, filterArgs := filter(value)
+		clauses = append(clauses, clause)
+		args = append(args, filterArgs...)
+	}
+	return clauses, args, nil
+}
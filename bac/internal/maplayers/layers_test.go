@@ -0,0 +1,131 @@
+package maplayers
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// attributeNames returns the names of attributes in order
+func attributeNames(attributes []Attribute) []string {
+	names := make([]string, len(attributes))
+	for i, a := range attributes {
+		names[i] = a.Name
+	}
+	return names
+}
+
+func TestEveryLayerStartsWithID(t *testing.T) {
+	for _, name := range Names() {
+		layer, err := Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if layer.Name != name || len(layer.Attributes) == 0 || layer.Attributes[0].Name != "id" {
+			t.Errorf("layer %s: name %q, attributes %q", name, layer.Name, layer.FieldNames())
+		}
+	}
+	if _, err := Get("parcels"); !errors.Is(err, ErrUnknownLayer) {
+		t.Errorf("unknown layer: got %v, want ErrUnknownLayer", err)
+	}
+}
+
+func TestSelectAttributes(t *testing.T) {
+	layer := Layers["aba_centers"]
+	tests := []struct {
+		name   string
+		fields []string
+		want   []string
+	}{
+		{"none named", nil, layer.FieldNames()},
+		{"id is always first", []string{"city", "name"}, []string{"id", "city", "name"}},
+		{"id named", []string{"name", "id"}, []string{"id", "name"}},
+		{"blank and padded names", []string{" phone ", ""}, []string{"id", "phone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes, err := layer.SelectAttributes(tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := attributeNames(attributes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Fields are matched by name, never used as SQL
+	for _, field := range []string{"location", "name; DROP TABLE aba_centers", "aba_centers.name"} {
+		if _, err := layer.SelectAttributes([]string{field}); !errors.Is(err, ErrUnknownField) {
+			t.Errorf("field %q: got %v, want ErrUnknownField", field, err)
+		}
+	}
+}
+
+func TestConditions(t *testing.T) {
+	layer := Layers["aba_centers"]
+	tests := []struct {
+		name    string
+		filters map[string]string
+		clauses []string
+		args    []interface{}
+	}{
+		{"no filters", nil, nil, nil},
+		{"blank values are ignored", map[string]string{"city": "  ", "service_type": ""}, nil, nil},
+		{
+			"filters in name order",
+			map[string]string{"service_type": " Clinic ", "city": "Fresno"},
+			[]string{"aba_centers.city ILIKE ?", "aba_centers.service_type = ?"},
+			[]interface{}{"%Fresno%", "Clinic"},
+		},
+		{
+			"one value against several columns",
+			map[string]string{"insurance": "Kaiser"},
+			[]string{"(aba_centers.insurance_accepted ILIKE ? OR aba_centers.medi_cal_plans ILIKE ?)"},
+			[]interface{}{"%Kaiser%", "%Kaiser%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clauses, args, err := layer.Conditions(tt.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The layer's own condition always comes first
+			wantClauses := append([]string{layer.Where}, tt.clauses...)
+			if !reflect.DeepEqual(clauses, wantClauses) {
+				t.Errorf("clauses %q, want %q", clauses, wantClauses)
+			}
+			wantArgs := append([]interface{}{}, tt.args...)
+			if !reflect.DeepEqual(args, wantArgs) {
+				t.Errorf("args %q, want %q", args, wantArgs)
+			}
+			if placeholders := strings.Count(strings.Join(clauses, " "), "?"); placeholders != len(args) {
+				t.Errorf("%d placeholders for %d args", placeholders, len(args))
+			}
+		})
+	}
+
+	_, _, err := layer.Conditions(map[string]string{"city": "Fresno", "diagnosis": "autism"})
+	if !errors.Is(err, ErrUnknownFilter) || !strings.Contains(err.Error(), "diagnosis") {
+		t.Errorf("filter from another layer: got %v, want ErrUnknownFilter naming diagnosis", err)
+	}
+}
+
+func TestTileRejectsInvalidCoordinates(t *testing.T) {
+	// Coordinates are checked before the database is touched
+	service := NewService(nil)
+	layer := Layers["aba_centers"]
+	for _, req := range []TileRequest{
+		{Z: -1},
+		{Z: MaxZoom + 1},
+		{Z: 2, X: 4, Y: 0},
+		{Z: 2, X: 0, Y: 4},
+		{Z: 0, X: -1, Y: 0},
+	} {
+		if _, err := service.Tile(layer, req); !errors.Is(err, ErrInvalidTile) {
+			t.Errorf("tile %d/%d/%d: got %v, want ErrInvalidTile", req.Z, req.X, req.Y, err)
+		}
+	}
+}
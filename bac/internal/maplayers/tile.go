@@ -0,0 +1,75 @@
+package maplayers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MaxZoom is the deepest tile zoom served
+const MaxZoom = 22
+
+// ErrInvalidTile is returned for tile coordinates outside the zoom level's grid
+var ErrInvalidTile = errors.New("invalid tile coordinates")
+
+// Service builds map layer queries
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a new Service instance
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// TileRequest selects one tile of a layer
+type TileRequest struct {
+	Z, X, Y int
+	Fields  []string
+	Filters map[string]string
+}
+
+// Tile renders the layer's features inside web mercator tile z/x/y as a
+// Mapbox Vector Tile. An empty tile has no bytes.
+func (s *Service) Tile(layer *Layer, req TileRequest) ([]byte, error) {
+	if req.Z < 0 || req.Z > MaxZoom || req.X < 0 || req.Y < 0 || req.X >= 1<<req.Z || req.Y >= 1<<req.Z {
+		return nil, ErrInvalidTile
+	}
+
+	attributes, err := layer.SelectAttributes(req.Fields)
+	if err != nil {
+		return nil, err
+	}
+	conditions, filterArgs, err := layer.Conditions(req.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(attributes))
+	for i, a := range attributes {
+		columns[i] = fmt.Sprintf(`%s AS "%s"`, a.SQL, a.Name)
+	}
+	// Compare in WGS84 so the planar index on the layer geometry is used
+	conditions = append([]string{layer.Geometry + " && ST_Transform(bounds.envelope, 4326)"}, conditions...)
+
+	query := `
+		WITH bounds AS (SELECT ST_TileEnvelope(?, ?, ?) AS envelope),
+		features AS (
+			SELECT ST_AsMVTGeom(ST_Transform(` + layer.Geometry + `, 3857), bounds.envelope, 4096, 64, true) AS mvt_geom,
+				` + strings.Join(columns, ", ") + `
+			FROM ` + layer.Table + `, bounds
+			WHERE ` + strings.Join(conditions, " AND ") + `
+		)
+		SELECT ST_AsMVT(features.*, ?, 4096, 'mvt_geom') FROM features WHERE mvt_geom IS NOT NULL`
+
+	args := append([]interface{}{req.Z, req.X, req.Y}, filterArgs...)
+	args = append(args, layer.Name)
+
+	var tile []byte
+	if err := s.db.Raw(query, args...).Row().Scan(&tile); err != nil {
+		return nil, err
+	}
+	return tile, nil
+}
@@ -0,0 +1,140 @@
+package maplayers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidBBox = errors.New("bbox must be minLng,minLat,maxLng,maxLat in degrees")
+	ErrInvalidZoom = errors.New("zoom must be an integer from 0 to 22")
+)
+
+// clusterRadiusPixels is how close, on screen, points must be to merge
+const clusterRadiusPixels = 40
+
+// BBox is a WGS84 viewport
+type BBox struct {
+	MinLng, MinLat, MaxLng, MaxLat float64
+}
+
+// ParseBBox reads minLng,minLat,maxLng,maxLat
+func ParseBBox(value string) (*BBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, ErrInvalidBBox
+	}
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, ErrInvalidBBox
+		}
+		v[i] = f
+	}
+
+	b := &BBox{MinLng: v[0], MinLat: v[1], MaxLng: v[2], MaxLat: v[3]}
+	if b.MinLng < -180 || b.MaxLng > 180 || b.MinLat < -90 || b.MaxLat > 90 ||
+		b.MinLng >= b.MaxLng || b.MinLat >= b.MaxLat {
+		return nil, ErrInvalidBBox
+	}
+	return b, nil
+}
+
+// Condition restricts the layer to features intersecting the box
+func (b *BBox) Condition(layer *Layer) (string, []interface{}) {
+	return layer.Geometry + " && ST_MakeEnvelope(?, ?, ?, ?, 4326)",
+		[]interface{}{b.MinLng, b.MinLat, b.MaxLng, b.MaxLat}
+}
+
+// Cluster is a group of nearby features drawn as one marker
+type Cluster struct {
+	Count     int     `json:"count"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Bounds is minLng,minLat,maxLng,maxLat of the members, for zooming in
+	Bounds []float64 `json:"bounds"`
+	// ID is set when the cluster is a single feature
+	ID *string `json:"id,omitempty"`
+}
+
+// ClusterRequest selects the features to cluster
+type ClusterRequest struct {
+	Zoom    int
+	BBox    *BBox
+	Filters map[string]string
+}
+
+// Clusters groups the layer's features with ST_ClusterDBSCAN, using a radius
+// that covers the same number of screen pixels at every zoom level
+func (s *Service) Clusters(layer *Layer, req ClusterRequest) ([]Cluster, error) {
+	if req.Zoom < 0 || req.Zoom > MaxZoom {
+		return nil, ErrInvalidZoom
+	}
+
+	conditions, args, err := layer.Conditions(req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	if req.BBox != nil {
+		clause, bboxArgs := req.BBox.Condition(layer)
+		conditions = append(conditions, clause)
+		args = append(args, bboxArgs...)
+	}
+	where := "TRUE"
+	if len(conditions) > 0 {
+		where = strings.Join(conditions, " AND ")
+	}
+
+	// Web mercator meters per pixel at this zoom, for 256 pixel tiles
+	eps := clusterRadiusPixels * 2 * math.Pi * 6378137 / 256 / math.Pow(2, float64(req.Zoom))
+
+	query := `
+		WITH points AS (
+			SELECT ` + layer.Attributes[0].SQL + ` AS id, ST_PointOnSurface(` + layer.Geometry + `) AS geom
+			FROM ` + layer.Table + `
+			WHERE ` + where + `
+		),
+		clustered AS (
+			SELECT id, geom, ST_ClusterDBSCAN(ST_Transform(geom, 3857), ?, 1) OVER () AS cluster_id
+			FROM points
+		)
+		SELECT COUNT(*) AS count,
+			ST_Y(ST_Centroid(ST_Collect(geom))) AS latitude,
+			ST_X(ST_Centroid(ST_Collect(geom))) AS longitude,
+			ST_XMin(ST_Extent(geom)) AS min_lng, ST_YMin(ST_Extent(geom)) AS min_lat,
+			ST_XMax(ST_Extent(geom)) AS max_lng, ST_YMax(ST_Extent(geom)) AS max_lat,
+			CASE WHEN COUNT(*) = 1 THEN MIN(id::text) END AS id
+		FROM clustered
+		GROUP BY cluster_id
+		ORDER BY count DESC`
+	args = append(args, eps)
+
+	var rows []struct {
+		Count     int
+		Latitude  float64
+		Longitude float64
+		MinLng    float64
+		MinLat    float64
+		MaxLng    float64
+		MaxLat    float64
+		ID        *string
+	}
+	if err := s.db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	clusters := make([]Cluster, len(rows))
+	for i, row := range rows {
+		clusters[i] = Cluster{
+			Count:     row.Count,
+			Latitude:  row.Latitude,
+			Longitude: row.Longitude,
+			Bounds:    []float64{row.MinLng, row.MinLat, row.MaxLng, row.MaxLat},
+			ID:        row.ID,
+		}
+	}
+	return clusters, nil
+}
@@ -0,0 +1,61 @@
+package maplayers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseBBox(t *testing.T) {
+	got, err := ParseBBox("-120.5, 36.2,-119.1,37")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (BBox{MinLng: -120.5, MinLat: 36.2, MaxLng: -119.1, MaxLat: 37}); *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+
+	for _, value := range []string{
+		"",
+		"-120,36,-119",
+		"-120,36,-119,37,1",
+		"west,36,-119,37",
+		"-181,36,-119,37",
+		"-120,-91,-119,37",
+		"-120,36,181,37",
+		"-120,36,-119,91",
+		"-119,36,-120,37",
+		"-120,37,-119,36",
+		"-120,36,-120,37",
+	} {
+		if _, err := ParseBBox(value); !errors.Is(err, ErrInvalidBBox) {
+			t.Errorf("ParseBBox(%q): got %v, want ErrInvalidBBox", value, err)
+		}
+	}
+}
+
+func TestBBoxCondition(t *testing.T) {
+	b := &BBox{MinLng: -120, MinLat: 36, MaxLng: -119, MaxLat: 37}
+	clause, args := b.Condition(Layers["providers"])
+	want := "ST_SetSRID(ST_MakePoint(providers.longitude, providers.latitude), 4326) && ST_MakeEnvelope(?, ?, ?, ?, 4326)"
+	if clause != want {
+		t.Errorf("clause %q, want %q", clause, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{-120.0, 36.0, -119.0, 37.0}) {
+		t.Errorf("args %v", args)
+	}
+}
+
+func TestClustersRejectsInvalidRequests(t *testing.T) {
+	// Requests are checked before the database is touched
+	service := NewService(nil)
+	for _, zoom := range []int{-1, MaxZoom + 1} {
+		if _, err := service.Clusters(Layers["aba_centers"], ClusterRequest{Zoom: zoom}); !errors.Is(err, ErrInvalidZoom) {
+			t.Errorf("zoom %d: got %v, want ErrInvalidZoom", zoom, err)
+		}
+	}
+	_, err := service.Clusters(Layers["resources"], ClusterRequest{Zoom: 10, Filters: map[string]string{"city": "Fresno"}})
+	if !errors.Is(err, ErrUnknownFilter) {
+		t.Errorf("filter from another layer: got %v, want ErrUnknownFilter", err)
+	}
+}
@@ -2,27 +2,45 @@
 package models
 
 import (
-	"time"
+	"fmt"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
 )
 
 // ABACenter represents an ABA therapy center in the database
 type ABACenter struct {
-	ID                   uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	Name                 string    `gorm:"not null" json:"name"`
-	Street               string    `gorm:"not null" json:"street"`
-	City                 string    `gorm:"not null" json:"city"`
-	Zip                  string    `gorm:"not null" json:"zip"`
-	Phone                string    `gorm:"not null" json:"phone"`
-	ServiceType          string    `gorm:"not null" json:"serviceType"`
-	WaitlistAvailability string    `json:"waitlistAvailability"`
-	WaitlistNotes        string    `json:"waitlistNotes"`
-	DxVerification       string    `json:"dxVerification"`
-	InsuranceAccepted    string    `json:"insuranceAccepted"`
-	MediCalPlans         string    `json:"mediCalPlans"`
-	Notes                string    `json:"notes"`
-	CreatedAt            time.Time `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt            time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+	ID                   uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name                 string     `gorm:"not null" json:"name"`
+	Street               string     `gorm:"not null" json:"street"`
+	City                 string     `gorm:"not null" json:"city"`
+	Zip                  string     `gorm:"not null" json:"zip"`
+	Phone                string     `gorm:"not null" json:"phone"`
+	ServiceType          string     `gorm:"not null" json:"serviceType"`
+	WaitlistAvailability string     `json:"waitlistAvailability"`
+	WaitlistNotes        string     `json:"waitlistNotes"`
+	DxVerification       string     `json:"dxVerification"`
+	InsuranceAccepted    string     `json:"insuranceAccepted"`
+	MediCalPlans         string     `json:"mediCalPlans"`
+	Notes                string     `json:"notes"`
+	Latitude             *float64   `json:"latitude"`
+	Longitude            *float64   `json:"longitude"`
+	GeocodedAt           *time.Time `json:"geocodedAt"`
+	// Mirror the latest waitlist history entry; only written through it
+	WaitlistStatus     *string    `json:"waitlistStatus"`
+	EstimatedWaitWeeks *int       `json:"estimatedWaitWeeks"`
+	LastVerifiedAt     *time.Time `json:"lastVerifiedAt"`
+	// RecordVerifiedAt is when staff last confirmed the whole record
+	RecordVerifiedAt *time.Time `json:"recordVerifiedAt"`
+	// Soft deleted centers are hidden from reads and kept in the admin trash
+	DeletedAt gorm.DeletedAt `json:"-"`
+	// Linked reference data; search filters on these rather than the text columns
+	InsuranceCarriers []InsuranceCarrier `json:"insuranceCarriers,omitempty" gorm:"many2many:aba_center_insurance_carriers;"`
+	MediCalPlanRefs   []MediCalPlan      `json:"mediCalPlanRefs,omitempty" gorm:"many2many:aba_center_medi_cal_plans;"`
+	CreatedBy         *int               `json:"createdBy"`
+	UpdatedBy         *int               `json:"updatedBy"`
+	CreatedAt         time.Time          `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt         time.Time          `gorm:"autoUpdateTime" json:"updatedAt"`
 }
 
 // TableName specifies the table name for the ABACenter model
@@ -44,4 +62,24 @@ type ABACenterRequest struct {
 	InsuranceAccepted    string `json:"insuranceAccepted"`
 	MediCalPlans         string `json:"mediCalPlans"`
 	Notes                string `json:"notes"`
-}
\ No newline at end of file
+	// Optional; when set they replace the center's linked carriers or plans,
+	// otherwise the links are parsed from the text fields
+	InsuranceCarrierIDs *[]int `json:"insuranceCarrierIds"`
+	MediCalPlanIDs      *[]int `json:"mediCalPlanIds"`
+	// Optional; when set a waitlist check is recorded along with the write
+	Waitlist *WaitlistRequest `json:"waitlist"`
+	// Optional; when both are set they are used as-is instead of geocoding the address
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+}
+
+// Address formats the center's street address for geocoding
+func (a ABACenter) Address() string {
+	return fmt.Sprintf("%s, %s, CA %s", a.Street, a.City, a.Zip)
+}
+
+// NearbyABACenter is an ABA center with its distance from a search point
+type NearbyABACenter struct {
+	ABACenter
+	DistanceMiles float64 `json:"distanceMiles"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKey is a long-lived credential for partner integrations. The secret is
+// shown once at creation; only its SHA-256 hash is stored.
+type APIKey struct {
+	ID                 int            `json:"id" gorm:"primaryKey"`
+	Name               string         `json:"name" gorm:"not null"`
+	Organization       string         `json:"organization"`
+	UserID             *int           `json:"user_id" gorm:"index"`
+	Prefix             string         `json:"prefix" gorm:"not null;index"`
+	KeyHash            string         `json:"-" gorm:"not null;uniqueIndex"`
+	Scopes             pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	RateLimitPerMinute int            `json:"rate_limit_per_minute" gorm:"not null"`
+	ExpiresAt          *time.Time     `json:"expires_at"`
+	LastUsedAt         *time.Time     `json:"last_used_at"`
+	LastUsedIP         string         `json:"last_used_ip"`
+	RevokedAt          *time.Time     `json:"revoked_at"`
+	CreatedBy          *int           `json:"created_by"`
+	CreatedAt          time.Time      `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for the APIKey model
+func (APIKey) TableName() string {
+	return "api_keys"
+}
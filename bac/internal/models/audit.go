@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"time"
+)
+
+// JSON is a raw JSON document stored in a jsonb column
+type JSON []byte
+
+// Value stores the document as text so Postgres parses it as jsonb
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan reads a jsonb column
+func (j *JSON) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return errors.New("unsupported type for JSON column")
+	}
+	return nil
+}
+
+// MarshalJSON writes the document as-is, or null when empty
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON keeps a copy of the raw document
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
+// AuditEntry records one change to a directory record
+type AuditEntry struct {
+	ID         int64  `json:"id" gorm:"primaryKey"`
+	EntityType string `json:"entityType"`
+	EntityID   string `json:"entityId"`
+	Operation  string `json:"operation"`
+	ActorID    *int   `json:"actorId"`
+	// Changes is a list of {field, before, after}; the states are full snapshots,
+	// with BeforeState empty for creates and AfterState empty for deletes
+	Changes      JSON      `json:"changes" gorm:"type:jsonb"`
+	BeforeState  JSON      `json:"before" gorm:"type:jsonb"`
+	AfterState   JSON      `json:"after" gorm:"type:jsonb"`
+	RevertedFrom *int64    `json:"revertedFrom"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TableName specifies the table name for the AuditEntry model
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RegionalCenterCatchment is a service area polygon assigned to a regional center.
+// The geometry lives in the geom column and is only read through PostGIS queries.
+type RegionalCenterCatchment struct {
+	ID               int            `json:"id" gorm:"primaryKey"`
+	RegionalCenterID int            `json:"regional_center_id"`
+	Name             string         `json:"name"`
+	ZipCodes         pq.StringArray `json:"zip_codes" gorm:"type:text[]"`
+	Source           string         `json:"source"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// TableName specifies the table name for the RegionalCenterCatchment model
+func (RegionalCenterCatchment) TableName() string {
+	return "regional_center_catchments"
+}
+
+// CatchmentLookup answers which regional center serves a location
+type CatchmentLookup struct {
+	// MatchedBy is "point" when the location fell inside a polygon, or "zip" when
+	// the catchment lists the ZIP code explicitly
+	MatchedBy      string                  `json:"matched_by"`
+	Catchment      RegionalCenterCatchment `json:"catchment"`
+	RegionalCenter RegionalCenter          `json:"regional_center"`
+	NearestOffice  *NearbyRegionalCenter   `json:"nearest_office"`
+}
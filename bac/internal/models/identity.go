@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// UserIdentity links a local user to an account at an external identity provider
+type UserIdentity struct {
+	ID          int        `json:"id" gorm:"primaryKey"`
+	UserID      int        `json:"user_id" gorm:"not null;index"`
+	Issuer      string     `json:"issuer" gorm:"not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Subject     string     `json:"subject" gorm:"not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for the UserIdentity model
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// OIDCState holds the per-login values of an authorization code + PKCE flow
+// between the redirect to the identity provider and the callback
+type OIDCState struct {
+	State        string    `gorm:"primaryKey"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for the OIDCState model
+func (OIDCState) TableName() string {
+	return "oidc_states"
+}
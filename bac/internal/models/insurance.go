@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// InsuranceCarrier is a commercial insurer an ABA center may accept
+type InsuranceCarrier struct {
+	ID   int    `json:"id" gorm:"primaryKey"`
+	Name string `json:"name"`
+	// Aliases are other spellings, stored as insurance.Key values
+	Aliases   pq.StringArray `json:"aliases" gorm:"type:text[]"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// TableName specifies the table name for the InsuranceCarrier model
+func (InsuranceCarrier) TableName() string {
+	return "insurance_carriers"
+}
+
+// MediCalPlan is a Medi-Cal managed care plan an ABA center may accept
+type MediCalPlan struct {
+	ID        int            `json:"id" gorm:"primaryKey"`
+	Name      string         `json:"name"`
+	Aliases   pq.StringArray `json:"aliases" gorm:"type:text[]"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// TableName specifies the table name for the MediCalPlan model
+func (MediCalPlan) TableName() string {
+	return "medi_cal_plans"
+}
+
+// InsuranceMappingReview is a free-text insurance or plan value that matched
+// nothing and is waiting for someone to map or ignore it
+type InsuranceMappingReview struct {
+	ID          int        `json:"id" gorm:"primaryKey"`
+	ABACenterID uuid.UUID  `json:"abaCenterId" gorm:"column:aba_center_id;type:uuid"`
+	Kind        string     `json:"kind"`
+	RawValue    string     `json:"rawValue"`
+	Status      string     `json:"status"`
+	ResolvedID  *int       `json:"resolvedId"`
+	ResolvedBy  *int       `json:"resolvedBy"`
+	ResolvedAt  *time.Time `json:"resolvedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	// CenterName is filled in when listing the queue
+	CenterName string `json:"centerName" gorm:"->;-:migration"`
+}
+
+// TableName specifies the table name for the InsuranceMappingReview model
+func (InsuranceMappingReview) TableName() string {
+	return "insurance_mapping_reviews"
+}
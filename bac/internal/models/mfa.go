@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// UserMFA holds a user's TOTP enrollment. The secret is encrypted at rest.
+type UserMFA struct {
+	UserID          int        `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	SecretEncrypted string     `json:"-" gorm:"not null"`
+	Enabled         bool       `json:"enabled" gorm:"not null;default:false"`
+	EnabledAt       *time.Time `json:"enabled_at"`
+	LastUsedStep    int64      `json:"-" gorm:"not null;default:0"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for the UserMFA model
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// MFARecoveryCode is a one-time code that can stand in for a TOTP code
+type MFARecoveryCode struct {
+	ID        int        `json:"id" gorm:"primaryKey"`
+	UserID    int        `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for the MFARecoveryCode model
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
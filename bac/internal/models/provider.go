@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Provider is a provider loaded from the provider spreadsheet
+type Provider struct {
+	ID                  int     `json:"id" gorm:"primaryKey"`
+	Name                string  `json:"name"`
+	Phone               string  `json:"phone"`
+	CoverageAreas       string  `json:"coverage_areas"`
+	CenterBasedServices string  `json:"center_based_services"`
+	Latitude            float64 `json:"latitude"`
+	Longitude           float64 `json:"longitude"`
+	// Areas is a comma separated list
+	Areas            string         `json:"areas"`
+	RecordVerifiedAt *time.Time     `json:"record_verified_at"`
+	DeletedAt        gorm.DeletedAt `json:"-"`
+}
@@ -2,7 +2,10 @@
 package models
 
 import (
+    "math"
     "time"
+
+    "gorm.io/gorm"
 )
 
 type RegionalCenter struct {
@@ -19,10 +22,18 @@ type RegionalCenter struct {
     CountyServed          string    `json:"county_served"`
     LosAngelesHealthDistrict string `json:"los_angeles_health_district"`
     LocationCoordinates    string    `json:"location_coordinates"`
+    // Read from the location geometry column; see RegionalCenterColumns
+    Latitude              *float64  `json:"latitude" gorm:"->"`
+    Longitude             *float64  `json:"longitude" gorm:"->"`
     CreatedAt             time.Time `json:"created_at"`
     UpdatedAt             time.Time `json:"updated_at"`
+    RecordVerifiedAt      *time.Time `json:"record_verified_at"`
+    DeletedAt             gorm.DeletedAt `json:"-"`
 }
 
+// RegionalCenterColumns selects every regional center column plus lat/lng from the location geometry
+const RegionalCenterColumns = "regional_centers.*, ST_Y(regional_centers.location) AS latitude, ST_X(regional_centers.location) AS longitude"
+
 type RegionalCenterResponse struct {
 		ID                      uint      `json:"id"`
 		RegionalCenter         string    `json:"regional_center"`
@@ -39,4 +50,42 @@ type RegionalCenterResponse struct {
 		LocationCoordinates    string    `json:"location_coordinates"`
 		CreatedAt             time.Time `json:"created_at"`
 		UpdatedAt             time.Time `json:"updated_at"`
-}
\ No newline at end of file
+}
+// NearbyRegionalCenter is a regional center office ranked by distance from a search point
+type NearbyRegionalCenter struct {
+	ID             uint    `json:"id"`
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	Address        string  `json:"address"`
+	Suite          string  `json:"suite"`
+	City           string  `json:"city"`
+	State          string  `json:"state"`
+	ZipCode        string  `json:"zip_code"`
+	FullAddress    string  `json:"full_address"`
+	Phone          string  `json:"phone"`
+	Website        string  `json:"website"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	DistanceMeters float64 `json:"distance_meters"`
+	DistanceMiles  float64 `json:"distance_miles"`
+}
+
+// SetDistanceMiles fills DistanceMiles from DistanceMeters, rounded to two places
+func (n *NearbyRegionalCenter) SetDistanceMiles() {
+	n.DistanceMiles = math.Round(n.DistanceMeters/1609.344*100) / 100
+}
+
+// NearbyRegionalCenterColumns selects a NearbyRegionalCenter from regional_centers
+// measured against a geography named search.point
+const NearbyRegionalCenterColumns = `
+	id, regional_center AS name, office_type AS type,
+	address, suite, city, state, zip_code,
+	CONCAT_WS(', ',
+		NULLIF(address, ''),
+		NULLIF(suite, ''),
+		NULLIF(city, ''),
+		NULLIF(TRIM(CONCAT_WS(' ', state, zip_code)), '')
+	) AS full_address,
+	telephone AS phone, website,
+	ST_Y(location) AS latitude, ST_X(location) AS longitude,
+	ST_Distance(geog, search.point) AS distance_meters`
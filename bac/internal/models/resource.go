@@ -4,6 +4,7 @@ import (
     "errors"
     "time"
     "github.com/lib/pq"
+    "gorm.io/gorm"
 )
 
 type Resource struct {
@@ -14,8 +15,12 @@ type Resource struct {
     Latitude    float64   `json:"latitude"`
     Longitude   float64   `json:"longitude"`
 	Diagnoses   pq.StringArray `gorm:"type:text[]"` // Correct type for PostgreSQL array
+    CreatedBy   *int      `json:"created_by"`
+    UpdatedBy   *int      `json:"updated_by"`
     CreatedAt   time.Time `json:"created_at"`
     UpdatedAt   time.Time `json:"updated_at"`
+	RecordVerifiedAt *time.Time `json:"record_verified_at"`
+	DeletedAt   gorm.DeletedAt `json:"-"`
 }
 
 type ResourceResponse struct {
@@ -26,6 +31,8 @@ type ResourceResponse struct {
     Latitude    float64   `json:"latitude"`
     Longitude   float64   `json:"longitude"`
 	Diagnoses   []string  `json:"diagnoses"`
+    CreatedBy   *int      `json:"created_by"`
+    UpdatedBy   *int      `json:"updated_by"`
     CreatedAt   time.Time `json:"created_at"`
     UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -37,8 +44,11 @@ type ResourceCenter struct {
 	Location  string    `gorm:"type:geometry(Point,4326)"` // PostGIS Point
 	Latitude  float64   `gorm:"not null"`
 	Longitude float64   `gorm:"not null"`
+	CreatedBy *int
+	UpdatedBy *int
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt
 }
 // NearbyResource extends Resource with distance information
 type NearbyResource struct {
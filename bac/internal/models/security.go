@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Scopes for login throttling counters
+const (
+	ThrottleScopeEmail = "email"
+	ThrottleScopeIP    = "ip"
+)
+
+// Security event types
+const (
+	SecurityEventLoginLocked     = "login_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+)
+
+// LoginThrottle counts failed logins for one email address or client IP
+type LoginThrottle struct {
+	ID            int        `json:"id" gorm:"primaryKey"`
+	Scope         string     `json:"scope" gorm:"not null;uniqueIndex:idx_login_throttles_scope_key"`
+	Key           string     `json:"key" gorm:"not null;uniqueIndex:idx_login_throttles_scope_key"`
+	FailedCount   int        `json:"failed_count" gorm:"not null;default:0"`
+	LockoutCount  int        `json:"lockout_count" gorm:"not null;default:0"`
+	FirstFailedAt *time.Time `json:"first_failed_at"`
+	LastFailedAt  *time.Time `json:"last_failed_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName specifies the table name for the LoginThrottle model
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
+
+// SecurityEvent records a security-relevant action such as a lockout
+type SecurityEvent struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	Type      string    `json:"type" gorm:"not null;index"`
+	UserID    *int      `json:"user_id" gorm:"index"`
+	ActorID   *int      `json:"actor_id"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// TableName specifies the table name for the SecurityEvent model
+func (SecurityEvent) TableName() string {
+	return "security_events"
+}
@@ -14,8 +14,10 @@ type User struct {
 	PasswordHash string `json:"-" gorm:"column:password_hash;not null"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Roles     []*Role   `json:"roles,omitempty" gorm:"many2many:user_roles;"`
 }
 
 // Role represents a user role
@@ -23,8 +25,10 @@ type Role struct {
 	ID          int       `json:"id" gorm:"primaryKey"`
 	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
 	Description string    `json:"description"`
+	RequireMFA  bool      `json:"require_mfa" gorm:"column:require_mfa;not null;default:false"`
 	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
 	Users       []*User   `json:"-" gorm:"many2many:user_roles;"`
+	Permissions []*Permission `json:"permissions,omitempty" gorm:"many2many:role_permissions;"`
 }
 
 ///Permission represents a permission that can be assigned to roles
@@ -35,12 +39,3 @@ type Permission struct {
 	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
 	Roles       []*Role   `json:"-" gorm:"many2many:role_permissions;"`
 }
-
-// Add these to your auto migration list
-func GetAuthModels() []interface{} {
-	return []interface{}{
-		&User{},
-		&Role{},
-		&Permission{},
-	}
-}
\ No newline at end of file
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Purposes for single-use user tokens
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeOIDCLogin         = "oidc_login"
+)
+
+// UserToken is a single-use, expiring token mailed to a user to verify their
+// email address or reset their password, or handed to the frontend to finish a
+// single sign-on login. Only a hash of the token is stored.
+type UserToken struct {
+	ID        int        `json:"id" gorm:"primaryKey"`
+	UserID    int        `json:"user_id" gorm:"not null;index"`
+	Purpose   string     `json:"purpose" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for the UserToken model
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
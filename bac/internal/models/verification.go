@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// VerificationTask is a directory record flagged for staff to re-verify
+type VerificationTask struct {
+	ID         int64  `json:"id" gorm:"primaryKey"`
+	EntityType string `json:"entityType"`
+	EntityID   string `json:"entityId"`
+	EntityName string `json:"entityName"`
+	// Reasons are machine-readable codes such as "stale" or "invalid_phone";
+	// Details holds a readable explanation for each
+	Reasons       pq.StringArray `json:"reasons" gorm:"type:text[]"`
+	Details       pq.StringArray `json:"details" gorm:"type:text[]"`
+	Status        string         `json:"status"`
+	AssignedTo    *int           `json:"assignedTo"`
+	AssignedAt    *time.Time     `json:"assignedAt"`
+	Outcome       *string        `json:"outcome"`
+	Notes         string         `json:"notes"`
+	CompletedBy   *int           `json:"completedBy"`
+	CompletedAt   *time.Time     `json:"completedAt"`
+	LastFlaggedAt time.Time      `json:"lastFlaggedAt"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+}
+
+// TableName specifies the table name for the VerificationTask model
+func (VerificationTask) TableName() string {
+	return "verification_tasks"
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Waitlist statuses, from shortest to longest wait
+const (
+	WaitlistOpen   = "open"
+	WaitlistShort  = "short"
+	WaitlistLong   = "long"
+	WaitlistClosed = "closed"
+)
+
+// WaitlistStatuses lists the valid statuses in order of increasing wait
+var WaitlistStatuses = []string{WaitlistOpen, WaitlistShort, WaitlistLong, WaitlistClosed}
+
+// WaitlistEntry is one confirmation of an ABA center's waitlist
+type WaitlistEntry struct {
+	ID                 int       `json:"id" gorm:"primaryKey"`
+	ABACenterID        uuid.UUID `json:"abaCenterId" gorm:"column:aba_center_id;type:uuid"`
+	Status             string    `json:"status"`
+	EstimatedWaitWeeks *int      `json:"estimatedWaitWeeks"`
+	Notes              string    `json:"notes"`
+	// Source says where the information came from, e.g. "phone call" or "provider website"
+	Source     string    `json:"source"`
+	VerifiedBy *int      `json:"verifiedBy"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// TableName specifies the table name for the WaitlistEntry model
+func (WaitlistEntry) TableName() string {
+	return "aba_center_waitlist_history"
+}
+
+// WaitlistRequest records a waitlist check for a center
+type WaitlistRequest struct {
+	Status             string `json:"status" binding:"required,oneof=open short long closed"`
+	EstimatedWaitWeeks *int   `json:"estimatedWaitWeeks" binding:"omitempty,min=0"`
+	Notes              string `json:"notes"`
+	Source             string `json:"source" binding:"required"`
+	// VerifiedAt defaults to now; earlier times only add to the history
+	VerifiedAt *time.Time `json:"verifiedAt"`
+}
@@ -0,0 +1,256 @@
+// Package trash manages soft deleted directory records. Deleting a record only
+// stamps deleted_at; admins can list and restore records until the purge job
+// removes them for good once the retention period has passed.
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"bac/internal/audit"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidEntityType = errors.New("entity_type must be aba_centers, resources, regional_centers, resource_centers or providers")
+	ErrNotInTrash        = errors.New("record not found in the trash")
+	ErrPurgeRunning      = errors.New("a purge is already running")
+)
+
+// entity describes one soft deleted table. Restores and purges are recorded
+// in the audit log under the table name.
+type entity struct {
+	table string
+	// name is the column shown as the record's name in the listing
+	name string
+}
+
+var entities = map[string]entity{
+	"aba_centers":      {"aba_centers", "name"},
+	"resources":        {"resources", "name"},
+	"regional_centers": {"regional_centers", "regional_center"},
+	"resource_centers": {"resource_centers", "name"},
+	"providers":        {"providers", "name"},
+}
+
+// entityOrder keeps the listing query stable
+var entityOrder = []string{"aba_centers", "resources", "regional_centers", "resource_centers", "providers"}
+
+// IsEntityType reports whether name is a table with a trash
+func IsEntityType(name string) bool {
+	_, ok := entities[name]
+	return ok
+}
+
+// Item is a record in the trash
+type Item struct {
+	EntityType string    `json:"entityType"`
+	ID         string    `json:"id"`
+	Name       *string   `json:"name"`
+	DeletedAt  time.Time `json:"deletedAt"`
+	DeletedBy  *int      `json:"deletedBy"`
+	// PurgeAt is when the purge job will remove the record for good
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+// Service lists, restores and purges soft deleted records
+type Service struct {
+	db        *gorm.DB
+	retention time.Duration
+	running   sync.Mutex
+}
+
+// NewService creates a new Service instance. Records stay in the trash for
+// retention before they are purged.
+func NewService(db *gorm.DB, retention time.Duration) *Service {
+	return &Service{db: db, retention: retention}
+}
+
+// tables returns the given entity types, or all of them, that exist in this database
+func (s *Service) tables(entityType string) ([]entity, error) {
+	names := entityOrder
+	if entityType != "" {
+		if !IsEntityType(entityType) {
+			return nil, ErrInvalidEntityType
+		}
+		names = []string{entityType}
+	}
+	tables := []entity{}
+	for _, name := range names {
+		// resource_centers is not created by every deployment
+		if s.db.Migrator().HasTable(name) {
+			tables = append(tables, entities[name])
+		}
+	}
+	return tables, nil
+}
+
+// List returns the records in the trash, most recently deleted first, with
+// the total count for paging. entityType may be empty to list every table.
+func (s *Service) List(entityType string, limit, offset int) ([]Item, int64, error) {
+	tables, err := s.tables(entityType)
+	if err != nil {
+		return nil, 0, err
+	}
+	items := []Item{}
+	if len(tables) == 0 {
+		return items, 0, nil
+	}
+
+	selects := make([]string, 0, len(tables))
+	for _, e := range tables {
+		selects = append(selects, fmt.Sprintf(
+			"SELECT '%s' AS entity_type, id::text AS id, %s::text AS name, deleted_at, deleted_by FROM %s WHERE deleted_at IS NOT NULL",
+			e.table, e.name, e.table))
+	}
+	union := strings.Join(selects, " UNION ALL ")
+
+	var total int64
+	if err := s.db.Raw("SELECT COUNT(*) FROM (" + union + ") trash").Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = s.db.Raw(union+" ORDER BY deleted_at DESC, entity_type, id LIMIT ? OFFSET ?", limit, offset).
+		Scan(&items).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(s.retention)
+	}
+	return items, total, nil
+}
+
+// Restore takes a record out of the trash
+func (s *Service) Restore(entityType, id string, actorID *int) error {
+	e, ok := entities[entityType]
+	if !ok {
+		return ErrInvalidEntityType
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		before, err := audit.Load(tx.Unscoped(), e.table, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotInTrash
+		} else if err != nil {
+			return err
+		}
+
+		result := tx.Table(e.table).Where("id::text = ? AND deleted_at IS NOT NULL", id).
+			UpdateColumns(map[string]interface{}{"deleted_at": nil, "deleted_by": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotInTrash
+		}
+
+		after, err := audit.Load(tx, e.table, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{
+			EntityType: e.table, EntityID: id, Operation: audit.OpRestore, ActorID: actorID,
+			Before: before, After: after,
+		})
+	})
+}
+
+// Start purges every interval until ctx is cancelled
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.purgeAndLog(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Service) purgeAndLog(ctx context.Context) {
+	purged, err := s.Purge(ctx)
+	if err != nil {
+		if !errors.Is(err, ErrPurgeRunning) && ctx.Err() == nil {
+			log.Printf("Trash purge failed: %v", err)
+		}
+		return
+	}
+	if len(purged) > 0 {
+		log.Printf("Trash purge: removed %v", purged)
+	}
+}
+
+// Purge permanently deletes records that have been in the trash longer than
+// the retention period and returns how many were removed from each table.
+// The last state of each record is kept in the audit log.
+func (s *Service) Purge(ctx context.Context) (map[string]int64, error) {
+	if !s.running.TryLock() {
+		return nil, ErrPurgeRunning
+	}
+	defer s.running.Unlock()
+
+	tables, err := s.tables("")
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-s.retention)
+	purged := map[string]int64{}
+	for _, e := range tables {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		var n int64
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			n, err = purgeTable(tx, e, cutoff)
+			return err
+		})
+		if err != nil {
+			return purged, fmt.Errorf("%s: %w", e.table, err)
+		}
+		if n > 0 {
+			purged[e.table] = n
+		}
+	}
+	return purged, nil
+}
+
+// purgeTable hard deletes one table's expired records
+func purgeTable(tx *gorm.DB, e entity, cutoff time.Time) (int64, error) {
+	var ids []string
+	err := tx.Table(e.table).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Pluck("id::text", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		record, err := audit.Load(tx.Unscoped(), e.table, id)
+		if err != nil {
+			return 0, err
+		}
+		err = audit.Record(tx, audit.Change{EntityType: e.table, EntityID: id, Operation: audit.OpPurge, Before: record})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Queued verification work for the records goes with them
+	if err := tx.Exec("DELETE FROM verification_tasks WHERE entity_type = ? AND entity_id IN ?", e.table, ids).Error; err != nil {
+		return 0, err
+	}
+	result := tx.Exec("DELETE FROM "+e.table+" WHERE id::text IN ?", ids)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,55 @@
+package trash
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"bac/internal/audit"
+	"bac/internal/models"
+	"bac/internal/testutil"
+)
+
+func TestEveryTableIsAudited(t *testing.T) {
+	for _, name := range entityOrder {
+		if !audit.IsEntityType(name) {
+			t.Errorf("%s has a trash but no audit history", name)
+		}
+	}
+}
+
+func TestRestoreAndPurgeAreAudited(t *testing.T) {
+	tx := testutil.Tx(t)
+	service := NewService(tx, time.Hour)
+
+	provider := models.Provider{Name: "Trashed Provider", Phone: "5595550100"}
+	if err := tx.Create(&provider).Error; err != nil {
+		t.Fatal(err)
+	}
+	id := provider.ID
+	if err := tx.Delete(&provider).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Restore(audit.EntityProvider, strconv.Itoa(id), nil); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	// Back in the trash long enough ago to be purged
+	err := tx.Table("providers").Where("id = ?", id).UpdateColumn("deleted_at", time.Now().Add(-2*time.Hour)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := purgeTable(tx, entities[audit.EntityProvider], time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	var operations []string
+	err = tx.Model(&models.AuditEntry{}).Where("entity_type = ? AND entity_id = ?", audit.EntityProvider, strconv.Itoa(id)).
+		Order("id").Pluck("operation", &operations).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(operations) != 2 || operations[0] != audit.OpRestore || operations[1] != audit.OpPurge {
+		t.Errorf("audit operations = %v, want restore then purge", operations)
+	}
+}
@@ -0,0 +1,64 @@
+package verification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Checker decides whether a website is reachable. The scan takes it as an
+// interface so tests and offline environments can swap in a stub.
+type Checker interface {
+	Check(ctx context.Context, url string) error
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func(ctx context.Context, url string) error
+
+// Check calls f
+func (f CheckerFunc) Check(ctx context.Context, url string) error {
+	return f(ctx, url)
+}
+
+// HTTPChecker treats a website as reachable when it answers with a status below 400
+type HTTPChecker struct {
+	Client    *http.Client
+	UserAgent string
+}
+
+// NewHTTPChecker creates an HTTPChecker whose requests give up after timeout
+func NewHTTPChecker(timeout time.Duration, userAgent string) *HTTPChecker {
+	return &HTTPChecker{Client: &http.Client{Timeout: timeout}, UserAgent: userAgent}
+}
+
+// Check sends a HEAD request, falling back to GET for servers that reject HEAD
+func (h *HTTPChecker) Check(ctx context.Context, url string) error {
+	status, err := h.do(ctx, http.MethodHead, url)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented || status == http.StatusForbidden) {
+		status, err = h.do(ctx, http.MethodGet, url)
+	}
+	if err != nil {
+		return err
+	}
+	if status >= 400 {
+		return fmt.Errorf("responded with HTTP %d", status)
+	}
+	return nil
+}
+
+func (h *HTTPChecker) do(ctx context.Context, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if h.UserAgent != "" {
+		req.Header.Set("User-Agent", h.UserAgent)
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,477 @@
+// Package verification flags directory records that are overdue for
+// re-verification or fail automated checks, and tracks the staff queue that
+// works through them
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"bac/internal/audit"
+	"bac/internal/models"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reasons a record is flagged
+const (
+	ReasonStale              = "stale"
+	ReasonInvalidPhone       = "invalid_phone"
+	ReasonUnreachableWebsite = "unreachable_website"
+	ReasonMissingGeocode     = "missing_geocode"
+)
+
+// Task statuses, matching verification_tasks.status
+const (
+	StatusOpen      = "open"
+	StatusAssigned  = "assigned"
+	StatusCompleted = "completed"
+)
+
+// Outcomes recorded when a task is completed. verified and updated mark the
+// record as confirmed; dismissed closes the task without touching it.
+const (
+	OutcomeVerified  = "verified"
+	OutcomeUpdated   = "updated"
+	OutcomeDismissed = "dismissed"
+)
+
+var (
+	ErrTaskNotFound      = errors.New("verification task not found")
+	ErrTaskCompleted     = errors.New("verification task already completed")
+	ErrUserNotFound      = errors.New("assignee not found")
+	ErrInvalidOutcome    = errors.New("outcome must be verified, updated or dismissed")
+	ErrInvalidEntityType = errors.New("entity type must be aba_centers, providers, resources or regional_centers")
+	ErrScanRunning       = errors.New("a verification scan is already running")
+)
+
+// entity describes how to read the checked fields from one directory table.
+// Empty phone or website expressions mean the table has no such column.
+type entity struct {
+	table    string
+	name     string
+	phone    string
+	website  string
+	located  string
+	verified string
+}
+
+const located = "latitude IS NOT NULL AND longitude IS NOT NULL AND NOT (latitude = 0 AND longitude = 0)"
+
+// Entities are scanned in this order
+var Entities = []string{"aba_centers", "providers", "resources", "regional_centers"}
+
+var entities = map[string]entity{
+	// A waitlist check is a phone call to the center, so it counts as verifying it
+	"aba_centers": {"aba_centers", "name", "phone", "", located, "GREATEST(record_verified_at, last_verified_at, created_at)"},
+	"providers":   {"providers", "name", "phone", "", located, "record_verified_at"},
+	"resources":   {"resources", "name", "", "", located, "GREATEST(record_verified_at, created_at)"},
+	"regional_centers": {"regional_centers", "regional_center", "telephone", "website", "location IS NOT NULL",
+		"GREATEST(record_verified_at, created_at)"},
+}
+
+// IsEntityType reports whether name is one of the scanned tables
+func IsEntityType(name string) bool {
+	_, ok := entities[name]
+	return ok
+}
+
+var (
+	phoneExtension = regexp.MustCompile(`(?i)\s*(x|ext\.?|extension)\s*\d+$`)
+	phoneChars     = regexp.MustCompile(`^[0-9\s().+-]+$`)
+)
+
+// ValidPhone reports whether s is a dialable US number, with an optional extension
+func ValidPhone(s string) bool {
+	s = strings.TrimSpace(phoneExtension.ReplaceAllString(strings.TrimSpace(s), ""))
+	if !phoneChars.MatchString(s) {
+		return false
+	}
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	if len(digits) == 11 && digits[0] == '1' {
+		digits = digits[1:]
+	}
+	// Area codes and exchanges never start with 0 or 1
+	return len(digits) == 10 && digits[0] >= '2' && digits[3] >= '2'
+}
+
+// Config controls the scan
+type Config struct {
+	// StaleAfter is how long a record may go without verification
+	StaleAfter time.Duration
+	// Interval between background scans; zero disables them
+	Interval time.Duration
+	// CheckWebsites turns the website reachability check on
+	CheckWebsites bool
+	HTTPTimeout   time.Duration
+	UserAgent     string
+}
+
+// Service runs scans and manages the verification queue
+type Service struct {
+	db         *gorm.DB
+	checker    Checker
+	staleAfter time.Duration
+	running    sync.Mutex
+}
+
+// NewService creates a new Service instance. checker may be nil to skip
+// website checks.
+func NewService(db *gorm.DB, checker Checker, staleAfter time.Duration) *Service {
+	return &Service{db: db, checker: checker, staleAfter: staleAfter}
+}
+
+// New builds a Service from cfg, checking websites over HTTP when enabled
+func New(db *gorm.DB, cfg Config) *Service {
+	var checker Checker
+	if cfg.CheckWebsites {
+		checker = NewHTTPChecker(cfg.HTTPTimeout, cfg.UserAgent)
+	}
+	return NewService(db, checker, cfg.StaleAfter)
+}
+
+// Summary reports what a scan found
+type Summary struct {
+	StartedAt time.Time      `json:"startedAt"`
+	Duration  string         `json:"duration"`
+	Checked   map[string]int `json:"checked"`
+	Flagged   map[string]int `json:"flagged"`
+	Reasons   map[string]int `json:"reasons"`
+	// Cleared counts open tasks closed because their record passed this scan
+	Cleared int `json:"cleared"`
+}
+
+// Start scans every interval until ctx is cancelled
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.scanAndLog(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Service) scanAndLog(ctx context.Context) {
+	summary, err := s.Scan(ctx)
+	if err != nil {
+		if !errors.Is(err, ErrScanRunning) && ctx.Err() == nil {
+			log.Printf("Verification scan failed: %v", err)
+		}
+		return
+	}
+	log.Printf("Verification scan: checked %v, flagged %v, cleared %d in %s",
+		summary.Checked, summary.Flagged, summary.Cleared, summary.Duration)
+}
+
+// record is a directory row as read by the scan
+type record struct {
+	ID         string
+	Name       *string
+	Phone      *string
+	Website    *string
+	Located    bool
+	VerifiedAt *time.Time
+}
+
+// Scan checks every directory record and refreshes the queue. Records with
+// problems get an open task, or have their existing one updated; open tasks
+// whose record now passes are closed as dismissed.
+func (s *Service) Scan(ctx context.Context) (*Summary, error) {
+	if !s.running.TryLock() {
+		return nil, ErrScanRunning
+	}
+	defer s.running.Unlock()
+
+	summary := &Summary{
+		StartedAt: time.Now(),
+		Checked:   map[string]int{},
+		Flagged:   map[string]int{},
+		Reasons:   map[string]int{},
+	}
+	websites := map[string]error{}
+	for _, name := range Entities {
+		if err := s.scanEntity(ctx, entities[name], summary, websites); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	summary.Duration = time.Since(summary.StartedAt).Round(time.Millisecond).String()
+	return summary, nil
+}
+
+func (s *Service) scanEntity(ctx context.Context, e entity, summary *Summary, websites map[string]error) error {
+	column := func(expr, alias string) string {
+		if expr == "" {
+			return "NULL::text AS " + alias
+		}
+		return expr + " AS " + alias
+	}
+	query := s.db.WithContext(ctx).Table(e.table).Select(strings.Join([]string{
+		"id::text AS id",
+		column(e.name, "name"),
+		column(e.phone, "phone"),
+		column(e.website, "website"),
+		"(" + e.located + ") AS located",
+		e.verified + " AS verified_at",
+	}, ", ")).Where("deleted_at IS NULL")
+
+	// Read everything first so slow website checks don't hold a cursor open
+	var records []record
+	if err := query.Scan(&records).Error; err != nil {
+		return err
+	}
+
+	staleBefore := summary.StartedAt.Add(-s.staleAfter)
+	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		summary.Checked[e.table]++
+
+		var reasons, details []string
+		flag := func(reason, detail string) {
+			reasons = append(reasons, reason)
+			details = append(details, detail)
+			summary.Reasons[reason]++
+		}
+
+		if s.staleAfter > 0 && (r.VerifiedAt == nil || r.VerifiedAt.Before(staleBefore)) {
+			if r.VerifiedAt == nil {
+				flag(ReasonStale, "never verified")
+			} else {
+				flag(ReasonStale, "last verified "+r.VerifiedAt.Format("2006-01-02"))
+			}
+		}
+		if e.phone != "" {
+			if phone := value(r.Phone); phone == "" {
+				flag(ReasonInvalidPhone, "no phone number")
+			} else if !ValidPhone(phone) {
+				flag(ReasonInvalidPhone, fmt.Sprintf("phone %q is not a valid US number", phone))
+			}
+		}
+		if e.website != "" && s.checker != nil {
+			if website := value(r.Website); website != "" {
+				if err := s.checkWebsite(ctx, website, websites); err != nil {
+					flag(ReasonUnreachableWebsite, fmt.Sprintf("website %s: %v", website, err))
+				}
+			}
+		}
+		if !r.Located {
+			flag(ReasonMissingGeocode, "no coordinates")
+		}
+
+		if len(reasons) == 0 {
+			continue
+		}
+		summary.Flagged[e.table]++
+		err := s.db.WithContext(ctx).Exec(`INSERT INTO verification_tasks
+				(entity_type, entity_id, entity_name, reasons, details, last_flagged_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (entity_type, entity_id) WHERE status <> 'completed' DO UPDATE
+			SET entity_name = EXCLUDED.entity_name, reasons = EXCLUDED.reasons, details = EXCLUDED.details,
+				last_flagged_at = EXCLUDED.last_flagged_at, updated_at = NOW()`,
+			e.table, r.ID, value(r.Name), pq.StringArray(reasons), pq.StringArray(details), summary.StartedAt).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	// Unassigned tasks this scan did not flag again have been fixed at the source
+	result := s.db.WithContext(ctx).Model(&models.VerificationTask{}).
+		Where("entity_type = ? AND status = ? AND last_flagged_at < ?", e.table, StatusOpen, summary.StartedAt).
+		Updates(map[string]interface{}{
+			"status":       StatusCompleted,
+			"outcome":      OutcomeDismissed,
+			"notes":        "No longer flagged by the verification scan",
+			"completed_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	summary.Cleared += int(result.RowsAffected)
+	return nil
+}
+
+// checkWebsite checks each distinct URL once per scan
+func (s *Service) checkWebsite(ctx context.Context, website string, checked map[string]error) error {
+	url := website
+	if !strings.Contains(url, "://") {
+		url = "https://" + url
+	}
+	if err, ok := checked[url]; ok {
+		return err
+	}
+	err := s.checker.Check(ctx, url)
+	checked[url] = err
+	return err
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.TrimSpace(*s)
+}
+
+// TaskFilter narrows the queue listing; empty fields match everything
+type TaskFilter struct {
+	Statuses   []string
+	EntityType string
+	Reason     string
+	AssignedTo *int
+	Limit      int
+	Offset     int
+}
+
+// Tasks lists queue entries, oldest first
+func (s *Service) Tasks(filter TaskFilter) ([]models.VerificationTask, int64, error) {
+	query := s.db.Model(&models.VerificationTask{})
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.Reason != "" {
+		query = query.Where("? = ANY (reasons)", filter.Reason)
+	}
+	if filter.AssignedTo != nil {
+		query = query.Where("assigned_to = ?", *filter.AssignedTo)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	tasks := []models.VerificationTask{}
+	err := query.Order("created_at, id").Limit(filter.Limit).Offset(filter.Offset).Find(&tasks).Error
+	return tasks, total, err
+}
+
+// Assign gives a task to a staff member, replacing any earlier assignee
+func (s *Service) Assign(id int64, userID int) (*models.VerificationTask, error) {
+	var task models.VerificationTask
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.lockTask(tx, id, &task); err != nil {
+			return err
+		}
+		var users int64
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Count(&users).Error; err != nil {
+			return err
+		}
+		if users == 0 {
+			return ErrUserNotFound
+		}
+
+		now := time.Now()
+		task.Status, task.AssignedTo, task.AssignedAt = StatusAssigned, &userID, &now
+		return tx.Save(&task).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Completion closes a task
+type Completion struct {
+	Outcome string `json:"outcome" binding:"required"`
+	Notes   string `json:"notes"`
+}
+
+// Complete closes a task. Verified and updated outcomes stamp the record's
+// record_verified_at so it is not flagged as stale again until the window
+// passes; the stamp is recorded in the record's audit history.
+func (s *Service) Complete(id int64, completion Completion, actorID *int) (*models.VerificationTask, error) {
+	switch completion.Outcome {
+	case OutcomeVerified, OutcomeUpdated, OutcomeDismissed:
+	default:
+		return nil, ErrInvalidOutcome
+	}
+
+	var task models.VerificationTask
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.lockTask(tx, id, &task); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if completion.Outcome != OutcomeDismissed {
+			if err := stamp(tx, task, now, actorID); err != nil {
+				return err
+			}
+		}
+
+		outcome := completion.Outcome
+		task.Status, task.Outcome, task.Notes = StatusCompleted, &outcome, completion.Notes
+		task.CompletedBy, task.CompletedAt = actorID, &now
+		return tx.Save(&task).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// stamp marks the task's record as confirmed at now. A record already purged
+// from the trash is left alone.
+func stamp(tx *gorm.DB, task models.VerificationTask, now time.Time, actorID *int) error {
+	e, ok := entities[task.EntityType]
+	if !ok {
+		return ErrInvalidEntityType
+	}
+	// Records in the trash keep their tasks until the purge, so read those too
+	before, err := audit.Load(tx.Unscoped(), e.table, task.EntityID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = tx.Table(e.table).Where("id::text = ?", task.EntityID).UpdateColumn("record_verified_at", now).Error
+	if err != nil {
+		return err
+	}
+	after, err := audit.Load(tx.Unscoped(), e.table, task.EntityID)
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, audit.Change{
+		EntityType: e.table, EntityID: task.EntityID, Operation: audit.OpUpdate, ActorID: actorID,
+		Before: before, After: after,
+	})
+}
+
+// lockTask loads a task that is still in the queue for update
+func (s *Service) lockTask(tx *gorm.DB, id int64, task *models.VerificationTask) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(task, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTaskNotFound
+		}
+		return err
+	}
+	if task.Status == StatusCompleted {
+		return ErrTaskCompleted
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"bac/internal/models"
+	"bac/internal/testutil"
+)
+
+func TestValidPhone(t *testing.T) {
+	tests := map[string]bool{
+		"(559) 555-0100":           true,
+		"559.555.0100":             true,
+		"559 555 0100":             true,
+		"+1 559 555 0100":          true,
+		"1-559-555-0100":           true,
+		" 5595550100 ":             true,
+		"559-555-0100 ext. 12":     true,
+		"559-555-0100 x12":         true,
+		"559-555-0100 Extension 4": true,
+		"":                         false,
+		"555-0100":                 false,
+		"559-555-01000":            false,
+		"2 559 555 0100":           false,
+		"(059) 555-0100":           false,
+		"(159) 555-0100":           false,
+		"559-055-0100":             false,
+		"559-155-0100":             false,
+		"559-555-CALL":             false,
+		"559-555-0100 ext.":        false,
+		"N/A":                      false,
+	}
+	for phone, want := range tests {
+		if got := ValidPhone(phone); got != want {
+			t.Errorf("ValidPhone(%q) = %v, want %v", phone, got, want)
+		}
+	}
+}
+
+func TestCheckWebsiteChecksEachURLOnce(t *testing.T) {
+	calls := map[string]int{}
+	service := NewService(nil, CheckerFunc(func(ctx context.Context, url string) error {
+		calls[url]++
+		if url == "https://closed.example" {
+			return errors.New("no such host")
+		}
+		return nil
+	}), 0)
+
+	checked := map[string]error{}
+	for _, website := range []string{"closed.example", "https://closed.example", "http://open.example", "http://open.example"} {
+		err := service.checkWebsite(context.Background(), website, checked)
+		if want := website != "http://open.example"; (err != nil) != want {
+			t.Errorf("%s: got %v", website, err)
+		}
+	}
+	// Bare hosts are checked over https
+	if want := map[string]int{"https://closed.example": 1, "http://open.example": 1}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls %v, want %v", calls, want)
+	}
+}
+
+func TestHTTPChecker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			if r.Header.Get("User-Agent") != "bac-verifier" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case "/get-only":
+			// Some servers refuse HEAD but answer GET
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	checker := NewHTTPChecker(time.Second, "bac-verifier")
+	for path, reachable := range map[string]bool{"/ok": true, "/get-only": true, "/gone": false} {
+		if err := checker.Check(context.Background(), server.URL+path); (err == nil) != reachable {
+			t.Errorf("%s: got %v, want reachable %v", path, err, reachable)
+		}
+	}
+}
+
+func TestScanRejectsConcurrentScans(t *testing.T) {
+	service := NewService(nil, nil, 0)
+	service.running.Lock()
+	defer service.running.Unlock()
+	if _, err := service.Scan(context.Background()); !errors.Is(err, ErrScanRunning) {
+		t.Errorf("got %v, want ErrScanRunning", err)
+	}
+}
+
+func TestScanFlagsProblems(t *testing.T) {
+	tx := testutil.Tx(t)
+	name := fmt.Sprintf("Verification Test %d", time.Now().UnixNano())
+	website := fmt.Sprintf("closed-%d.example", time.Now().UnixNano())
+	var ids []string
+	for _, phone := range []string{"555-0100", "(559) 555-0100"} {
+		var id string
+		err := tx.Raw(`INSERT INTO regional_centers (regional_center, telephone, website, created_at, updated_at)
+			VALUES (?, ?, ?, NOW(), NOW()) RETURNING id::text`, name, phone, website).Scan(&id).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	// The stub stands in for the network; both centers share one website
+	calls := 0
+	checker := CheckerFunc(func(ctx context.Context, url string) error {
+		if url != "https://"+website {
+			return nil
+		}
+		calls++
+		return errors.New("no such host")
+	})
+	summary, err := NewService(tx, checker, 24*time.Hour).Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("website checked %d times, want 1", calls)
+	}
+	if summary.Flagged["regional_centers"] < 2 {
+		t.Errorf("summary %+v", summary)
+	}
+
+	want := map[string][]string{
+		ids[0]: {ReasonInvalidPhone, ReasonMissingGeocode, ReasonUnreachableWebsite},
+		ids[1]: {ReasonMissingGeocode, ReasonUnreachableWebsite},
+	}
+	for id, reasons := range want {
+		var task models.VerificationTask
+		if err := tx.Where("entity_type = ? AND entity_id = ?", "regional_centers", id).First(&task).Error; err != nil {
+			t.Fatalf("task for %s: %v", id, err)
+		}
+		got := append([]string{}, task.Reasons...)
+		sort.Strings(got)
+		if task.Status != StatusOpen || task.EntityName != name || !reflect.DeepEqual(got, reasons) {
+			t.Errorf("task for %s: %s %q with reasons %q, want %q", id, task.Status, task.EntityName, got, reasons)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package waitlist records when and how each ABA center's waitlist was
+// confirmed and keeps the center's current status in step with the history
+package waitlist
+
+import (
+	"errors"
+	"time"
+
+	"bac/internal/audit"
+	"bac/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCenterNotFound = errors.New("ABA center not found")
+	ErrFutureCheck    = errors.New("verifiedAt cannot be in the future")
+)
+
+// clockSkew allows for clients whose clocks run slightly ahead of the server
+const clockSkew = 5 * time.Minute
+
+// StatusOrder sorts aba_centers from shortest to longest wait, unknown last
+const StatusOrder = "CASE aba_centers.waitlist_status WHEN 'open' THEN 0 WHEN 'short' THEN 1 " +
+	"WHEN 'long' THEN 2 WHEN 'closed' THEN 3 ELSE 4 END"
+
+// Service records waitlist checks and reads them back
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a new Service instance
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Record adds a check to a center's history inside tx. The center's current
+// status only changes when the check is at least as recent as the last one,
+// so backdated checks fill in history without overwriting newer information.
+func (s *Service) Record(tx *gorm.DB, centerID uuid.UUID, req models.WaitlistRequest, actorID *int) (*models.WaitlistEntry, error) {
+	verifiedAt := time.Now()
+	if req.VerifiedAt != nil {
+		if req.VerifiedAt.After(verifiedAt.Add(clockSkew)) {
+			return nil, ErrFutureCheck
+		}
+		verifiedAt = *req.VerifiedAt
+	}
+
+	entry := models.WaitlistEntry{
+		ABACenterID:        centerID,
+		Status:             req.Status,
+		EstimatedWaitWeeks: req.EstimatedWaitWeeks,
+		Notes:              req.Notes,
+		Source:             req.Source,
+		VerifiedBy:         actorID,
+		VerifiedAt:         verifiedAt,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"waitlist_status":      entry.Status,
+		"estimated_wait_weeks": entry.EstimatedWaitWeeks,
+		"last_verified_at":     entry.VerifiedAt,
+		"updated_by":           actorID,
+	}
+	if entry.Notes != "" {
+		updates["waitlist_notes"] = entry.Notes
+	}
+	err := tx.Model(&models.ABACenter{}).
+		Where("id = ? AND (last_verified_at IS NULL OR last_verified_at <= ?)", centerID, entry.VerifiedAt).
+		Updates(updates).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RecordForCenter records a check in its own transaction, auditing the
+// change to the center's current status
+func (s *Service) RecordForCenter(centerID uuid.UUID, req models.WaitlistRequest, actorID *int) (*models.WaitlistEntry, error) {
+	var entry *models.WaitlistEntry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		before, err := audit.Load(tx, audit.EntityABACenter, centerID.String())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCenterNotFound
+		} else if err != nil {
+			return err
+		}
+		if entry, err = s.Record(tx, centerID, req, actorID); err != nil {
+			return err
+		}
+		after, err := audit.Load(tx, audit.EntityABACenter, centerID.String())
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{
+			EntityType: audit.EntityABACenter,
+			EntityID:   centerID.String(),
+			Operation:  audit.OpUpdate,
+			ActorID:    actorID,
+			Before:     before,
+			After:      after,
+		})
+	})
+	return entry, err
+}
+
+// History lists a center's checks, most recent first
+func (s *Service) History(centerID uuid.UUID, limit int) ([]models.WaitlistEntry, error) {
+	var count int64
+	if err := s.db.Model(&models.ABACenter{}).Where("id = ?", centerID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrCenterNotFound
+	}
+
+	entries := []models.WaitlistEntry{}
+	query := s.db.Where("aba_center_id = ?", centerID).Order("verified_at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&entries).Error
+	return entries, err
+}